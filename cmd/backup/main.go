@@ -0,0 +1,93 @@
+// Command backup takes and restores snapshots of a SQLite training database:
+//
+//	backup snapshot -db training.db -dir backups -keep 7
+//	backup list -dir backups
+//	backup verify backups/training-20240101-030000.000000.db
+//	backup restore -db training.db backups/training-20240101-030000.000000.db
+//
+// Snapshots can be taken while the server runs. Stop the server before
+// restoring one.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"training-tracker/internal/backup"
+	"training-tracker/internal/database"
+)
+
+const usage = `usage:
+  backup snapshot [-db training.db] [-dir backups] [-keep 7]
+  backup list [-dir backups]
+  backup verify FILE
+  backup restore [-db training.db] FILE`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	dsn := flags.String("db", "training.db", "SQLite database file")
+	dir := flags.String("dir", "backups", "directory of the snapshots")
+	keep := flags.Int("keep", 7, "number of snapshots to keep, 0 to keep all")
+	flags.Parse(os.Args[2:])
+
+	switch os.Args[1] {
+	case "snapshot":
+		db, dialect, err := database.Open(*dsn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+
+		backups := &backup.Manager{DB: db, Dialect: dialect, Dir: *dir, Keep: *keep, S3: backup.S3FromEnv()}
+
+		snapshot, err := backups.Snapshot(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s (%d bytes)\n", snapshot.Path, snapshot.Size)
+	case "list":
+		snapshots, err := (&backup.Manager{Dir: *dir}).List()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s  %s  %d bytes\n", s.TakenAt.Format("2006-01-02 15:04:05"), s.Path, s.Size)
+		}
+	case "verify":
+		if flags.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		if err := backup.Verify(flags.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("ok")
+	case "restore":
+		if flags.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		if database.DialectOf(*dsn) != database.SQLite {
+			log.Fatal("restore only supports SQLite databases")
+		}
+		previous, err := backup.Restore(flags.Arg(0), *dsn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if previous != "" {
+			fmt.Printf("previous database kept as %s\n", previous)
+		}
+		fmt.Printf("restored %s into %s\n", flags.Arg(0), *dsn)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,100 @@
+// Command doctor reports rows of a training database that break a foreign
+// key, such as details of deleted sessions, and fixes them with -fix:
+//
+//	doctor -db training.db
+//	doctor -db training.db -fix
+//
+// Before fixing a SQLite database it takes a snapshot into -backup-dir.
+// Without -fix it exits with status 1 if it found any problems.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"training-tracker/internal/backup"
+	"training-tracker/internal/database"
+)
+
+// maxListedIDs keeps the report readable for large numbers of rows.
+const maxListedIDs = 20
+
+func main() {
+	dsn := flag.String("db", "training.db", "SQLite database file or postgres:// URL")
+	fix := flag.Bool("fix", false, "fix the problems found")
+	backupDir := flag.String("backup-dir", "backups", "directory for the snapshot taken before fixing a SQLite database, empty to skip it")
+	flag.Parse()
+
+	db, dialect, err := database.Open(*dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Also enables foreign keys with ON DELETE actions on older databases
+	if err := database.CreateTables(db, dialect); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	problems, err := database.Doctor(ctx, db, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return
+	}
+
+	for _, p := range problems {
+		fmt.Printf("%s: %d (%s)\n", p.Description, len(p.IDs), listIDs(p.IDs))
+		fmt.Printf("  fix: %s\n", p.Fix)
+	}
+	if !*fix {
+		fmt.Println("Run with -fix to fix these problems.")
+		os.Exit(1)
+	}
+
+	if dialect == database.SQLite && *backupDir != "" {
+		snapshot, err := (&backup.Manager{DB: db, Dialect: dialect, Dir: *backupDir}).Snapshot(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Snapshot taken before fixing: %s\n", snapshot.Path)
+	}
+
+	if _, err := database.Doctor(ctx, db, true); err != nil {
+		log.Fatal(err)
+	}
+
+	remaining, err := database.Doctor(ctx, db, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(remaining) == 0 {
+		fmt.Println("Fixed.")
+		return
+	}
+	fmt.Println("Left to be fixed by hand:")
+	for _, p := range remaining {
+		fmt.Printf("%s: %d (%s)\n", p.Description, len(p.IDs), listIDs(p.IDs))
+	}
+	os.Exit(1)
+}
+
+// listIDs formats the first IDs of a problem.
+func listIDs(ids []int64) string {
+	list := make([]string, 0, maxListedIDs+1)
+	for i, id := range ids {
+		if i == maxListedIDs {
+			list = append(list, "…")
+			break
+		}
+		list = append(list, fmt.Sprint(id))
+	}
+	return "IDs " + strings.Join(list, ", ")
+}
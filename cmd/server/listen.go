@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+
+	"training-tracker/internal/config"
+)
+
+// listen opens the configured TCP address or Unix socket.
+func listen(cfg config.Config) (net.Listener, error) {
+	path, ok := strings.CutPrefix(cfg.Listen, "unix:")
+	if !ok {
+		return net.Listen("tcp", cfg.Listen)
+	}
+
+	// A socket left behind by a server that did not stop cleanly would make
+	// listening fail; anything else at the path is not ours to remove
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	mode, _ := cfg.Socket()
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	// Closing the listener on shutdown removes the socket file
+	return listener, nil
+}
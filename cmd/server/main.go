@@ -1,37 +1,190 @@
+// Command server runs the training tracker's web interface. It is configured
+// by flags, TRAINING_* environment variables and a YAML file given by
+// -config, in that order of precedence; -print-config shows the result.
+//
+// On SIGINT or SIGTERM it stops accepting connections, lets requests in
+// flight finish and closes the database.
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata" // Time zones must resolve even without system tzdata
+
+	"training-tracker/internal/backup"
+	"training-tracker/internal/config"
 	"training-tracker/internal/database"
 	"training-tracker/internal/handlers"
+	"training-tracker/internal/metrics"
+	"training-tracker/internal/service"
+	"training-tracker/internal/static"
+	"training-tracker/internal/storage"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:], os.Stdout)
+	if errors.Is(err, config.ErrPrinted) || errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err == nil {
+		err = run(cfg)
+	}
+	if err != nil {
+		// Written directly, as errors may span several lines, which a log
+		// record would escape
+		fmt.Fprintln(os.Stderr, "server:", err)
+		os.Exit(1)
+	}
+}
+
+// run serves until the process is asked to stop.
+func run(cfg config.Config) error {
+	// The standard logger goes through slog as well, at info level
+	level, _ := cfg.Level()
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize database
-	db, err := database.InitDB("training.db")
+	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+		return err
+	}
+	db, dialect, err := database.Open(cfg.DB)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer db.Close()
 
 	// Create tables
-	if err := database.CreateTables(db); err != nil {
-		log.Fatal(err)
+	if err := database.CreateTables(db, dialect); err != nil {
+		return err
+	}
+
+	// Rows from before foreign keys were enforced are left for the doctor
+	if problems, err := database.Doctor(ctx, db, false); err != nil {
+		return err
+	} else if len(problems) > 0 {
+		slog.Warn("The database has rows that break foreign keys; run the doctor command to see them", "problems", len(problems))
+	}
+
+	svc := service.New(storage.New(db, dialect))
+
+	// A scheduled backup is cancelled on shutdown, or when starting fails,
+	// but gets to clean up before the database is closed
+	var background sync.WaitGroup
+	defer background.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	backups := &backup.Manager{DB: db, Dialect: dialect, Dir: cfg.Backup.Dir, Keep: cfg.Backup.Keep, S3: backup.S3FromEnv()}
+	switch {
+	case cfg.Backup.Interval <= 0:
+	case dialect != database.SQLite:
+		slog.Info("Scheduled backups are disabled: back up PostgreSQL with pg_dump", "interval", cfg.Backup.Interval)
+	default:
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			backups.Run(ctx, cfg.Backup.Interval)
+		}()
 	}
 
-	// Initialize handlers
+	// Initialize handlers, with templates and static files from the binary
+	// unless they are being worked on
+	var assets fs.FS = static.FS
+	if cfg.Dev {
+		handlers.Templates = os.DirFS(cfg.TemplateDir)
+		handlers.ReloadTemplates = true
+		assets = os.DirFS(cfg.StaticDir)
+		slog.Info("Development mode: serving templates and static files from disk",
+			"templates", cfg.TemplateDir, "static", cfg.StaticDir)
+	}
 	mux := http.NewServeMux()
-	
+
 	// Serve static files
-	fs := http.FileServer(http.Dir("static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(assets))))
 
 	// Register routes
-	handlers.RegisterRoutes(mux, db)
+	handlers.RegisterRoutes(mux, svc, cfg.Features)
+	if cfg.Features.Admin {
+		handlers.RegisterAdminRoutes(mux, svc, backups, cfg.AdminToken)
+		if cfg.AdminToken == "" {
+			slog.Warn("Backup downloads and storing durations are off: set an admin token to turn them on")
+		}
+	}
+	handlers.RegisterHealthRoutes(mux, db)
+	var handler http.Handler = handlers.Recover(mux)
+	if cfg.Features.Metrics {
+		reg := &metrics.Registry{}
+		reg.CollectRuntime()
+		queries := reg.NewHistogram("db_query_duration_seconds",
+			"Time database queries take by the function running them.", metrics.DefaultBuckets, "caller")
+		storage.QueryObserver = func(caller string, d time.Duration) {
+			queries.Observe(d.Seconds(), caller)
+		}
+		handlers.RegisterMetricsRoutes(mux, reg, svc)
+		handler = handlers.Instrument(reg, mux, handler)
+	}
+	if err := handlers.CheckTemplates(); err != nil {
+		return fmt.Errorf("invalid templates:\n%w", err)
+	}
+
+	server := &http.Server{
+		Handler:           handlers.LogRequests(handlers.Mount(cfg, handlers.Localize(svc, handler))),
+		ReadHeaderTimeout: cfg.HTTP.ReadTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	return serve(ctx, server, cfg)
+}
 
-	log.Println("Server starting on :8080")
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		log.Fatal(err)
+// serve runs server until ctx is done and then shuts it down gracefully,
+// giving requests in flight the configured time to finish.
+func serve(ctx context.Context, server *http.Server, cfg config.Config) error {
+	listener, err := listen(cfg)
+	if err != nil {
+		return err
+	}
+
+	tls := cfg.TLS.CertFile != ""
+	slog.Info("Server starting", "listen", cfg.Listen, "tls", tls, "base_path", cfg.BasePath)
+	served := make(chan error, 1)
+	go func() {
+		if tls {
+			served <- server.ServeTLS(listener, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			served <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down", "timeout", cfg.HTTP.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("requests still running after %s: %w", cfg.HTTP.ShutdownTimeout, err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	slog.Info("Server stopped")
+	return nil
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os/user"
+	"time"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+// backend is where the commands read and write plans: the database itself or
+// a running server.
+type backend interface {
+	WorkoutTypes(ctx context.Context) ([]models.WorkoutType, error)
+	Plans(ctx context.Context) ([]models.TrainingPlan, error)
+	Plan(ctx context.Context, id int64) (service.PlanDetail, error)
+	CreatePlan(ctx context.Context, plan service.NewPlan) (service.PlanDetail, error)
+	// SyncPlan updates a plan from its YAML, or only works out the changes
+	// unless apply is set.
+	SyncPlan(ctx context.Context, id int64, plan service.NewPlan, apply bool) (service.PlanSync, error)
+	// Sessions from the from day up to but excluding the to day
+	Sessions(ctx context.Context, from, to time.Time) ([]storage.ScheduledSession, error)
+	Complete(ctx context.Context, c models.Completion) (models.TrainingSession, error)
+	Streaks(ctx context.Context) ([]service.PlanStreak, error)
+	// Settings of the user, such as the day weeks start on
+	Settings(ctx context.Context) (service.Settings, error)
+	// Time zone that decides which day today is
+	Location(ctx context.Context) (*time.Location, error)
+	Close() error
+}
+
+// local works on the database directly, through the same service as the
+// server.
+type local struct {
+	db  *sql.DB
+	svc *service.Service
+}
+
+func openLocal(dsn string) (*local, error) {
+	db, dialect, err := database.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.CreateTables(db, dialect); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &local{db: db, svc: service.New(storage.New(db, dialect))}, nil
+}
+
+// localActor returns who the plan history names for changes made directly
+// on the database.
+func localActor() string {
+	u, err := user.Current()
+	if err != nil {
+		return "command line"
+	}
+	return u.Username + " (command line)"
+}
+
+func (l *local) Close() error {
+	return l.db.Close()
+}
+
+func (l *local) WorkoutTypes(ctx context.Context) ([]models.WorkoutType, error) {
+	return l.svc.WorkoutTypes(ctx)
+}
+
+func (l *local) Plans(ctx context.Context) ([]models.TrainingPlan, error) {
+	return l.svc.Plans(ctx)
+}
+
+func (l *local) Plan(ctx context.Context, id int64) (service.PlanDetail, error) {
+	return l.svc.Plan(ctx, id)
+}
+
+func (l *local) CreatePlan(ctx context.Context, plan service.NewPlan) (service.PlanDetail, error) {
+	created, err := l.svc.CreatePlan(ctx, plan)
+	if err != nil {
+		return service.PlanDetail{}, err
+	}
+	return l.svc.Plan(ctx, created.ID)
+}
+
+func (l *local) SyncPlan(ctx context.Context, id int64, plan service.NewPlan, apply bool) (service.PlanSync, error) {
+	if !apply {
+		return l.svc.PreviewSync(ctx, id, plan)
+	}
+	return l.svc.SyncPlan(ctx, id, plan)
+}
+
+func (l *local) Sessions(ctx context.Context, from, to time.Time) ([]storage.ScheduledSession, error) {
+	return l.svc.Schedule(ctx, storage.DateRange{From: from, To: to})
+}
+
+func (l *local) Complete(ctx context.Context, c models.Completion) (models.TrainingSession, error) {
+	if err := l.svc.CompleteSession(ctx, c); err != nil {
+		return models.TrainingSession{}, err
+	}
+	return l.svc.Session(ctx, c.SessionID)
+}
+
+func (l *local) Streaks(ctx context.Context) ([]service.PlanStreak, error) {
+	loc, err := l.Location(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return l.svc.Streaks(ctx, dates.Today(loc))
+}
+
+func (l *local) Settings(ctx context.Context) (service.Settings, error) {
+	return l.svc.Settings(ctx)
+}
+
+func (l *local) Location(ctx context.Context) (*time.Location, error) {
+	settings, err := l.svc.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Location(), nil
+}
@@ -0,0 +1,492 @@
+// Command training manages plans and sessions from the terminal, either
+// directly on the database or through the server's JSON API:
+//
+//	training plan import cycling.yaml --type cycling --name "MSR 300"
+//	training plan import plans/*.yaml
+//	training plan list
+//	training plan export 3 > plan.yaml
+//	training plan sync 3 msr300.yaml --apply
+//	training plan schema > plan.schema.json
+//	training today
+//	training week
+//	training complete 42 --duration 45 --hr 138 --rpe 6
+//	training stats
+//
+// -db selects the database (default training.db); -server, or the
+// TRAINING_SERVER environment variable, a running server instead.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	_ "time/tzdata" // Time zones must resolve even without system tzdata
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+)
+
+const usage = `usage: training [-db FILE | -server URL] COMMAND
+
+commands:
+  plan import FILE... [--type TYPE] [--name NAME]
+                                               create plans from YAML files
+  plan list                                    list all plans
+  plan export ID                               print a plan as YAML
+  plan sync ID FILE [--apply]                  show, or with --apply make, the
+                                               changes to update a plan from YAML
+  plan schema                                  print the JSON Schema of the YAML
+                                               format, for editors
+  today                                        show today's sessions
+  week                                         show this week's sessions
+  complete ID [--duration MIN] [--hr BPM] [--rpe 1-10] [--distance KM]
+  stats                                        show streaks per plan`
+
+func main() {
+	global := flag.NewFlagSet("training", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	dsn := global.String("db", "training.db", "SQLite database file or postgres:// URL")
+	server := global.String("server", os.Getenv("TRAINING_SERVER"), "base URL of a running server, e.g. http://localhost:8080")
+	global.Parse(os.Args[1:])
+
+	args := global.Args()
+	if len(args) == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	var (
+		b   backend
+		err error
+	)
+	ctx := context.Background()
+	if *server != "" {
+		b = newRemote(*server)
+	} else {
+		b, err = openLocal(*dsn)
+		if err != nil {
+			fail(err)
+		}
+		ctx = service.WithActor(ctx, localActor())
+	}
+	defer b.Close()
+
+	if err := run(ctx, b, args); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "training:", err)
+	os.Exit(1)
+}
+
+// run executes a command with its arguments.
+func run(ctx context.Context, b backend, args []string) error {
+	command, args := args[0], args[1:]
+	if command == "plan" && len(args) > 0 {
+		command, args = "plan "+args[0], args[1:]
+	}
+
+	switch command {
+	case "plan import":
+		return importPlans(ctx, b, args)
+	case "plan list":
+		return listPlans(ctx, b)
+	case "plan export":
+		id, err := idArgument(args)
+		if err != nil {
+			return err
+		}
+		return exportPlan(ctx, b, id)
+	case "plan sync":
+		return syncPlan(ctx, b, args)
+	case "plan schema":
+		return printSchema(ctx, b)
+	case "today":
+		loc, err := b.Location(ctx)
+		if err != nil {
+			return err
+		}
+		today := dates.Today(loc)
+		return showSessions(ctx, b, today, today.AddDate(0, 0, 1))
+	case "week":
+		settings, err := b.Settings(ctx)
+		if err != nil {
+			return err
+		}
+		// The week starts on the same day as in the web calendar
+		start := settings.Locale(envLanguage()).StartOfWeek(dates.Today(settings.Location()))
+		return showSessions(ctx, b, start, start.AddDate(0, 0, 7))
+	case "complete":
+		return completeSession(ctx, b, args)
+	case "stats":
+		return showStats(ctx, b)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+}
+
+// envLanguage returns the language of the environment as a language tag,
+// such as "de-DE" for LANG=de_DE.UTF-8. Like the browser's language in the
+// web calendar, it picks the locale unless the settings choose one.
+func envLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			tag, _, _ := strings.Cut(value, ".")
+			return strings.ReplaceAll(tag, "_", "-")
+		}
+	}
+	return ""
+}
+
+// parseInterspersed parses flags that may come before, between or after
+// the positional arguments, which it returns.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// idArgument reads the single ID a command takes.
+func idArgument(args []string) (int64, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected one ID\n%s", usage)
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", args[0])
+	}
+	return id, nil
+}
+
+// importPlans creates the plans of one or more files, each of which may hold
+// several. Name and workout type default to the flags; the name also to the
+// file's. All files are read before any plan is created.
+func importPlans(ctx context.Context, b backend, args []string) error {
+	fs := flag.NewFlagSet("plan import", flag.ContinueOnError)
+	typeName := fs.String("type", "", "workout type, e.g. cycling, unless the file names one")
+	name := fs.String("name", "", "plan name, unless the file names one; defaults to the file name")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("expected a file\n%s", usage)
+	}
+
+	types, err := b.WorkoutTypes(ctx)
+	if err != nil {
+		return err
+	}
+	var defaults service.NewPlan
+	if *typeName != "" {
+		var known []string
+		for _, wt := range types {
+			if wt.Name == *typeName {
+				defaults.WorkoutTypeID = wt.ID
+			}
+			known = append(known, wt.Name)
+		}
+		if defaults.WorkoutTypeID == 0 {
+			return fmt.Errorf("unknown workout type %q, expected one of %s", *typeName, strings.Join(known, ", "))
+		}
+	}
+
+	loc, err := b.Location(ctx)
+	if err != nil {
+		return err
+	}
+	var plans []service.NewPlan
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		docs, err := service.ParsePlans(data, loc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		defaults.Name = *name
+		if defaults.Name == "" {
+			defaults.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		for _, doc := range docs {
+			plan, err := doc.Plan(types, defaults)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			if plan.WorkoutTypeID == 0 {
+				return fmt.Errorf("%s: no workout type in the file, give one with --type", file)
+			}
+			plans = append(plans, plan)
+		}
+	}
+
+	for _, plan := range plans {
+		detail, err := b.CreatePlan(ctx, plan)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created plan %d %q with %d sessions.\n", detail.ID, detail.Name, len(detail.Sessions))
+	}
+	return nil
+}
+
+// syncPlan shows how a plan differs from an updated version of its file and
+// with --apply updates it, keeping its completions.
+func syncPlan(ctx context.Context, b backend, args []string) error {
+	fs := flag.NewFlagSet("plan sync", flag.ContinueOnError)
+	apply := fs.Bool("apply", false, "make the changes instead of only showing them")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("expected a plan ID and a file\n%s", usage)
+	}
+	id, err := idArgument(positional[:1])
+	if err != nil {
+		return err
+	}
+	file := positional[1]
+
+	types, err := b.WorkoutTypes(ctx)
+	if err != nil {
+		return err
+	}
+	loc, err := b.Location(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	docs, err := service.ParsePlans(data, loc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if len(docs) != 1 {
+		return fmt.Errorf("%s: expected one plan, the file holds %d", file, len(docs))
+	}
+	plan, err := docs[0].Plan(types, service.NewPlan{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	sync, err := b.SyncPlan(ctx, id, plan, *apply)
+	if err != nil {
+		return err
+	}
+	if len(sync.Fields) == 0 && len(sync.Changes) == 0 {
+		fmt.Printf("Plan %d %q already matches %s.\n", sync.Before.ID, sync.Before.Name, file)
+		return nil
+	}
+	printSync(sync)
+	if !*apply {
+		fmt.Println("Nothing was changed; run again with --apply to make these changes.")
+	}
+	return nil
+}
+
+// syncSymbols mark the changes of a sync like a diff does.
+var syncSymbols = map[service.SyncAction]string{
+	service.SyncAdd:     "+",
+	service.SyncUpdate:  "~",
+	service.SyncDelete:  "-",
+	service.SyncArchive: "a",
+	service.SyncRestore: "r",
+}
+
+func printSync(sync service.PlanSync) {
+	fmt.Printf("Plan %d %q:\n", sync.Before.ID, sync.Before.Name)
+	plans := map[string][2]string{
+		"name":        {sync.Before.Name, sync.After.Name},
+		"description": {sync.Before.Description, sync.After.Description},
+		"source":      {sync.Before.Source, sync.After.Source},
+		"author":      {sync.Before.Author, sync.After.Author},
+	}
+	for _, field := range sync.Fields {
+		fmt.Printf("  %s: %q -> %q\n", field, plans[field][0], plans[field][1])
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range sync.Changes {
+		session := c.After
+		if session == nil {
+			session = c.Before
+		}
+		var notes []string
+		if len(c.Fields) > 0 {
+			notes = append(notes, strings.Join(c.Fields, ", "))
+		}
+		if c.Before != nil && c.Before.Completed {
+			notes = append(notes, "completed")
+		}
+		note := ""
+		if len(notes) > 0 {
+			note = "(" + strings.Join(notes, "; ") + ")"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", syncSymbols[c.Action], session.Date.Format(dates.Layout), session.Description, note)
+	}
+	w.Flush()
+
+	fmt.Printf("%d added, %d updated, %d deleted, %d archived, %d restored, %d unchanged.\n",
+		sync.Count(service.SyncAdd), sync.Count(service.SyncUpdate), sync.Count(service.SyncDelete), sync.Count(service.SyncArchive), sync.Count(service.SyncRestore), sync.Unchanged)
+}
+
+func listPlans(ctx context.Context, b backend) error {
+	plans, err := b.Plans(ctx)
+	if err != nil {
+		return err
+	}
+	types, err := b.WorkoutTypes(ctx)
+	if err != nil {
+		return err
+	}
+	typeNames := make(map[int64]string, len(types))
+	for _, wt := range types {
+		typeNames[wt.ID] = wt.Name
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tCREATED")
+	for _, p := range plans {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", p.ID, p.Name, typeNames[p.WorkoutTypeID], p.CreatedAt.Local().Format(dates.Layout))
+	}
+	return w.Flush()
+}
+
+func exportPlan(ctx context.Context, b backend, id int64) error {
+	detail, err := b.Plan(ctx, id)
+	if err != nil {
+		return err
+	}
+	data, err := service.MarshalPlan(detail)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// printSchema prints the JSON Schema of the import format, with the workout
+// types of the backend.
+func printSchema(ctx context.Context, b backend) error {
+	types, err := b.WorkoutTypes(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(service.PlanSchema(types), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(data, '\n'))
+	return err
+}
+
+// showSessions prints the sessions from the from day up to but excluding
+// the to day.
+func showSessions(ctx context.Context, b backend, from, to time.Time) error {
+	sessions, err := b.Sessions(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDATE\tTIME\tPLAN\tDESCRIPTION\tMIN\tDONE")
+	for _, s := range sessions {
+		startTime, minutes, done := "", "", ""
+		if s.StartTime != nil {
+			startTime = *s.StartTime
+		}
+		if s.Duration != nil {
+			minutes = strconv.Itoa(*s.Duration)
+		}
+		if s.Completed {
+			done = "✓"
+		}
+		fmt.Fprintf(w, "%d\t%s %s\t%s\t%s (%s)\t%s\t%s\t%s\n",
+			s.ID, s.Date.Weekday().String()[:3], s.Date.Format(dates.Layout), startTime,
+			s.PlanName, s.WorkoutType, s.Description, minutes, done)
+	}
+	return w.Flush()
+}
+
+func completeSession(ctx context.Context, b backend, args []string) error {
+	fs := flag.NewFlagSet("complete", flag.ContinueOnError)
+	duration := fs.Int("duration", 0, "minutes trained")
+	hr := fs.Int("hr", 0, "average heart rate in bpm")
+	rpe := fs.Int("rpe", 0, "rate of perceived exertion, 1-10")
+	distance := fs.Float64("distance", 0, "distance in km")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := idArgument(positional)
+	if err != nil {
+		return err
+	}
+
+	// Only the details given on the command line are recorded
+	completion := models.Completion{SessionID: id}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "duration":
+			completion.Duration = duration
+		case "hr":
+			completion.AvgHR = hr
+		case "rpe":
+			completion.RPE = rpe
+		case "distance":
+			completion.DistanceKm = distance
+		}
+	})
+
+	session, err := b.Complete(ctx, completion)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Completed session %d: %s (%s).\n", session.ID, session.Description, session.Date.Format(dates.Layout))
+	return nil
+}
+
+func showStats(ctx context.Context, b backend) error {
+	streaks, err := b.Streaks(ctx)
+	if err != nil {
+		return err
+	}
+	if len(streaks) == 0 {
+		fmt.Println("No sessions due yet.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PLAN\tTYPE\tCOMPLETED\tCURRENT STREAK\tLONGEST STREAK")
+	for _, s := range streaks {
+		fmt.Fprintf(w, "%s\t%s\t%d / %d\t%d\t%d\n", s.PlanName, s.WorkoutType, s.Completed, s.Total, s.Current, s.Longest)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// output runs a command and returns what it printed.
+func output(t *testing.T, b backend, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		printed <- string(data)
+	}()
+
+	err = run(context.Background(), b, args)
+	os.Stdout = stdout
+	w.Close()
+	return <-printed, err
+}
+
+// writeFile writes a file into a temporary directory and returns its path.
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		apply      bool
+		typeName   string
+		err        bool
+	}{
+		{name: "flags after", args: []string{"3", "plan.yaml", "--apply"}, positional: []string{"3", "plan.yaml"}, apply: true},
+		{name: "flags before", args: []string{"--apply", "3", "plan.yaml"}, positional: []string{"3", "plan.yaml"}, apply: true},
+		{name: "flags between", args: []string{"a.yaml", "--type", "cycling", "b.yaml"}, positional: []string{"a.yaml", "b.yaml"}, typeName: "cycling"},
+		{name: "flag with value", args: []string{"-type=core", "a.yaml"}, positional: []string{"a.yaml"}, typeName: "core"},
+		{name: "no arguments", args: nil},
+		{name: "after --", args: []string{"a.yaml", "--", "--apply"}, positional: []string{"a.yaml", "--apply"}},
+		{name: "unknown flag", args: []string{"a.yaml", "--force"}, err: true},
+		{name: "missing value", args: []string{"a.yaml", "--type"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			apply := fs.Bool("apply", false, "")
+			typeName := fs.String("type", "", "")
+
+			positional, err := parseInterspersed(fs, tt.args)
+			if tt.err {
+				if err == nil {
+					t.Errorf("got %v, want an error", positional)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(positional, tt.positional) || *apply != tt.apply || *typeName != tt.typeName {
+				t.Errorf("got %q, apply %v, type %q; want %q, %v, %q", positional, *apply, *typeName, tt.positional, tt.apply, tt.typeName)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	b, err := openLocal(filepath.Join(t.TempDir(), "training.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	file := writeFile(t, "base.yaml", "sessions:\n  - description: GA1 60min\n    date: 2025-03-03\n  - description: GA2\n    date: 2025-03-04\n")
+
+	tests := []struct {
+		name string
+		args []string
+		// Part of the output, or of the error if err is set
+		want string
+		err  bool
+	}{
+		{name: "unknown command", args: []string{"plans"}, want: `unknown command "plans"`, err: true},
+		{name: "plan without subcommand", args: []string{"plan"}, want: `unknown command "plan"`, err: true},
+		{name: "import without file", args: []string{"plan", "import", "--type", "cycling"}, want: "expected a file", err: true},
+		{name: "import with unknown type", args: []string{"plan", "import", file, "--type", "rowing"}, want: `unknown workout type "rowing"`, err: true},
+		{name: "import without type", args: []string{"plan", "import", file}, want: "no workout type in the file", err: true},
+		{name: "import", args: []string{"plan", "import", file, "--type", "cycling"}, want: `Created plan 1 "base" with 2 sessions.`},
+		{name: "list", args: []string{"plan", "list"}, want: "base  cycling"},
+		{name: "export without ID", args: []string{"plan", "export"}, want: "expected one ID", err: true},
+		{name: "export with invalid ID", args: []string{"plan", "export", "first"}, want: `invalid ID "first"`, err: true},
+		{name: "export", args: []string{"plan", "export", "1"}, want: "description: GA1 60min"},
+		{name: "sync without file", args: []string{"plan", "sync", "1"}, want: "expected a plan ID and a file", err: true},
+		{name: "complete with invalid RPE", args: []string{"complete", "1", "--rpe", "11"}, want: "Invalid RPE", err: true},
+		{name: "complete", args: []string{"complete", "--rpe", "6", "1"}, want: "Completed session 1: GA1 60min (2025-03-03)."},
+		{name: "stats", args: []string{"stats"}, want: "1 / 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := output(t, b, tt.args...)
+			if tt.err {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Errorf("got error %v, want one with %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want %q in it", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+// remote talks to a running server through its JSON API.
+type remote struct {
+	base   string
+	client *http.Client
+}
+
+func newRemote(base string) *remote {
+	return &remote{
+		base:   strings.TrimRight(base, "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (r *remote) Close() error {
+	return nil
+}
+
+// The API's representation of plans and sessions, with dates as
+// "2006-01-02".
+
+type remoteSession struct {
+	ID          int64  `json:"id,omitempty"`
+	PlanID      int64  `json:"plan_id,omitempty"`
+	PlanName    string `json:"plan_name,omitempty"`
+	WorkoutType string `json:"workout_type,omitempty"`
+	Order       *int   `json:"order,omitempty"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+	StartTime   string `json:"start_time,omitempty"`
+	Duration    int    `json:"duration_minutes,omitempty"`
+	Completed   bool   `json:"completed,omitempty"`
+	HFMax       string `json:"hfmax,omitempty"`
+	Key         string `json:"key,omitempty"`
+	Archived    bool   `json:"archived,omitempty"`
+}
+
+type remotePlan struct {
+	ID            int64           `json:"id,omitempty"`
+	Name          string          `json:"name"`
+	WorkoutTypeID int64           `json:"workout_type_id"`
+	WorkoutType   string          `json:"workout_type,omitempty"`
+	CreatedAt     time.Time       `json:"created_at,omitempty"`
+	Description   string          `json:"description,omitempty"`
+	Source        string          `json:"source,omitempty"`
+	Author        string          `json:"author,omitempty"`
+	Sessions      []remoteSession `json:"sessions,omitempty"`
+}
+
+func (s remoteSession) session() (models.TrainingSession, error) {
+	date, err := dates.Parse(s.Date)
+	if err != nil {
+		return models.TrainingSession{}, fmt.Errorf("server sent invalid date %q", s.Date)
+	}
+	session := models.TrainingSession{
+		ID:           s.ID,
+		PlanID:       s.PlanID,
+		SessionOrder: s.Order,
+		Description:  s.Description,
+		Date:         date,
+		Completed:    s.Completed,
+		HFMax:        s.HFMax,
+		Key:          s.Key,
+		Archived:     s.Archived,
+	}
+	if s.StartTime != "" {
+		session.StartTime = &s.StartTime
+	}
+	if s.Duration > 0 {
+		session.Duration = &s.Duration
+	}
+	return session, nil
+}
+
+func (p remotePlan) plan() models.TrainingPlan {
+	return models.TrainingPlan{
+		ID:            p.ID,
+		Name:          p.Name,
+		WorkoutTypeID: p.WorkoutTypeID,
+		CreatedAt:     p.CreatedAt,
+		Description:   p.Description,
+		Source:        p.Source,
+		Author:        p.Author,
+	}
+}
+
+// do sends a request with body encoded as JSON, unless it is nil, and
+// decodes the response into out. Errors of the API carry their message.
+func (r *remote) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s", apiErr.Error)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (r *remote) WorkoutTypes(ctx context.Context) ([]models.WorkoutType, error) {
+	var types []models.WorkoutType
+	err := r.do(ctx, "GET", "/api/workout-types", nil, &types)
+	return types, err
+}
+
+func (r *remote) Plans(ctx context.Context) ([]models.TrainingPlan, error) {
+	var list []remotePlan
+	if err := r.do(ctx, "GET", "/api/plans", nil, &list); err != nil {
+		return nil, err
+	}
+	plans := make([]models.TrainingPlan, 0, len(list))
+	for _, p := range list {
+		plans = append(plans, p.plan())
+	}
+	return plans, nil
+}
+
+// detail converts a plan with its sessions.
+func (p remotePlan) detail() (service.PlanDetail, error) {
+	detail := service.PlanDetail{TrainingPlan: p.plan(), WorkoutType: p.WorkoutType}
+	for _, s := range p.Sessions {
+		session, err := s.session()
+		if err != nil {
+			return detail, err
+		}
+		detail.Sessions = append(detail.Sessions, session)
+	}
+	return detail, nil
+}
+
+func (r *remote) Plan(ctx context.Context, id int64) (service.PlanDetail, error) {
+	var plan remotePlan
+	if err := r.do(ctx, "GET", fmt.Sprintf("/api/plans/%d", id), nil, &plan); err != nil {
+		return service.PlanDetail{}, err
+	}
+	return plan.detail()
+}
+
+// newRemotePlan converts a plan to create or sync with.
+func newRemotePlan(plan service.NewPlan) remotePlan {
+	input := remotePlan{
+		Name:          plan.Name,
+		WorkoutTypeID: plan.WorkoutTypeID,
+		Description:   plan.Description,
+		Source:        plan.Source,
+		Author:        plan.Author,
+	}
+	for _, s := range plan.Sessions {
+		input.Sessions = append(input.Sessions, remoteSession{
+			Key:         s.Key,
+			Order:       s.Order,
+			Description: s.Description,
+			Date:        s.Date.Format(dates.Layout),
+			StartTime:   s.StartTime,
+			Duration:    s.Duration,
+			HFMax:       s.HFMax,
+		})
+	}
+	return input
+}
+
+func (r *remote) CreatePlan(ctx context.Context, plan service.NewPlan) (service.PlanDetail, error) {
+	var created remotePlan
+	if err := r.do(ctx, "POST", "/api/plans", newRemotePlan(plan), &created); err != nil {
+		return service.PlanDetail{}, err
+	}
+	return created.detail()
+}
+
+func (r *remote) SyncPlan(ctx context.Context, id int64, plan service.NewPlan, apply bool) (service.PlanSync, error) {
+	path := fmt.Sprintf("/api/plans/%d/sync", id)
+	if !apply {
+		path += "?dry_run=1"
+	}
+	var result struct {
+		Before    remotePlan `json:"before"`
+		After     remotePlan `json:"after"`
+		Fields    []string   `json:"fields"`
+		Unchanged int        `json:"unchanged"`
+		Changes   []struct {
+			Action service.SyncAction `json:"action"`
+			Number int                `json:"number"`
+			Before *remoteSession     `json:"before"`
+			After  *remoteSession     `json:"after"`
+			Fields []string           `json:"fields"`
+		} `json:"changes"`
+	}
+	if err := r.do(ctx, "POST", path, newRemotePlan(plan), &result); err != nil {
+		return service.PlanSync{}, err
+	}
+
+	sync := service.PlanSync{
+		Before:      result.Before.plan(),
+		After:       result.After.plan(),
+		WorkoutType: result.Before.WorkoutType,
+		Fields:      result.Fields,
+		Unchanged:   result.Unchanged,
+	}
+	for _, c := range result.Changes {
+		change := service.SessionChange{Action: c.Action, Number: c.Number, Fields: c.Fields}
+		if c.Before != nil {
+			before, err := c.Before.session()
+			if err != nil {
+				return sync, err
+			}
+			change.Before = &before
+		}
+		if c.After != nil {
+			after, err := c.After.session()
+			if err != nil {
+				return sync, err
+			}
+			change.After = &after
+		}
+		sync.Changes = append(sync.Changes, change)
+	}
+	return sync, nil
+}
+
+func (r *remote) Sessions(ctx context.Context, from, to time.Time) ([]storage.ScheduledSession, error) {
+	query := url.Values{"from": {from.Format(dates.Layout)}, "to": {to.Format(dates.Layout)}}
+	var list []remoteSession
+	if err := r.do(ctx, "GET", "/api/sessions?"+query.Encode(), nil, &list); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]storage.ScheduledSession, 0, len(list))
+	for _, s := range list {
+		session, err := s.session()
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, storage.ScheduledSession{
+			TrainingSession: session,
+			PlanName:        s.PlanName,
+			WorkoutType:     s.WorkoutType,
+		})
+	}
+	return sessions, nil
+}
+
+func (r *remote) Complete(ctx context.Context, c models.Completion) (models.TrainingSession, error) {
+	// The server records the time of completion itself
+	input := map[string]interface{}{}
+	if c.Duration != nil {
+		input["duration_minutes"] = *c.Duration
+	}
+	if c.AvgHR != nil {
+		input["avg_hr"] = *c.AvgHR
+	}
+	if c.RPE != nil {
+		input["rpe"] = *c.RPE
+	}
+	if c.DistanceKm != nil {
+		input["distance_km"] = *c.DistanceKm
+	}
+
+	var session remoteSession
+	if err := r.do(ctx, "POST", fmt.Sprintf("/api/sessions/%d/complete", c.SessionID), input, &session); err != nil {
+		return models.TrainingSession{}, err
+	}
+	return session.session()
+}
+
+func (r *remote) Streaks(ctx context.Context) ([]service.PlanStreak, error) {
+	var streaks []service.PlanStreak
+	err := r.do(ctx, "GET", "/api/streaks", nil, &streaks)
+	return streaks, err
+}
+
+func (r *remote) Settings(ctx context.Context) (service.Settings, error) {
+	var settings struct {
+		TimeZone  string `json:"time_zone"`
+		Locale    string `json:"locale"`
+		WeekStart string `json:"week_start"`
+		RestingHR int    `json:"resting_hr"`
+		MaxHR     int    `json:"max_hr"`
+	}
+	if err := r.do(ctx, "GET", "/api/settings", nil, &settings); err != nil {
+		return service.Settings{}, err
+	}
+	return service.Settings{
+		RestingHR:  settings.RestingHR,
+		MaxHR:      settings.MaxHR,
+		TimeZone:   settings.TimeZone,
+		LocaleCode: settings.Locale,
+		WeekStart:  settings.WeekStart,
+	}, nil
+}
+
+// Location returns the zone configured on the server, the same that decides
+// which day today is in the web calendar.
+func (r *remote) Location(ctx context.Context) (*time.Location, error) {
+	settings, err := r.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return settings.Location(), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/config"
+	"training-tracker/internal/database"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/handlers"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+// The remote backend does what the local one does, through the API of a
+// server.
+func TestRemote(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewSQLite(db)
+	svc := service.New(store)
+	mux := http.NewServeMux()
+	handlers.RegisterRoutes(mux, svc, config.Features{API: true})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	b := newRemote(server.URL + "/")
+
+	loc, err := b.Location(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := dates.Today(loc)
+	yesterday := today.AddDate(0, 0, -1)
+	plan := func(second string) string {
+		return fmt.Sprintf("name: Base\nworkout_type: cycling\nsessions:\n  - description: GA1 60min\n    date: %s\n    time: \"07:30\"\n  - description: %s\n    date: %s\n",
+			yesterday.Format(dates.Layout), second, today.Format(dates.Layout))
+	}
+	file := writeFile(t, "base.yaml", plan("GA2"))
+	updated := writeFile(t, "updated.yaml", plan("GA2 90min"))
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"import", []string{"plan", "import", file}, `Created plan 1 "Base" with 2 sessions.`},
+		{"list", []string{"plan", "list"}, "Base  cycling"},
+		{"export", []string{"plan", "export", "1"}, "time: \"07:30\""},
+		{"today", []string{"today"}, "GA2"},
+		{"complete", []string{"complete", "1", "--duration", "55", "--rpe", "6"}, "Completed session 1: GA1 60min (" + yesterday.Format(dates.Layout) + ")."},
+		{"stats", []string{"stats"}, "1 / 2"},
+		{"sync without applying", []string{"plan", "sync", "1", updated}, "Nothing was changed"},
+		{"sync", []string{"plan", "sync", "1", updated, "--apply"}, "0 added, 1 updated"},
+		{"synced", []string{"plan", "sync", "1", updated}, `Plan 1 "Base" already matches`},
+		{"schema", []string{"plan", "schema"}, `"cycling"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := output(t, b, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want %q in it", got, tt.want)
+			}
+		})
+	}
+
+	detail, err := svc.Plan(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := detail.Sessions[0]; !first.Completed || first.StartTime == nil || *first.StartTime != "07:30" {
+		t.Errorf("got first session %+v, want it completed at 07:30", first)
+	}
+	if second := detail.Sessions[1]; second.Description != "GA2 90min" || second.Duration == nil || *second.Duration != 90 {
+		t.Errorf("got second session %+v, want it synced", second)
+	}
+	completion, err := store.Completions().Get(ctx, detail.Sessions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Duration == nil || *completion.Duration != 55 || completion.RPE == nil || *completion.RPE != 6 || completion.AvgHR != nil {
+		t.Errorf("got completion %+v, want only duration and RPE", completion)
+	}
+
+	// Errors of the API come with the server's message
+	if _, err := b.Complete(ctx, models.Completion{SessionID: 99}); err == nil || err.Error() != "Not found" {
+		t.Errorf("got %v, want the server's message", err)
+	}
+}
+
+// Errors of something other than the API, such as a proxy, name the
+// request.
+func TestRemoteError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := newRemote(server.URL).Plans(context.Background())
+	want := "GET /api/plans: 502 Bad Gateway: upstream unavailable"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
@@ -4,4 +4,6 @@ go 1.21
 
 require github.com/mattn/go-sqlite3 v1.14.24
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/lib/pq v1.10.9
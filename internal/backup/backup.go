@@ -0,0 +1,225 @@
+// Package backup takes consistent snapshots of the SQLite database while the
+// server runs, keeps a limited number of them, optionally pushes them to an
+// S3-compatible store, and restores them.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"training-tracker/internal/database"
+)
+
+// ErrUnsupported is returned for databases that are not backed up here.
+// PostgreSQL has its own tools for that, such as pg_dump.
+var ErrUnsupported = errors.New("snapshots are only supported for SQLite databases; back up PostgreSQL with pg_dump")
+
+const (
+	filePrefix = "training-"
+	fileSuffix = ".db"
+	timeLayout = "20060102-150405"
+	// Snapshots are named down to the microsecond so that two taken within
+	// a second do not collide. Parsing with timeLayout accepts the fraction
+	// as well as names without one.
+	nameLayout = timeLayout + ".000000"
+)
+
+// Manager takes snapshots of a database into a directory.
+type Manager struct {
+	DB      *sql.DB
+	Dialect database.Dialect
+	// Directory the snapshots are kept in
+	Dir string
+	// Number of snapshots to keep; zero keeps all
+	Keep int
+	// Optional target every snapshot is uploaded to
+	S3 *S3Target
+}
+
+// Snapshot is a backup file in the manager's directory.
+type Snapshot struct {
+	Name    string
+	Path    string
+	Size    int64
+	TakenAt time.Time
+}
+
+// vacuumInto writes a consistent copy of the database to path, which must
+// not exist yet. VACUUM INTO reads within a single transaction, so writes
+// of the running server do not end up half in the copy.
+func (m *Manager) vacuumInto(ctx context.Context, path string) error {
+	if m.Dialect != database.SQLite {
+		return ErrUnsupported
+	}
+	_, err := m.DB.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
+
+// Snapshot writes a new snapshot into the directory, uploads it if a target
+// is configured and removes the snapshots beyond the retention.
+func (m *Manager) Snapshot(ctx context.Context) (Snapshot, error) {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return Snapshot{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	name := filePrefix + now.Format(nameLayout) + fileSuffix
+	path := filepath.Join(m.Dir, name)
+	if err := m.vacuumInto(ctx, path); err != nil {
+		// Cancelling, e.g. on shutdown, leaves a partial file
+		if ctx.Err() != nil {
+			os.Remove(path)
+		}
+		return Snapshot{}, err
+	}
+	// The copy holds everything the database does, but VACUUM INTO creates
+	// it like any other file
+	if err := os.Chmod(path, 0o600); err != nil {
+		os.Remove(path)
+		return Snapshot{}, err
+	}
+	if err := Verify(path); err != nil {
+		os.Remove(path)
+		return Snapshot{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{Name: name, Path: path, Size: info.Size(), TakenAt: now}
+
+	if m.S3 != nil {
+		if err := m.S3.Upload(ctx, path); err != nil {
+			return snapshot, fmt.Errorf("uploading %s: %w", name, err)
+		}
+	}
+
+	if _, err := m.Prune(); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// List returns the snapshots in the directory, newest first.
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		takenAt, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{
+			Name:    name,
+			Path:    filepath.Join(m.Dir, name),
+			Size:    info.Size(),
+			TakenAt: takenAt,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].TakenAt.After(snapshots[j].TakenAt)
+	})
+	return snapshots, nil
+}
+
+// Prune removes the oldest snapshots beyond the retention and returns them.
+func (m *Manager) Prune() ([]Snapshot, error) {
+	if m.Keep <= 0 {
+		return nil, nil
+	}
+	snapshots, err := m.List()
+	if err != nil || len(snapshots) <= m.Keep {
+		return nil, err
+	}
+
+	removed := snapshots[m.Keep:]
+	for _, s := range removed {
+		if err := os.Remove(s.Path); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// Run takes a snapshot every interval until ctx is done. Failures are
+// logged and retried at the next interval.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot, err := m.Snapshot(ctx)
+			if err != nil {
+				slog.Error("backup failed", "dir", m.Dir, "error", err)
+				continue
+			}
+			slog.Info("backup written", "path", snapshot.Path, "size", snapshot.Size)
+		}
+	}
+}
+
+// tempFile is a snapshot outside the directory that is removed on Close.
+type tempFile struct {
+	*os.File
+	dir string
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.RemoveAll(f.dir); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Open writes a fresh snapshot to a temporary file, without keeping it in
+// the directory, and opens it for reading. Closing it removes the file.
+func (m *Manager) Open(ctx context.Context) (io.ReadSeekCloser, error) {
+	dir, err := os.MkdirTemp("", "training-backup-")
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "training.db")
+	if err := m.vacuumInto(ctx, path); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &tempFile{File: f, dir: dir}, nil
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"training-tracker/internal/database"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "training.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{DB: db, Dialect: database.SQLite, Dir: filepath.Join(dir, "backups"), Keep: 1}
+	snapshot, err := m.Snapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(snapshot.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("snapshot is readable with mode %v, want %v", mode, os.FileMode(0o600))
+	}
+	if err := Verify(snapshot.Path); err != nil {
+		t.Error(err)
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name != snapshot.Name {
+		t.Errorf("listed %+v, want %s", snapshots, snapshot.Name)
+	}
+
+	if _, err := (&Manager{DB: db, Dialect: database.Postgres, Dir: dir}).Snapshot(context.Background()); err != ErrUnsupported {
+		t.Errorf("got %v for PostgreSQL, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "training.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manager{DB: db, Dialect: database.SQLite, Dir: filepath.Join(dir, "backups")}
+	f, err := m.Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := f.(*tempFile).Name()
+	if err := Verify(path); err != nil {
+		t.Error(err)
+	}
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != "SQLite format 3\x00" {
+		t.Errorf("read %q, %v, want the SQLite header", header, err)
+	}
+
+	// The snapshot goes away with the download and never was in the directory
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("temporary snapshot left behind: %v", err)
+	}
+	if snapshots, err := m.List(); err != nil || len(snapshots) != 0 {
+		t.Errorf("listed %v, %v, want no snapshots", snapshots, err)
+	}
+
+	if _, err := (&Manager{DB: db, Dialect: database.Postgres}).Open(context.Background()); err != ErrUnsupported {
+		t.Errorf("got %v for PostgreSQL, want %v", err, ErrUnsupported)
+	}
+}
+
+func TestSnapshotsWithinASecond(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "training.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots of earlier releases are named to the second
+	m := &Manager{DB: db, Dialect: database.SQLite, Dir: filepath.Join(dir, "backups")}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(m.Dir, "training-20250301-120000.db"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for i := 0; i < 3; i++ {
+		snapshot, err := m.Snapshot(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, snapshot.Name)
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, s := range snapshots {
+		listed = append(listed, s.Name)
+	}
+	want := []string{names[2], names[1], names[0], "training-20250301-120000.db"}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("listed %v, want %v", listed, want)
+	}
+	if got := snapshots[3].TakenAt; !got.Equal(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("old snapshot taken at %v", got)
+	}
+}
@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// requiredTables must exist in every snapshot of a training database.
+var requiredTables = []string{"workout_types", "training_plans", "training_sessions", "settings"}
+
+// Verify checks that the file at path is an intact SQLite database with the
+// tables of the training tracker.
+func Verify(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	// Characters like "?" and "#" are legal in file names but not in the
+	// path of a URI
+	uri := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	db, err := sql.Open("sqlite3", uri.String())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("%s is not a readable SQLite database: %w", path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%s failed the integrity check: %s", path, result)
+	}
+
+	for _, table := range requiredTables {
+		var name string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%s is not a training database: table %s is missing", path, table)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the database at target with the snapshot at path after
+// verifying it. The server must not be running. An existing database is
+// kept next to the target with a ".before-restore-" suffix; that path is
+// returned.
+func Restore(path, target string) (string, error) {
+	if err := Verify(path); err != nil {
+		return "", err
+	}
+
+	// Copy next to the target first so the final rename cannot leave a
+	// half-written database behind
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".restore-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	src, err := os.Open(path)
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	_, err = io.Copy(tmp, src)
+	src.Close()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var previous string
+	if _, err := os.Stat(target); err == nil {
+		previous = target + ".before-restore-" + time.Now().UTC().Format(timeLayout)
+		if err := os.Rename(target, previous); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return previous, err
+	}
+	return previous, nil
+}
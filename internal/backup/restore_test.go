@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/database"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "training.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name string, contents func(path string)) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		contents(path)
+		return path
+	}
+	snapshot := func(path string) {
+		if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		err  string
+	}{
+		{"snapshot", write("backups/training.db", snapshot), ""},
+		{"URI characters in the path", write("backups?mode=rwc#1/training 100%.db", snapshot), ""},
+		{"missing", filepath.Join(dir, "missing.db"), "no such file"},
+		{"not a database", write("notes.db", func(path string) {
+			if err := os.WriteFile(path, []byte(strings.Repeat("notes ", 200)), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}), "not a readable SQLite database"},
+		{"other database", write("other.db", func(path string) {
+			other, err := sql.Open("sqlite3", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer other.Close()
+			if _, err := other.Exec("CREATE TABLE notes (text TEXT)"); err != nil {
+				t.Fatal(err)
+			}
+		}), "table workout_types is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.path)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("got %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got %v, want an error about %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Target is a bucket on an S3-compatible store such as AWS S3 or MinIO.
+// Objects are addressed path-style, which every such store supports.
+type S3Target struct {
+	// Base URL of the store, e.g. http://localhost:9000
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// Optional key prefix, e.g. "training/"
+	Prefix string
+
+	Client *http.Client
+}
+
+// S3FromEnv returns the target configured by the BACKUP_S3_* environment
+// variables, or nil if no bucket is set. Credentials are read from the
+// environment rather than flags, which show up in the process list.
+func S3FromEnv() *S3Target {
+	bucket := os.Getenv("BACKUP_S3_BUCKET")
+	if bucket == "" {
+		return nil
+	}
+	return &S3Target{
+		Endpoint:  os.Getenv("BACKUP_S3_ENDPOINT"),
+		Bucket:    bucket,
+		Region:    os.Getenv("BACKUP_S3_REGION"),
+		AccessKey: os.Getenv("BACKUP_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("BACKUP_S3_SECRET_KEY"),
+		Prefix:    os.Getenv("BACKUP_S3_PREFIX"),
+	}
+}
+
+// Upload puts the file at path into the bucket under its base name.
+func (t *S3Target) Upload(ctx context.Context, filePath string) error {
+	body, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(t.Prefix, filepath.Base(filePath))
+	url := strings.TrimRight(t.Endpoint, "/") + "/" + t.Bucket + "/" + key
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.sqlite3")
+	t.sign(req, body, time.Now().UTC())
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign adds an AWS Signature Version 4 to req, covering the host and every
+// header already set on the request.
+func (t *S3Target) sign(req *http.Request, body []byte, now time.Time) {
+	region := t.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+t.SecretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.AccessKey, scope, signedHeaders, signature))
+}
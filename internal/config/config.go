@@ -0,0 +1,330 @@
+// Package config assembles the server's configuration from defaults, a YAML
+// file, environment variables and command-line flags, in increasing order of
+// precedence.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"training-tracker/internal/database"
+)
+
+// envPrefix starts the environment variable of every option: -backup-keep
+// is read from TRAINING_BACKUP_KEEP.
+const envPrefix = "TRAINING_"
+
+// Config holds every setting of the server.
+type Config struct {
+	// Address to listen on, e.g. ":8080" or "127.0.0.1:8080", or a Unix
+	// socket as "unix:/run/training/server.sock"
+	Listen string `yaml:"listen"`
+	// Permissions of a Unix socket, e.g. "0660" for a proxy in the group
+	SocketMode string `yaml:"socket_mode"`
+	// SQLite database file or postgres:// URL
+	DB string `yaml:"db"`
+	// Path prefix the server is reachable under behind a reverse proxy,
+	// e.g. "/training"; empty for the root
+	BasePath string `yaml:"base_path"`
+	// Directory relative paths of the database and backups are resolved in
+	DataDir string `yaml:"data_dir"`
+	// One of debug, info, warn and error
+	LogLevel string `yaml:"log_level"`
+
+	// Serve templates and static files from disk instead of the binary,
+	// parsing templates again on every request
+	Dev         bool   `yaml:"dev"`
+	TemplateDir string `yaml:"template_dir"`
+	StaticDir   string `yaml:"static_dir"`
+
+	HTTP struct {
+		ReadTimeout  time.Duration `yaml:"read_timeout"`
+		WriteTimeout time.Duration `yaml:"write_timeout"`
+		IdleTimeout  time.Duration `yaml:"idle_timeout"`
+		// Time in-flight requests get to finish on SIGINT or SIGTERM
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	} `yaml:"http"`
+
+	// Serve HTTPS with these PEM files if both are set
+	TLS struct {
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+	} `yaml:"tls"`
+
+	Backup struct {
+		Dir string `yaml:"dir"`
+		// Time between scheduled snapshots; zero disables them
+		Interval time.Duration `yaml:"interval"`
+		// Number of scheduled snapshots to keep; zero keeps all
+		Keep int `yaml:"keep"`
+	} `yaml:"backup"`
+
+	// Required to download backups and store durations; without it both
+	// are off
+	AdminToken string `yaml:"admin_token"`
+	// Header in which a trusted reverse proxy passes the authenticated user,
+	// e.g. "X-Forwarded-User", who the history of plans names for changes.
+	// Without it changes are put down to the client's address.
+	UserHeader string `yaml:"user_header"`
+
+	Features Features `yaml:"features"`
+}
+
+// Features switches optional parts of the server on and off.
+type Features struct {
+	// JSON API for scripts and the command-line client
+	API bool `yaml:"api"`
+	// Analytics page with its charts and data
+	Analytics bool `yaml:"analytics"`
+	// Calendar subscription in iCalendar format
+	ICS bool `yaml:"ics"`
+	// Admin pages: duration backfill and backups
+	Admin bool `yaml:"admin"`
+	// Prometheus metrics at /metrics
+	Metrics bool `yaml:"metrics"`
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() Config {
+	cfg := Config{
+		Listen:      ":8080",
+		SocketMode:  "0660",
+		DB:          "training.db",
+		DataDir:     ".",
+		LogLevel:    "info",
+		TemplateDir: "internal/templates",
+		StaticDir:   "internal/static",
+		Features:    Features{API: true, Analytics: true, ICS: true, Admin: true, Metrics: true},
+	}
+	cfg.HTTP.ReadTimeout = 15 * time.Second
+	cfg.HTTP.WriteTimeout = time.Minute
+	cfg.HTTP.IdleTimeout = 2 * time.Minute
+	cfg.HTTP.ShutdownTimeout = 30 * time.Second
+	cfg.Backup.Dir = "backups"
+	cfg.Backup.Interval = 24 * time.Hour
+	cfg.Backup.Keep = 7
+	return cfg
+}
+
+// flagSet binds a flag to every option of cfg.
+func flagSet(cfg *Config, configFile *string, printConfig *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(configFile, "config", "", "YAML configuration file (env TRAINING_CONFIG)")
+	fs.BoolVar(printConfig, "print-config", false, "print the effective configuration and exit")
+
+	fs.StringVar(&cfg.Listen, "listen", cfg.Listen, "address to listen on, or unix:PATH for a Unix socket")
+	fs.StringVar(&cfg.SocketMode, "socket-mode", cfg.SocketMode, "permissions of a Unix socket, in octal")
+	fs.DurationVar(&cfg.HTTP.ReadTimeout, "read-timeout", cfg.HTTP.ReadTimeout, "time to read a whole request")
+	fs.DurationVar(&cfg.HTTP.WriteTimeout, "write-timeout", cfg.HTTP.WriteTimeout, "time to handle a request and write the response")
+	fs.DurationVar(&cfg.HTTP.IdleTimeout, "idle-timeout", cfg.HTTP.IdleTimeout, "time an idle keep-alive connection stays open")
+	fs.DurationVar(&cfg.HTTP.ShutdownTimeout, "shutdown-timeout", cfg.HTTP.ShutdownTimeout, "time in-flight requests get to finish when stopping")
+	fs.StringVar(&cfg.TLS.CertFile, "tls-cert", cfg.TLS.CertFile, "PEM certificate file, to serve HTTPS")
+	fs.StringVar(&cfg.TLS.KeyFile, "tls-key", cfg.TLS.KeyFile, "PEM private key file, to serve HTTPS")
+	fs.StringVar(&cfg.DB, "db", cfg.DB, "SQLite database file or postgres:// URL")
+	fs.StringVar(&cfg.BasePath, "base-path", cfg.BasePath, "path prefix behind a reverse proxy, e.g. /training")
+	fs.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "directory relative database and backup paths are resolved in")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "debug, info, warn or error")
+	fs.BoolVar(&cfg.Dev, "dev", cfg.Dev, "serve templates and static files from disk, reloading templates on every request")
+	fs.StringVar(&cfg.TemplateDir, "template-dir", cfg.TemplateDir, "directory of the page templates in -dev mode")
+	fs.StringVar(&cfg.StaticDir, "static-dir", cfg.StaticDir, "directory of the static files in -dev mode")
+	fs.StringVar(&cfg.Backup.Dir, "backup-dir", cfg.Backup.Dir, "directory for scheduled snapshots of a SQLite database")
+	fs.DurationVar(&cfg.Backup.Interval, "backup-interval", cfg.Backup.Interval, "time between scheduled snapshots, 0 to disable them")
+	fs.IntVar(&cfg.Backup.Keep, "backup-keep", cfg.Backup.Keep, "number of scheduled snapshots to keep, 0 to keep all")
+	fs.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "token required to download backups and store durations, which are off without one; prefer the environment")
+	fs.StringVar(&cfg.UserHeader, "user-header", cfg.UserHeader, "header a trusted proxy passes the authenticated user in")
+	fs.BoolVar(&cfg.Features.API, "feature-api", cfg.Features.API, "serve the JSON API")
+	fs.BoolVar(&cfg.Features.Analytics, "feature-analytics", cfg.Features.Analytics, "serve the analytics page")
+	fs.BoolVar(&cfg.Features.ICS, "feature-ics", cfg.Features.ICS, "serve the calendar subscription")
+	fs.BoolVar(&cfg.Features.Admin, "feature-admin", cfg.Features.Admin, "serve the admin pages")
+	fs.BoolVar(&cfg.Features.Metrics, "feature-metrics", cfg.Features.Metrics, "serve Prometheus metrics at /metrics")
+	return fs
+}
+
+// EnvName returns the environment variable of the option with the given
+// flag name.
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Load builds the configuration from args, the environment and the file
+// given by -config or TRAINING_CONFIG. If -print-config is set it writes
+// the configuration to out and returns ErrPrinted.
+func Load(args []string, out io.Writer) (Config, error) {
+	cfg := Default()
+	var (
+		configFile  string
+		printConfig bool
+	)
+	fs := flagSet(&cfg, &configFile, &printConfig)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	// Flags win over everything else, so remember them and apply them again
+	// once the file and the environment are read
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	cfg = Default()
+
+	if configFile == "" {
+		configFile = os.Getenv(EnvName("config"))
+	}
+	if configFile != "" {
+		if err := readFile(&cfg, configFile); err != nil {
+			return cfg, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		if value, ok := os.LookupEnv(EnvName(f.Name)); ok && err == nil {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("%s: %w", EnvName(f.Name), setErr)
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	for name, value := range given {
+		if err := fs.Set(name, value); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.normalize(); err != nil {
+		return cfg, err
+	}
+
+	if printConfig {
+		if err := cfg.Print(out); err != nil {
+			return cfg, err
+		}
+		return cfg, ErrPrinted
+	}
+	return cfg, nil
+}
+
+// ErrPrinted tells the caller that the configuration was printed on request
+// and the server should not start.
+var ErrPrinted = errors.New("configuration printed")
+
+// readFile reads a YAML configuration file over cfg. Unknown keys are an
+// error so that typos do not go unnoticed.
+func readFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// normalize validates the configuration and resolves relative paths of the
+// database and backups in the data directory.
+func (cfg *Config) normalize() error {
+	if _, err := cfg.Level(); err != nil {
+		return err
+	}
+	if cfg.Backup.Interval < 0 || cfg.Backup.Keep < 0 {
+		return errors.New("backup interval and keep must not be negative")
+	}
+	if cfg.HTTP.ReadTimeout < 0 || cfg.HTTP.WriteTimeout < 0 || cfg.HTTP.IdleTimeout < 0 || cfg.HTTP.ShutdownTimeout < 0 {
+		return errors.New("timeouts must not be negative")
+	}
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return errors.New("TLS needs both a certificate and a key file")
+	}
+	if _, err := cfg.Socket(); err != nil {
+		return err
+	}
+
+	cfg.BasePath = strings.TrimRight(cfg.BasePath, "/")
+	if cfg.BasePath != "" && !strings.HasPrefix(cfg.BasePath, "/") {
+		cfg.BasePath = "/" + cfg.BasePath
+	}
+
+	// URIs such as file:training.db?cache=shared are taken as they are
+	if database.DialectOf(cfg.DB) == database.SQLite && !strings.HasPrefix(cfg.DB, "file:") &&
+		cfg.DB != ":memory:" && !filepath.IsAbs(cfg.DB) {
+		cfg.DB = filepath.Join(cfg.DataDir, cfg.DB)
+	}
+	if !filepath.IsAbs(cfg.Backup.Dir) {
+		cfg.Backup.Dir = filepath.Join(cfg.DataDir, cfg.Backup.Dir)
+	}
+	return nil
+}
+
+// Socket returns the permissions of a Unix socket to listen on.
+func (cfg Config) Socket() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(cfg.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q, expected octal permissions such as 0660", cfg.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// Level returns the configured log level.
+func (cfg Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return level, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", cfg.LogLevel)
+	}
+	return level, nil
+}
+
+// Print writes the configuration as YAML, in the format of the file. The
+// admin token and the password of a database URL are masked.
+func (cfg Config) Print(w io.Writer) error {
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = "********"
+	}
+	cfg.DB = maskPassword(cfg.DB)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// maskPassword hides the password of a postgres:// URL.
+func maskPassword(dsn string) string {
+	if database.DialectOf(dsn) != database.Postgres {
+		return dsn
+	}
+	scheme, rest, _ := strings.Cut(dsn, "://")
+	userinfo, host, ok := strings.Cut(rest, "@")
+	if !ok {
+		return dsn
+	}
+	user, _, hasPassword := strings.Cut(userinfo, ":")
+	if !hasPassword {
+		return dsn
+	}
+	return scheme + "://" + user + ":********@" + host
+}
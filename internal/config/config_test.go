@@ -0,0 +1,110 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "training.yaml")
+	if err := os.WriteFile(file, []byte("listen: :7000\nlog_level: warn\nbackup:\n  keep: 3\ndata_dir: /var/lib/training\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		listen   string
+		logLevel string
+		keep     int
+		db       string
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want want
+	}{
+		{"defaults", nil, nil, want{":8080", "info", 7, "training.db"}},
+		{"file", nil, []string{"-config", file}, want{":7000", "warn", 3, "/var/lib/training/training.db"}},
+		{"file from the environment", map[string]string{"TRAINING_CONFIG": file}, nil, want{":7000", "warn", 3, "/var/lib/training/training.db"}},
+		{"environment over file", map[string]string{"TRAINING_LISTEN": ":7100", "TRAINING_BACKUP_KEEP": "5"}, []string{"-config", file},
+			want{":7100", "warn", 5, "/var/lib/training/training.db"}},
+		{"flags over environment", map[string]string{"TRAINING_LISTEN": ":7100", "TRAINING_LOG_LEVEL": "debug"}, []string{"-config", file, "-listen", ":7200"},
+			want{":7200", "debug", 3, "/var/lib/training/training.db"}},
+		{"flags over file", nil, []string{"-backup-keep", "0", "-config", file, "-data-dir", "/srv"}, want{":7000", "warn", 0, "/srv/training.db"}},
+		{"flag set to the default still wins", map[string]string{"TRAINING_LISTEN": ":7100"}, []string{"-listen", ":8080"}, want{":8080", "info", 7, "training.db"}},
+		{"absolute database", map[string]string{"TRAINING_DB": "/data/t.db"}, []string{"-config", file}, want{":7000", "warn", 3, "/data/t.db"}},
+		{"database URL", nil, []string{"-config", file, "-db", "postgres://u@db/training"}, want{":7000", "warn", 3, "postgres://u@db/training"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Load(tt.args, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := want{cfg.Listen, cfg.LogLevel, cfg.Backup.Keep, cfg.DB}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typo.yaml")
+	if err := os.WriteFile(typo, []byte("lisen: :7000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown key in the file", nil, []string{"-config", typo}, "field lisen not found"},
+		{"missing file", nil, []string{"-config", filepath.Join(dir, "missing.yaml")}, "no such file"},
+		{"invalid environment value", map[string]string{"TRAINING_BACKUP_KEEP": "many"}, nil, "TRAINING_BACKUP_KEEP"},
+		{"invalid log level", nil, []string{"-log-level", "loud"}, "invalid log level"},
+		{"negative keep", nil, []string{"-backup-keep", "-1"}, "must not be negative"},
+		{"certificate without key", nil, []string{"-tls-cert", "cert.pem"}, "both a certificate and a key"},
+		{"invalid socket mode", nil, []string{"-socket-mode", "0999"}, "invalid socket mode"},
+		{"positional argument", nil, []string{"serve"}, "unexpected argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := Load(tt.args, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	var out bytes.Buffer
+	_, err := Load([]string{"-print-config", "-admin-token", "s3cret", "-db", "postgres://user:pa55@db/training", "-base-path", "training/"}, &out)
+	if err != ErrPrinted {
+		t.Fatalf("got %v, want %v", err, ErrPrinted)
+	}
+	printed := out.String()
+	for _, secret := range []string{"s3cret", "pa55"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("printed configuration contains %q:\n%s", secret, printed)
+		}
+	}
+	for _, line := range []string{"admin_token: '********'", "db: postgres://user:********@db/training", "base_path: /training"} {
+		if !strings.Contains(printed, line) {
+			t.Errorf("printed configuration lacks %q:\n%s", line, printed)
+		}
+	}
+}
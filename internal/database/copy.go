@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// copyTables lists every table with its columns, parents before children.
+var copyTables = []struct {
+	name    string
+	columns []string
+	// Has an id column backed by a sequence in PostgreSQL
+	serial bool
+}{
+	{"workout_types", []string{"id", "name"}, true},
+	{"training_plans", []string{"id", "name", "workout_type_id", "created_at", "description", "source", "author"}, true},
+	{"training_sessions", []string{"id", "plan_id", "session_order", "description", "date", "completed", "duration_minutes", "start_time", "sync_key", "archived"}, true},
+	{"cycling_sessions", []string{"session_id", "hfmax"}, false},
+	{"mobility_sessions", []string{"session_id"}, false},
+	{"sandbag_sessions", []string{"session_id"}, false},
+	{"core_sessions", []string{"session_id"}, false},
+	{"session_completions", []string{"session_id", "completed_at", "duration_minutes", "avg_hr", "rpe", "distance_km"}, false},
+	{"plan_history", []string{"id", "plan_id", "session_id", "action", "actor", "created_at", "snapshot_before", "snapshot_after"}, true},
+	{"settings", []string{"key", "value"}, false},
+}
+
+// placeholders returns n parameter placeholders for the dialect.
+func placeholders(dialect Dialect, n int) string {
+	list := make([]string, n)
+	for i := range list {
+		if dialect == Postgres {
+			list[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			list[i] = "?"
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// Copy copies all data from src into dst, keeping every ID. Both must have
+// their tables created, and dst must not contain any plans or settings yet;
+// its default workout types are replaced by those of src. It returns the
+// number of rows copied per table.
+func Copy(ctx context.Context, src *sql.DB, dst *sql.DB, dstDialect Dialect) (map[string]int, error) {
+	// The target enforces foreign keys, so broken rows would fail halfway
+	problems, err := Doctor(ctx, src, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("source database has %s; fix it with the doctor command first", problems[0].Description)
+	}
+
+	for _, table := range []string{"training_plans", "training_sessions", "settings"} {
+		var n int
+		if err := dst.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return nil, fmt.Errorf("target database is not empty: %s has %d rows", table, n)
+		}
+	}
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM workout_types"); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, table := range copyTables {
+		n, err := copyTable(ctx, src, tx, dstDialect, table.name, table.columns)
+		if err != nil {
+			return nil, fmt.Errorf("copying %s: %w", table.name, err)
+		}
+		counts[table.name] = n
+
+		// Continue the sequences after the copied IDs
+		if dstDialect == Postgres && table.serial {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(
+				"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false)",
+				table.name))
+			if err != nil {
+				return nil, fmt.Errorf("resetting the sequence of %s: %w", table.name, err)
+			}
+		}
+	}
+
+	return counts, tx.Commit()
+}
+
+// copyTable copies the given columns of every row of a table.
+func copyTable(ctx context.Context, src *sql.DB, dst *sql.Tx, dstDialect Dialect, table string, columns []string) (int, error) {
+	list := strings.Join(columns, ", ")
+	rows, err := src.QueryContext(ctx, "SELECT "+list+" FROM "+table)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	insert, err := dst.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, list, placeholders(dstDialect, len(columns))))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		// Session dates are stored as plain calendar dates
+		for i, column := range columns {
+			if t, ok := values[i].(time.Time); ok && column == "date" {
+				values[i] = t.Format("2006-01-02")
+			}
+		}
+		if _, err := insert.ExecContext(ctx, values...); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, rows.Err()
+}
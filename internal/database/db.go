@@ -2,72 +2,283 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// Dialect identifies the kind of database behind a connection.
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite3"
+	Postgres Dialect = "postgres"
+)
+
+// DialectOf returns the dialect of a DSN. postgres:// and postgresql:// URLs
+// select PostgreSQL, anything else is the path of a SQLite database.
+func DialectOf(dsn string) Dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return Postgres
+	}
+	return SQLite
+}
+
+// Open connects to the database a DSN points at.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	dialect := DialectOf(dsn)
+	if dialect == Postgres {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, dialect, err
+		}
+		// sql.Open does not connect; fail early on a wrong DSN
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, dialect, err
+		}
+		return db, dialect, nil
+	}
+
+	db, err := InitDB(dsn)
+	return db, dialect, err
+}
+
+// InitDB opens the SQLite database at filepath. ":memory:" gives a fresh
+// in-memory database, for example for tests. SQLite only enforces foreign
+// keys on connections that ask for it, so every connection does.
 func InitDB(filepath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", filepath)
+	dsn := filepath + "?_foreign_keys=on"
+	if strings.Contains(filepath, "?") {
+		dsn = filepath + "&_foreign_keys=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	// Every connection would get its own in-memory database
+	if filepath == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
 	return db, nil
 }
 
-func CreateTables(db *sql.DB) error {
-	tables := `
-	CREATE TABLE IF NOT EXISTS workout_types (
+// sqliteTables holds the definition of every table, parents before
+// children. ON DELETE must match foreignKeys, which older databases are
+// rebuilt to.
+var sqliteTables = []struct {
+	name       string
+	definition string
+}{
+	{"workout_types", `
 		id INTEGER PRIMARY KEY,
-		name TEXT NOT NULL UNIQUE
-	);
-
-	CREATE TABLE IF NOT EXISTS training_plans (
+		name TEXT NOT NULL UNIQUE`},
+	{"training_plans", `
 		id INTEGER PRIMARY KEY,
 		name TEXT NOT NULL,
 		workout_type_id INTEGER,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (workout_type_id) REFERENCES workout_types(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS training_sessions (
+		description TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT '',
+		author TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (workout_type_id) REFERENCES workout_types(id) ON DELETE RESTRICT`},
+	{"training_sessions", `
 		id INTEGER PRIMARY KEY,
 		plan_id INTEGER,
 		session_order INTEGER,
 		description TEXT,
-		date TIMESTAMP NOT NULL,
+		date DATE NOT NULL,
 		completed BOOLEAN DEFAULT 0,
-		FOREIGN KEY (plan_id) REFERENCES training_plans(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS cycling_sessions (
+		duration_minutes INTEGER,
+		start_time TEXT,
+		sync_key TEXT,
+		archived BOOLEAN NOT NULL DEFAULT 0,
+		FOREIGN KEY (plan_id) REFERENCES training_plans(id) ON DELETE CASCADE`},
+	{"cycling_sessions", `
 		session_id INTEGER PRIMARY KEY,
 		hfmax TEXT,
-		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS mobility_sessions (
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id) ON DELETE CASCADE`},
+	{"mobility_sessions", `
 		session_id INTEGER PRIMARY KEY,
-		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS sandbag_sessions (
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id) ON DELETE CASCADE`},
+	{"sandbag_sessions", `
 		session_id INTEGER PRIMARY KEY,
-		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
-	);
-
-	CREATE TABLE IF NOT EXISTS core_sessions (
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id) ON DELETE CASCADE`},
+	{"core_sessions", `
+		session_id INTEGER PRIMARY KEY,
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id) ON DELETE CASCADE`},
+	{"session_completions", `
 		session_id INTEGER PRIMARY KEY,
-		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
-	);
+		completed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		duration_minutes INTEGER,
+		avg_hr INTEGER,
+		rpe INTEGER,
+		distance_km REAL,
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id) ON DELETE CASCADE`},
+	{"plan_history", `
+		id INTEGER PRIMARY KEY,
+		plan_id INTEGER NOT NULL,
+		session_id INTEGER,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		snapshot_before TEXT,
+		snapshot_after TEXT,
+		FOREIGN KEY (plan_id) REFERENCES training_plans(id) ON DELETE CASCADE`},
+	{"settings", `
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL`},
+}
+
+// sqliteIndexes are created after the tables, and again after a table was
+// rebuilt since dropping a table drops its indexes.
+const sqliteIndexes = `
+	-- The calendar and analytics select sessions by date. Streaks walk every
+	-- plan's sessions in order, which this index covers without a sort.
+	CREATE INDEX IF NOT EXISTS idx_training_sessions_date ON training_sessions(date);
+	CREATE INDEX IF NOT EXISTS idx_training_sessions_plan ON training_sessions(plan_id, date, session_order, completed);
+	CREATE INDEX IF NOT EXISTS idx_plan_history_plan ON plan_history(plan_id, id);
+`
+
+// CreateTables creates all tables of the given dialect that do not exist yet
+// and migrates older schemas.
+func CreateTables(db *sql.DB, dialect Dialect) error {
+	if dialect == Postgres {
+		return createPostgresTables(db)
+	}
 
+	var tables strings.Builder
+	for _, table := range sqliteTables {
+		fmt.Fprintf(&tables, "CREATE TABLE IF NOT EXISTS %s (%s\n);\n", table.name, table.definition)
+	}
+	tables.WriteString(sqliteIndexes)
+	tables.WriteString(`
 	-- Insert default workout types if they don't exist
 	INSERT OR IGNORE INTO workout_types (name) VALUES 
 		('cycling'),
 		('mobility'),
 		('sandbag'),
 		('core');
-	`
+	`)
+
+	if _, err := db.Exec(tables.String()); err != nil {
+		return err
+	}
+
+	return migrate(db)
+}
+
+// migrate brings databases created by older versions up to the current
+// schema. Every step must be safe to run repeatedly.
+func migrate(db *sql.DB) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"training_sessions", "duration_minutes", "INTEGER"},
+		{"training_sessions", "start_time", "TEXT"},
+		{"session_completions", "distance_km", "REAL"},
+		{"training_plans", "description", "TEXT NOT NULL DEFAULT ''"},
+		{"training_plans", "source", "TEXT NOT NULL DEFAULT ''"},
+		{"training_plans", "author", "TEXT NOT NULL DEFAULT ''"},
+		{"training_sessions", "sync_key", "TEXT"},
+		{"training_sessions", "archived", "BOOLEAN NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := addColumn(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateSessionDates(db); err != nil {
+		return err
+	}
+
+	return migrateForeignKeys(db)
+}
+
+// migrateSessionDates converts session dates stored as full timestamps into
+// plain calendar dates ("2006-01-02"). Timestamps at midnight only ever
+// carried a date and keep the day as written. Other timestamps are instants;
+// they are converted into the server's zone and their time of day becomes
+// the start time unless one is set already.
+func migrateSessionDates(db *sql.DB) error {
+	rows, err := db.Query(`
+		SELECT id, date, COALESCE(start_time, '')
+		FROM training_sessions
+		WHERE length(date) > 10`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type update struct {
+		id        int64
+		date      string
+		startTime string
+	}
+	var updates []update
+	for rows.Next() {
+		var (
+			u    update
+			date time.Time
+		)
+		if err := rows.Scan(&u.id, &date, &u.startTime); err != nil {
+			return err
+		}
+		if date.Hour() != 0 || date.Minute() != 0 || date.Second() != 0 {
+			date = date.In(time.Local)
+			if u.startTime == "" {
+				u.startTime = date.Format("15:04")
+			}
+		}
+		u.date = date.Format("2006-01-02")
+		updates = append(updates, u)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, u := range updates {
+		_, err := tx.Exec(`
+			UPDATE training_sessions SET date = ?, start_time = NULLIF(?, '')
+			WHERE id = ?`, u.date, u.startTime, u.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addColumn adds a column to an existing table unless it is already present.
+func addColumn(db *sql.DB, table, column, definition string) error {
+	columns, err := sqliteColumns(db, table)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(columns, column) {
+		return nil
+	}
 
-	_, err := db.Exec(tables)
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
 	return err
 }
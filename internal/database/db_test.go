@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMigrateSessionDates(t *testing.T) {
+	// Instants are converted into the server's zone
+	local := time.Local
+	time.Local = time.FixedZone("UTC+1", 60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	tests := []struct {
+		name      string
+		stored    string
+		date      string
+		startTime string
+	}{
+		{"midnight UTC", "2025-03-03 00:00:00+00:00", "2025-03-03", ""},
+		{"midnight as written by YAML", "2025-03-03T00:00:00Z", "2025-03-03", ""},
+		{"midnight in another zone", "2025-03-03 00:00:00+02:00", "2025-03-03", ""},
+		{"time of day", "2025-03-03 06:30:00+00:00", "2025-03-03", "07:30"},
+		{"time of day on the next local day", "2025-03-03 23:30:00+00:00", "2025-03-04", "00:30"},
+		{"plain date", "2025-03-05", "2025-03-05", ""},
+	}
+	var rows string
+	for i, tt := range tests {
+		rows += fmt.Sprintf("INSERT INTO training_sessions (id, date) VALUES (%d, '%s');\n", i+1, tt.stored)
+	}
+	db := openBaseline(t, rows)
+
+	check := func() {
+		t.Helper()
+		for i, tt := range tests {
+			var date string
+			var startTime sql.NullString
+			err := db.QueryRow("SELECT CAST(date AS TEXT), start_time FROM training_sessions WHERE id = ?", i+1).Scan(&date, &startTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if date != tt.date || startTime.String != tt.startTime {
+				t.Errorf("%s: %q became %s %q, want %s %q", tt.name, tt.stored, date, startTime.String, tt.date, tt.startTime)
+			}
+		}
+	}
+
+	if err := CreateTables(db, SQLite); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	// Running it again changes nothing
+	if err := migrateSessionDates(db); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// Problem is a kind of row that breaks a foreign key, with the primary keys
+// of the affected rows.
+type Problem struct {
+	Description string
+	// What Doctor does about it when fixing
+	Fix string
+	IDs []int64
+}
+
+// describe returns the problem rows breaking a foreign key amount to, and
+// what fixing them means.
+func (fk foreignKey) describe() (string, string) {
+	switch fk.table {
+	case "training_plans":
+		return "plans with an unknown workout type",
+			"set to the workout type their sessions have details for; deleted if they have no sessions, otherwise left to be set by hand"
+	case "training_sessions":
+		return "sessions without a plan", "deleted"
+	default:
+		return fmt.Sprintf("orphaned %s rows", fk.table), "deleted"
+	}
+}
+
+// violation is the condition a row of fk.table breaking fk meets. A missing
+// reference counts as well: every plan needs a workout type and every
+// session a plan.
+func (fk foreignKey) violation() string {
+	return fmt.Sprintf("%[1]s.%[2]s IS NULL OR NOT EXISTS (SELECT 1 FROM %[3]s WHERE %[3]s.id = %[1]s.%[2]s)",
+		fk.table, fk.column, fk.parent)
+}
+
+// Doctor looks for rows that break a foreign key, which databases may hold
+// from before SQLite enforced them. With fix set it also repairs them, in
+// one transaction. Problems are checked parents first, so fixing one, such
+// as deleting a session, shows up in the checks after it.
+func Doctor(ctx context.Context, db *sql.DB, fix bool) ([]Problem, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var problems []Problem
+	for _, fk := range foreignKeys {
+		ids, err := queryIDs(ctx, tx, fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s",
+			fk.key, fk.table, fk.violation(), fk.key))
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		description, action := fk.describe()
+		problems = append(problems, Problem{Description: description, Fix: action, IDs: ids})
+		if !fix {
+			continue
+		}
+
+		if fk.table == "training_plans" {
+			err = fixPlanTypes(ctx, tx, ids)
+		} else {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", fk.table, fk.violation()))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fixing %s: %w", description, err)
+		}
+	}
+
+	if !fix {
+		return problems, nil
+	}
+	return problems, tx.Commit()
+}
+
+// fixPlanTypes gives plans without a known workout type the one their
+// sessions have details for. Plans without sessions are deleted. The rest,
+// with details of several types or none at all, are left alone rather than
+// losing their history; they need a workout type set by hand.
+func fixPlanTypes(ctx context.Context, tx *sql.Tx, planIDs []int64) error {
+	names := make([]string, 0, len(DetailTables))
+	for name := range DetailTables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	types := make(map[int64][]int64)
+	for _, name := range names {
+		// The names are constants, so they can be part of the query
+		ids, err := queryIDs(ctx, tx, fmt.Sprintf(`
+			SELECT DISTINCT ts.plan_id, wt.id
+			FROM training_sessions ts
+			JOIN %s d ON d.session_id = ts.id
+			JOIN workout_types wt ON wt.name = '%s'
+			WHERE ts.plan_id IS NOT NULL`, DetailTables[name], name))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(ids); i += 2 {
+			types[ids[i]] = append(types[ids[i]], ids[i+1])
+		}
+	}
+
+	for _, id := range planIDs {
+		var statement string
+		switch {
+		case len(types[id]) == 1:
+			statement = fmt.Sprintf("UPDATE training_plans SET workout_type_id = %d WHERE id = %d", types[id][0], id)
+		case len(types[id]) == 0:
+			statement = fmt.Sprintf("DELETE FROM training_plans WHERE id = %d AND NOT EXISTS (SELECT 1 FROM training_sessions WHERE plan_id = %d)", id, id)
+		default:
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryIDs returns the integer columns of every row of a query, one row
+// after the other.
+func queryIDs(ctx context.Context, tx *sql.Tx, query string) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	row := make([]int64, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range row {
+		dest[i] = &row[i]
+	}
+
+	var ids []int64
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		ids = append(ids, row...)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DetailTables holds the table with the type-specific details of every
+// workout type.
+var DetailTables = map[string]string{
+	"cycling":  "cycling_sessions",
+	"mobility": "mobility_sessions",
+	"sandbag":  "sandbag_sessions",
+	"core":     "core_sessions",
+}
+
+// querier runs queries on a database or within a transaction.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// foreignKey is a reference from a column to the id of a parent table.
+type foreignKey struct {
+	table  string
+	column string
+	parent string
+	// Primary key of the referencing table
+	key string
+	// What happens to the row when its parent is deleted
+	onDelete string
+}
+
+// foreignKeys lists every foreign key, parents before children. Plans keep
+// their workout type from being deleted; everything else goes along with
+// the row it belongs to.
+var foreignKeys = []foreignKey{
+	{"training_plans", "workout_type_id", "workout_types", "id", "RESTRICT"},
+	{"training_sessions", "plan_id", "training_plans", "id", "CASCADE"},
+	{"cycling_sessions", "session_id", "training_sessions", "session_id", "CASCADE"},
+	{"mobility_sessions", "session_id", "training_sessions", "session_id", "CASCADE"},
+	{"sandbag_sessions", "session_id", "training_sessions", "session_id", "CASCADE"},
+	{"core_sessions", "session_id", "training_sessions", "session_id", "CASCADE"},
+	{"session_completions", "session_id", "training_sessions", "session_id", "CASCADE"},
+	{"plan_history", "plan_id", "training_plans", "id", "CASCADE"},
+}
+
+// migrateForeignKeys rebuilds the tables of databases created before foreign
+// keys had ON DELETE actions, since SQLite cannot alter a constraint.
+// Existing rows are copied as they are, even if they violate a foreign key;
+// the doctor reports and fixes those.
+func migrateForeignKeys(db *sql.DB) error {
+	var stale []string
+	for _, fk := range foreignKeys {
+		onDelete, err := sqliteOnDelete(db, fk)
+		if err != nil {
+			return err
+		}
+		if onDelete != fk.onDelete {
+			stale = append(stale, fk.table)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	// foreign_keys holds per connection and cannot change within a
+	// transaction, so the rebuild pins one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range sqliteTables {
+		if !slices.Contains(stale, table.name) {
+			continue
+		}
+		columns, err := sqliteColumns(tx, table.name)
+		if err != nil {
+			return err
+		}
+		list := strings.Join(columns, ", ")
+
+		statements := []string{
+			fmt.Sprintf("CREATE TABLE %s_rebuild (%s\n)", table.name, table.definition),
+			fmt.Sprintf("INSERT INTO %s_rebuild (%s) SELECT %s FROM %s", table.name, list, list, table.name),
+			fmt.Sprintf("DROP TABLE %s", table.name),
+			fmt.Sprintf("ALTER TABLE %s_rebuild RENAME TO %s", table.name, table.name),
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("rebuilding %s: %w", table.name, err)
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, sqliteIndexes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteOnDelete returns the ON DELETE action of a foreign key, or "" if the
+// table does not declare it.
+func sqliteOnDelete(db *sql.DB, fk foreignKey) (string, error) {
+	rows, err := db.Query("PRAGMA foreign_key_list(" + fk.table + ")")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, seq                     int
+			parent, from                string
+			to                          sql.NullString
+			onUpdate, onDelete, matches string
+		)
+		if err := rows.Scan(&id, &seq, &parent, &from, &to, &onUpdate, &onDelete, &matches); err != nil {
+			return "", err
+		}
+		if from == fk.column {
+			return onDelete, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// sqliteColumns returns the column names of a table.
+func sqliteColumns(q querier, table string) ([]string, error) {
+	rows, err := q.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   bool
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// migratePostgresForeignKeys replaces foreign keys created without their
+// ON DELETE action. The constraints carry PostgreSQL's default names.
+func migratePostgresForeignKeys(db *sql.DB) error {
+	actions := map[string]string{"CASCADE": "c", "RESTRICT": "r"}
+
+	for _, fk := range foreignKeys {
+		name := fk.table + "_" + fk.column + "_fkey"
+		var action string
+		err := db.QueryRow("SELECT confdeltype FROM pg_constraint WHERE conname = $1", name).Scan(&action)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if action == actions[fk.onDelete] {
+			continue
+		}
+
+		_, err = db.Exec(fmt.Sprintf(
+			"ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s, ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s(id) ON DELETE %s",
+			fk.table, name, name, fk.column, fk.parent, fk.onDelete))
+		if err != nil {
+			return fmt.Errorf("updating %s: %w", name, err)
+		}
+	}
+	return nil
+}
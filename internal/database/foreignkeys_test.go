@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// baselineSchema is the schema of the first release: foreign keys without
+// ON DELETE actions, which SQLite did not enforce, and full timestamps as
+// session dates.
+const baselineSchema = `
+	CREATE TABLE workout_types (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE
+	);
+	CREATE TABLE training_plans (
+		id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		workout_type_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (workout_type_id) REFERENCES workout_types(id)
+	);
+	CREATE TABLE training_sessions (
+		id INTEGER PRIMARY KEY,
+		plan_id INTEGER,
+		session_order INTEGER,
+		description TEXT,
+		date TIMESTAMP NOT NULL,
+		completed BOOLEAN DEFAULT 0,
+		FOREIGN KEY (plan_id) REFERENCES training_plans(id)
+	);
+	CREATE TABLE cycling_sessions (
+		session_id INTEGER PRIMARY KEY,
+		hfmax TEXT,
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
+	);
+	CREATE TABLE mobility_sessions (
+		session_id INTEGER PRIMARY KEY,
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
+	);
+	CREATE TABLE sandbag_sessions (
+		session_id INTEGER PRIMARY KEY,
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
+	);
+	CREATE TABLE core_sessions (
+		session_id INTEGER PRIMARY KEY,
+		FOREIGN KEY (session_id) REFERENCES training_sessions(id)
+	);
+	INSERT INTO workout_types (id, name) VALUES (1, 'cycling'), (2, 'mobility'), (3, 'sandbag'), (4, 'core');
+`
+
+// openBaseline returns a database file with the baseline schema and the
+// given rows, written without foreign keys enforced like the first release
+// did, opened as the server opens it but before any migration.
+func openBaseline(t *testing.T, rows string) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec(baselineSchema + rows); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	db, err := InitDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// orphans holds rows that break the foreign keys of the baseline schema:
+// plan 2 has an unknown workout type but core sessions, plan 3 has no
+// workout type and no sessions, plan 4 an unknown one and sessions without
+// details. Session 5 belongs to no plan, and cycling row 77 to no session.
+const orphans = `
+	INSERT INTO training_plans (id, name, workout_type_id) VALUES
+		(1, 'Base', 1), (2, 'Core', 99), (3, 'Empty', NULL), (4, 'Unknown', 99);
+	INSERT INTO training_sessions (id, plan_id, session_order, description, date, completed) VALUES
+		(1, 1, 1, 'GA1', '2025-03-03', 1),
+		(2, 1, 2, 'GA2', '2025-03-04', 0),
+		(3, 2, 1, 'Plank', '2025-03-03', 0),
+		(4, 4, 1, 'Something', '2025-03-03', 0),
+		(5, 42, 1, 'Lost', '2025-03-03', 0);
+	INSERT INTO cycling_sessions (session_id, hfmax) VALUES (1, '140'), (2, '150'), (5, '130'), (77, '120');
+	INSERT INTO core_sessions (session_id) VALUES (3);
+`
+
+func TestMigrateForeignKeys(t *testing.T) {
+	db := openBaseline(t, orphans)
+	if err := CreateTables(db, SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every row survives the rebuild, the orphans included
+	for table, want := range map[string]int{
+		"workout_types":     4,
+		"training_plans":    4,
+		"training_sessions": 5,
+		"cycling_sessions":  4,
+		"core_sessions":     1,
+	} {
+		if got := count(t, db, "SELECT COUNT(*) FROM "+table); got != want {
+			t.Errorf("%s: got %d rows, want %d", table, got, want)
+		}
+	}
+	if got := count(t, db, "SELECT COUNT(*) FROM cycling_sessions WHERE session_id = 1 AND hfmax = '140'"); got != 1 {
+		t.Error("cycling details of session 1 were not kept")
+	}
+
+	for _, fk := range foreignKeys {
+		onDelete, err := sqliteOnDelete(db, fk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if onDelete != fk.onDelete {
+			t.Errorf("%s.%s: got ON DELETE %q, want %q", fk.table, fk.column, onDelete, fk.onDelete)
+		}
+	}
+
+	// Migrating again leaves the tables alone
+	if err := CreateTables(db, SQLite); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(t, db, "SELECT COUNT(*) FROM training_sessions"); got != 5 {
+		t.Errorf("got %d sessions after migrating again, want 5", got)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	ctx := context.Background()
+	db := openBaseline(t, orphans)
+	if err := CreateTables(db, SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(problems []Problem) map[string][]int64 {
+		m := make(map[string][]int64)
+		for _, p := range problems {
+			m[p.Description] = p.IDs
+		}
+		return m
+	}
+
+	problems, err := Doctor(ctx, db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]int64{
+		"plans with an unknown workout type": {2, 3, 4},
+		"sessions without a plan":            {5},
+		"orphaned cycling_sessions rows":     {77},
+	}
+	if got := ids(problems); !reflect.DeepEqual(got, want) {
+		t.Errorf("got problems %v, want %v", got, want)
+	}
+	if got := count(t, db, "SELECT COUNT(*) FROM training_sessions"); got != 5 {
+		t.Errorf("reporting changed the sessions: got %d, want 5", got)
+	}
+
+	// Fixing deletes session 5, whose details go along with it now
+	problems, err = Doctor(ctx, db, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(problems); !reflect.DeepEqual(got, want) {
+		t.Errorf("got fixed problems %v, want %v", got, want)
+	}
+
+	var plans []string
+	rows, err := db.Query("SELECT id || ':' || COALESCE(workout_type_id, '') FROM training_plans ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var plan string
+		if err := rows.Scan(&plan); err != nil {
+			t.Fatal(err)
+		}
+		plans = append(plans, plan)
+	}
+	// Plan 2 gets the type of its details, 3 is deleted, 4 is left to be set
+	// by hand
+	if want := []string{"1:1", "2:4", "4:99"}; !reflect.DeepEqual(plans, want) {
+		t.Errorf("got plans %v, want %v", plans, want)
+	}
+	for table, want := range map[string]int{"training_sessions": 4, "cycling_sessions": 2, "core_sessions": 1} {
+		if got := count(t, db, "SELECT COUNT(*) FROM "+table); got != want {
+			t.Errorf("%s: got %d rows after fixing, want %d", table, got, want)
+		}
+	}
+
+	problems, err = Doctor(ctx, db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(problems); !reflect.DeepEqual(got, map[string][]int64{"plans with an unknown workout type": {4}}) {
+		t.Errorf("got problems %v after fixing, want only plan 4", got)
+	}
+
+	// Deleting a plan now takes its sessions and their details along
+	if _, err := db.Exec("DELETE FROM training_plans WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(t, db, "SELECT COUNT(*) FROM cycling_sessions"); got != 0 {
+		t.Errorf("got %d cycling rows after deleting their plan, want 0", got)
+	}
+}
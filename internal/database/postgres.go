@@ -0,0 +1,106 @@
+package database
+
+import "database/sql"
+
+// postgresSchema mirrors the SQLite schema. Dates are real DATE columns and
+// flags real booleans; everything else keeps its SQLite type.
+const postgresSchema = `
+	CREATE TABLE IF NOT EXISTS workout_types (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE
+	);
+
+	CREATE TABLE IF NOT EXISTS training_plans (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		workout_type_id INTEGER REFERENCES workout_types(id) ON DELETE RESTRICT,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		description TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT '',
+		author TEXT NOT NULL DEFAULT ''
+	);
+
+	-- Added after the first release
+	ALTER TABLE training_plans ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE training_plans ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+	ALTER TABLE training_plans ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT '';
+
+	CREATE TABLE IF NOT EXISTS training_sessions (
+		id BIGSERIAL PRIMARY KEY,
+		plan_id BIGINT REFERENCES training_plans(id) ON DELETE CASCADE,
+		session_order INTEGER,
+		description TEXT,
+		date DATE NOT NULL,
+		completed BOOLEAN NOT NULL DEFAULT FALSE,
+		duration_minutes INTEGER,
+		start_time TEXT,
+		sync_key TEXT,
+		archived BOOLEAN NOT NULL DEFAULT FALSE
+	);
+
+	-- Added for syncing plans with their YAML
+	ALTER TABLE training_sessions ADD COLUMN IF NOT EXISTS sync_key TEXT;
+	ALTER TABLE training_sessions ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+	CREATE TABLE IF NOT EXISTS cycling_sessions (
+		session_id BIGINT PRIMARY KEY REFERENCES training_sessions(id) ON DELETE CASCADE,
+		hfmax TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS mobility_sessions (
+		session_id BIGINT PRIMARY KEY REFERENCES training_sessions(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS sandbag_sessions (
+		session_id BIGINT PRIMARY KEY REFERENCES training_sessions(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS core_sessions (
+		session_id BIGINT PRIMARY KEY REFERENCES training_sessions(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS session_completions (
+		session_id BIGINT PRIMARY KEY REFERENCES training_sessions(id) ON DELETE CASCADE,
+		completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		duration_minutes INTEGER,
+		avg_hr INTEGER,
+		rpe INTEGER,
+		distance_km DOUBLE PRECISION
+	);
+
+	CREATE TABLE IF NOT EXISTS plan_history (
+		id BIGSERIAL PRIMARY KEY,
+		plan_id BIGINT NOT NULL REFERENCES training_plans(id) ON DELETE CASCADE,
+		session_id BIGINT,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		snapshot_before TEXT,
+		snapshot_after TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_training_sessions_date ON training_sessions(date);
+	CREATE INDEX IF NOT EXISTS idx_training_sessions_plan ON training_sessions(plan_id, date, session_order, completed);
+	CREATE INDEX IF NOT EXISTS idx_plan_history_plan ON plan_history(plan_id, id);
+
+	INSERT INTO workout_types (name) VALUES
+		('cycling'),
+		('mobility'),
+		('sandbag'),
+		('core')
+	ON CONFLICT (name) DO NOTHING;
+`
+
+// createPostgresTables sets up a PostgreSQL database and migrates older
+// schemas.
+func createPostgresTables(db *sql.DB) error {
+	if _, err := db.Exec(postgresSchema); err != nil {
+		return err
+	}
+	return migratePostgresForeignKeys(db)
+}
@@ -0,0 +1,234 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+// openTestPostgres connects to the PostgreSQL database of TEST_POSTGRES_DSN,
+// in a schema of its own that is dropped after the test. Without the
+// variable the test is skipped.
+func openTestPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	admin, _, err := database.Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	db, _, err := database.Open(dsn + separator + "search_path=" + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.CreateTables(db, database.Postgres); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// TestPostgres copies a SQLite database into PostgreSQL and runs the service
+// on the copy, so that every query goes through the rewriting of the
+// PostgreSQL dialect.
+func TestPostgres(t *testing.T) {
+	ctx := context.Background()
+	pg := openTestPostgres(t)
+
+	lite, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lite.Close()
+	if err := database.CreateTables(lite, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+
+	// Seed SQLite
+	seed := service.New(storage.NewSQLite(lite))
+	types, err := seed.WorkoutTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cyclingID int64
+	for _, wt := range types {
+		if wt.Name == "cycling" {
+			cyclingID = wt.ID
+		}
+	}
+	sessions := []service.NewSession{
+		{Key: "a", Description: "60 min GA1", Date: day(t, "2025-03-01"), StartTime: "07:30", HFMax: "68-73"},
+		{Key: "b", Description: "90 min GA1", Date: day(t, "2025-03-02"), HFMax: "150"},
+		{Key: "c", Description: "45 min GA1", Date: day(t, "2025-03-04")},
+	}
+	plan, err := seed.CreatePlan(ctx, service.NewPlan{Name: "Base", WorkoutTypeID: cyclingID, Author: "Coach", Sessions: sessions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := seed.Plan(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr, distance := 135, 30.5
+	if err := seed.CompleteSession(ctx, models.Completion{SessionID: detail.Sessions[0].ID, AvgHR: &hr, DistanceKm: &distance}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.SaveSettings(ctx, service.Settings{RestingHR: 50, MaxHR: 190, TimeZone: "Europe/Berlin", WeekStart: "sunday"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copy
+	counts, err := database.Copy(ctx, lite, pg, database.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, want := range map[string]int{"training_plans": 1, "training_sessions": 3, "cycling_sessions": 3, "session_completions": 1, "plan_history": 2} {
+		if counts[table] != want {
+			t.Errorf("copied %d rows of %s, want %d", counts[table], table, want)
+		}
+	}
+
+	// Read the copy
+	svc := service.New(storage.NewPostgres(pg))
+	copied, err := svc.Plan(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Name != "Base" || copied.Author != "Coach" || len(copied.Sessions) != 3 {
+		t.Fatalf("copied plan %+v", copied.TrainingPlan)
+	}
+	first := copied.Sessions[0]
+	if !first.Date.Equal(day(t, "2025-03-01")) || first.StartTime == nil || *first.StartTime != "07:30" || first.HFMax != "68-73" || !first.Completed {
+		t.Errorf("copied session %+v", first)
+	}
+
+	settings, err := svc.Settings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.TimeZone != "Europe/Berlin" || settings.WeekStart != "sunday" || settings.MaxHR != 190 {
+		t.Errorf("copied settings %+v", settings)
+	}
+
+	scheduled, err := svc.Schedule(ctx, storage.DateRange{From: day(t, "2025-03-02"), To: day(t, "2025-03-05")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduled) != 2 || scheduled[0].PlanName != "Base" || scheduled[0].WorkoutType != "cycling" {
+		t.Errorf("scheduled %+v", scheduled)
+	}
+
+	perDay, err := svc.CompletedPerDay(ctx, storage.DateRange{From: day(t, "2025-01-01"), To: day(t, "2026-01-01")}, storage.StatsFilter{WorkoutType: "cycling"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perDay) != 1 || perDay[0] != (storage.DayTotal{Date: "2025-03-01", Sessions: 1, Minutes: 60}) {
+		t.Errorf("completed per day %+v", perDay)
+	}
+
+	volume, err := svc.VolumePerDay(ctx, storage.DateRange{From: day(t, "2025-03-01"), To: day(t, "2025-04-01")}, storage.StatsFilter{PlanID: plan.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volume) != 3 || volume[0].CompletedMinutes != 60 || volume[0].DistanceKm != distance {
+		t.Errorf("volume %+v", volume)
+	}
+
+	load, err := svc.LoadSessions(ctx, storage.StatsFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(load) != 3 || !load[0].AvgHR.Valid || load[0].AvgHR.Int64 != int64(hr) || load[1].HFMax != "150" {
+		t.Errorf("load sessions %+v", load)
+	}
+
+	streaks, err := svc.Streaks(ctx, day(t, "2025-03-02"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streaks) != 1 || streaks[0].Completed != 1 || streaks[0].Total != 2 {
+		t.Errorf("streaks %+v", streaks)
+	}
+
+	// Write to the copy; new rows continue after the copied IDs
+	added, err := svc.AddSession(ctx, plan.ID, service.NewSession{Description: "30 min locker", Date: day(t, "2025-03-05")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID <= detail.Sessions[2].ID {
+		t.Errorf("added session got ID %d, not after the copied %d", added.ID, detail.Sessions[2].ID)
+	}
+	if err := svc.CompleteSession(ctx, models.Completion{SessionID: detail.Sessions[1].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	sync, err := svc.SyncPlan(ctx, plan.ID, service.NewPlan{Sessions: []service.NewSession{
+		{Key: "a", Description: "60 min GA1", Date: day(t, "2025-03-01"), StartTime: "07:30", HFMax: "68-73"},
+		{Key: "c", Description: "50 min GA1", Date: day(t, "2025-03-06")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b was completed and is archived, the added session is deleted
+	for action, want := range map[service.SyncAction]int{service.SyncUpdate: 1, service.SyncArchive: 1, service.SyncDelete: 1} {
+		if n := sync.Count(action); n != want {
+			t.Errorf("sync: %d %s, want %d", n, action, want)
+		}
+	}
+
+	history, err := svc.PlanHistory(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 5 || history[0].Action != service.ActionSyncPlan || history[len(history)-1].Action != service.ActionCreatePlan {
+		t.Fatalf("history has %d entries", len(history))
+	}
+	if _, err := svc.RevertPlan(ctx, plan.ID, history[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	reverted, err := svc.Plan(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted.Sessions) != 4 || reverted.Sessions[2].Description != "45 min GA1" {
+		t.Errorf("reverted plan has %d sessions", len(reverted.Sessions))
+	}
+}
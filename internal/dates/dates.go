@@ -0,0 +1,57 @@
+// Package dates handles the calendar dates sessions are planned on.
+//
+// Session dates are calendar dates without a time zone, stored as
+// "2006-01-02". In Go they are represented as midnight UTC of that day so
+// that date arithmetic never crosses a day boundary. Whatever "today" is
+// depends on the user's time zone.
+package dates
+
+import "time"
+
+// Layout is the format dates are stored and exchanged in.
+const Layout = "2006-01-02"
+
+// Of returns the calendar day of t, as seen in t's own location.
+func Of(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Today returns the current calendar day in loc.
+func Today(loc *time.Location) time.Time {
+	return Of(time.Now().In(loc))
+}
+
+// Parse parses a date in Layout.
+func Parse(value string) (time.Time, error) {
+	return time.Parse(Layout, value)
+}
+
+// Split turns a timestamp from an import into a calendar date and an
+// optional start time. A timestamp at midnight only carries a date, which is
+// kept as written. Any other timestamp is an instant and is converted into
+// loc first.
+func Split(t time.Time, loc *time.Location) (time.Time, string) {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return Of(t), ""
+	}
+	local := t.In(loc)
+	return Of(local), local.Format("15:04")
+}
+
+// MinutesOfDay converts a "15:04" start time into minutes after midnight.
+func MinutesOfDay(startTime string) (int, bool) {
+	t, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// Start returns the instant a session with a start time begins.
+func Start(date time.Time, startTime string, loc *time.Location) (time.Time, bool) {
+	minutes, ok := MinutesOfDay(startTime)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), minutes/60, minutes%60, 0, 0, loc), true
+}
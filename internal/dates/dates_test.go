@@ -0,0 +1,73 @@
+package dates
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		date      string
+		startTime string
+		loc       *time.Location
+		want      string
+		ok        bool
+	}{
+		{"2025-01-15", "18:30", berlin, "2025-01-15T18:30:00+01:00", true},
+		{"2025-07-15", "18:30", berlin, "2025-07-15T18:30:00+02:00", true},
+		// The night the clocks go forward
+		{"2025-03-30", "07:00", berlin, "2025-03-30T07:00:00+02:00", true},
+		{"2025-01-15", "06:05", time.UTC, "2025-01-15T06:05:00Z", true},
+		{"2025-01-15", "", berlin, "", false},
+		{"2025-01-15", "25:00", berlin, "", false},
+	}
+	for _, tt := range tests {
+		date, err := Parse(tt.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, ok := Start(date, tt.startTime, tt.loc)
+		if ok != tt.ok {
+			t.Errorf("Start(%s, %q) ok = %v, want %v", tt.date, tt.startTime, ok, tt.ok)
+			continue
+		}
+		if ok && start.Format(time.RFC3339) != tt.want {
+			t.Errorf("Start(%s, %q) = %s, want %s", tt.date, tt.startTime, start.Format(time.RFC3339), tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		timestamp string
+		date      string
+		startTime string
+	}{
+		// Midnight carries only a date, whatever its zone
+		{"2025-03-03T00:00:00Z", "2025-03-03", ""},
+		{"2025-03-03T00:00:00-08:00", "2025-03-03", ""},
+		{"2025-03-03T17:30:00Z", "2025-03-03", "18:30"},
+		{"2025-03-03T18:30:00+01:00", "2025-03-03", "18:30"},
+		// Late in UTC is the next day in Berlin
+		{"2025-03-03T23:30:00Z", "2025-03-04", "00:30"},
+	}
+	for _, tt := range tests {
+		ts, err := time.Parse(time.RFC3339, tt.timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		date, startTime := Split(ts, berlin)
+		if date.Format(Layout) != tt.date || startTime != tt.startTime {
+			t.Errorf("Split(%s) = %s %q, want %s %q", tt.timestamp, date.Format(Layout), startTime, tt.date, tt.startTime)
+		}
+	}
+}
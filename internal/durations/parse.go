@@ -0,0 +1,118 @@
+// Package durations extracts planned session durations from free-text
+// descriptions such as "40 min Grundlageneinheit" or
+// "15 min Einfahren, 4x2 min Kraftausdauer mit 2 min locker kurbeln, 15 min Ausfahren".
+package durations
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// 4x2 min, 6x30 sec, 4x30s
+	intervalPattern = regexp.MustCompile(`(?i)(\d+)\s*[x×]\s*(\d+(?:[.,]\d+)?)\s*(h|std|min|minutes?|minuten|sec|sek|s)\b`)
+	// mit 3 min locker kurbeln, P: 4 min, with 2 min rest
+	pausePattern = regexp.MustCompile(`(?i)(?:mit|p:|pause|with|rest)\s*(\d+(?:[.,]\d+)?)\s*(min|minutes?|minuten|sec|sek|s)\b`)
+	// 3,5 Std
+	decimalCommaPattern = regexp.MustCompile(`(\d),(\d)`)
+	// 1h30, 1 h 30 min
+	hoursMinutesPattern = regexp.MustCompile(`(?i)(\d+)\s*(?:h|std)\s*(\d+)\s*(?:min|minutes?|minuten)?\b`)
+	// 2.5h, 3,5 Std, 2 hours
+	hoursPattern = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*(h|hrs?|hours?|std|stunden?)\b`)
+	// 40 min, 10min, 90 minutes
+	minutesPattern = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*(min|mins|minutes?|minuten)\b`)
+)
+
+// nestedPrefixes mark parts of a description that happen within the time of
+// the preceding part, e.g. "60 min Grundlageneinheit, darin 4 kurze Antritte".
+var nestedPrefixes = []string{"darin", "davon", "inkl", "including", "incl"}
+
+// Parse returns the total planned duration in minutes described by text.
+// The description is split at commas; every part contributes its first
+// duration, interval blocks count repetitions and their recovery pauses.
+// ok is false if no duration could be found.
+func Parse(text string) (minutes int, ok bool) {
+	total := 0.0
+	found := false
+	// Repetitions of the previous interval block, for a pause given as a
+	// separate part like "4x4 min, P: 3 min"
+	reps := 0
+
+	text = decimalCommaPattern.ReplaceAllString(text, "$1.$2")
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || isNested(part) {
+			continue
+		}
+
+		if m := intervalPattern.FindStringSubmatch(part); m != nil {
+			reps, _ = strconv.Atoi(m[1])
+			total += float64(reps) * toMinutes(m[2], m[3])
+			rest := part[strings.Index(part, m[0])+len(m[0]):]
+			if p := pausePattern.FindStringSubmatch(rest); p != nil {
+				total += float64(reps) * toMinutes(p[1], p[2])
+				reps = 0
+			}
+			found = true
+			continue
+		}
+
+		if p := pausePattern.FindStringSubmatchIndex(part); p != nil && p[0] == 0 && reps > 0 {
+			total += float64(reps) * toMinutes(part[p[2]:p[3]], part[p[4]:p[5]])
+			reps = 0
+			continue
+		}
+		reps = 0
+
+		if m := hoursMinutesPattern.FindStringSubmatch(part); m != nil {
+			total += toMinutes(m[1], "h") + toMinutes(m[2], "min")
+			found = true
+			continue
+		}
+
+		if m := hoursPattern.FindStringSubmatch(part); m != nil {
+			total += toMinutes(m[1], m[2])
+			found = true
+			continue
+		}
+
+		if m := minutesPattern.FindStringSubmatch(part); m != nil {
+			total += toMinutes(m[1], m[2])
+			found = true
+		}
+	}
+
+	if !found || total <= 0 {
+		return 0, false
+	}
+	return int(math.Round(total)), true
+}
+
+func isNested(part string) bool {
+	lower := strings.ToLower(part)
+	for _, prefix := range nestedPrefixes {
+		if strings.HasPrefix(lower, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// toMinutes converts an amount with a German or English unit into minutes.
+func toMinutes(amount, unit string) float64 {
+	value, err := strconv.ParseFloat(strings.Replace(amount, ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+
+	switch strings.ToLower(unit) {
+	case "h", "hr", "hrs", "hour", "hours", "std", "stunde", "stunden":
+		return value * 60
+	case "s", "sec", "sek":
+		return value / 60
+	default:
+		return value
+	}
+}
@@ -0,0 +1,67 @@
+package durations
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text    string
+		minutes int
+		ok      bool
+	}{
+		{"40 min Grundlageneinheit", 40, true},
+		{"10min Einfahren", 10, true},
+		{"90 minutes endurance", 90, true},
+		{"2h Grundlageneinheit", 120, true},
+		{"2.5h Grundlageneinheit", 150, true},
+		{"3,5 Std Grundlageneinheit", 210, true},
+		{"2 hours easy", 120, true},
+		{"1h30 Grundlageneinheit", 90, true},
+		{"1 h 30 min Grundlageneinheit", 90, true},
+		// Interval blocks count their repetitions and pauses
+		{"15 min Einfahren, 4x2 min Kraftausdauer mit 2 min locker kurbeln, 15 min Ausfahren", 46, true},
+		{"10min Einfahren, 4x30s intensive Belastung mit 4 min locker kurbeln, 5 min lockeres Ausfahren", 33, true},
+		{"15 min Einfahren, 6x30 sec intensiv mit 3 min locker kurbeln, 10 min lockeres Ausfahren", 46, true},
+		{"15 min Einfahren, 4x3 min intensiv (EB) P: 4 min locker, 10 min lockeres Ausfahren", 53, true},
+		// A pause given as its own part belongs to the interval block before
+		{"20 min Einfahren, 4x6 min intensiv, P: 4 min, 15 min Ausfahren", 75, true},
+		// Without one, it is time like any other
+		{"20 min Einfahren, P: 4 min, 15 min Ausfahren", 39, true},
+		// Parts within the time of the preceding one are left out
+		{"120 min Grundlageneinheit, darin 4-6 kurze Antritte á 6-8 sec", 120, true},
+		{"30 min locker kurbeln, davon 3 min schnell", 30, true},
+		{"60 min endurance, including 5 min sprints", 60, true},
+		// Only the first duration of a part counts
+		{"15 min locker Einfahren, 60 min GA2, 10 min locker Ausfahren", 85, true},
+		{"30 ganz locker fahren", 0, false},
+		{"Ruhetag", 0, false},
+		{"", 0, false},
+		{"0 min", 0, false},
+	}
+	for _, tt := range tests {
+		minutes, ok := Parse(tt.text)
+		if minutes != tt.minutes || ok != tt.ok {
+			t.Errorf("Parse(%q) = %d, %v, want %d, %v", tt.text, minutes, ok, tt.minutes, tt.ok)
+		}
+	}
+}
+
+func TestToMinutes(t *testing.T) {
+	tests := []struct {
+		amount, unit string
+		want         float64
+	}{
+		{"40", "min", 40},
+		{"1.5", "h", 90},
+		{"2", "Std", 120},
+		{"2", "Stunden", 120},
+		{"30", "sec", 0.5},
+		{"90", "s", 1.5},
+		{"3,5", "std", 210},
+		{"x", "min", 0},
+	}
+	for _, tt := range tests {
+		if got := toMinutes(tt.amount, tt.unit); got != tt.want {
+			t.Errorf("toMinutes(%q, %q) = %v, want %v", tt.amount, tt.unit, got, tt.want)
+		}
+	}
+}
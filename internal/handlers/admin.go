@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"training-tracker/internal/backup"
+	"training-tracker/internal/service"
+)
+
+// RegisterAdminRoutes adds the pages for admins. Downloading a backup or
+// storing durations requires the admin token; without one, both are off,
+// as anyone who reaches the server could take or rewrite the whole database
+// otherwise.
+func RegisterAdminRoutes(mux *http.ServeMux, svc *service.Service, backups *backup.Manager, adminToken string) {
+	mux.HandleFunc("/admin/durations", handleBackfillDurations(svc, adminToken))
+	mux.HandleFunc("/admin/backup", handleBackup(backups, adminToken))
+}
+
+// checkAdminToken answers with an error and returns false unless the form
+// carries the admin token. off is the message for when no token is set.
+func checkAdminToken(w http.ResponseWriter, r *http.Request, adminToken, off string) bool {
+	if adminToken == "" {
+		httpError(w, r, off, http.StatusForbidden)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(adminToken)) != 1 {
+		httpError(w, r, "Invalid admin token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
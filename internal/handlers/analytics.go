@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+)
+
+func handleAnalytics(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("analytics.html", nil)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Get all plans for the filter
+		plans, err := svc.Plans(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		load, err := loadFromRequest(svc, r)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		volume, err := volumeFromRequest(svc, r)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		data := struct {
+			Plans   []models.TrainingPlan
+			Load    LoadData
+			Current LoadDay
+			Volume  VolumeData
+		}{
+			Plans:   plans,
+			Load:    load,
+			Current: load.Current(),
+			Volume:  volume,
+		}
+
+		render(w, r, tmpl, data)
+	}
+}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+)
+
+// The JSON API exposes plans and sessions to scripts and other clients.
+// Dates are exchanged as "2006-01-02", errors as {"error": "..."}.
+
+type apiSession struct {
+	ID          int64  `json:"id,omitempty"`
+	PlanID      int64  `json:"plan_id,omitempty"`
+	Order       *int   `json:"order"`
+	Description string `json:"description"`
+	Date        string `json:"date"`
+	StartTime   string `json:"start_time,omitempty"`
+	Duration    int    `json:"duration_minutes,omitempty"`
+	Completed   bool   `json:"completed"`
+	HFMax       string `json:"hfmax,omitempty"`
+	Key         string `json:"key,omitempty"`
+	Archived    bool   `json:"archived,omitempty"`
+}
+
+type apiPlan struct {
+	ID            int64        `json:"id,omitempty"`
+	Name          string       `json:"name"`
+	WorkoutTypeID int64        `json:"workout_type_id"`
+	WorkoutType   string       `json:"workout_type,omitempty"`
+	CreatedAt     *time.Time   `json:"created_at,omitempty"`
+	Description   string       `json:"description,omitempty"`
+	Source        string       `json:"source,omitempty"`
+	Author        string       `json:"author,omitempty"`
+	Sessions      []apiSession `json:"sessions,omitempty"`
+}
+
+func toAPISession(s models.TrainingSession) apiSession {
+	session := apiSession{
+		ID:          s.ID,
+		PlanID:      s.PlanID,
+		Order:       s.SessionOrder,
+		Description: s.Description,
+		Date:        s.Date.Format(dates.Layout),
+		Completed:   s.Completed,
+		HFMax:       s.HFMax,
+		Key:         s.Key,
+		Archived:    s.Archived,
+	}
+	if s.StartTime != nil {
+		session.StartTime = *s.StartTime
+	}
+	if s.Duration != nil {
+		session.Duration = *s.Duration
+	}
+	return session
+}
+
+func toAPIPlan(p models.TrainingPlan) apiPlan {
+	createdAt := p.CreatedAt
+	return apiPlan{
+		ID:            p.ID,
+		Name:          p.Name,
+		WorkoutTypeID: p.WorkoutTypeID,
+		CreatedAt:     &createdAt,
+		Description:   p.Description,
+		Source:        p.Source,
+		Author:        p.Author,
+	}
+}
+
+// newSession converts a session from a request. index is the session's
+// position within a plan, or zero for a single session.
+func (s apiSession) newSession(index int) (service.NewSession, error) {
+	date, err := dates.Parse(s.Date)
+	if err != nil {
+		if index > 0 {
+			return service.NewSession{}, &service.InputError{Field: "date", Message: "Invalid date %q in session %d", Args: []interface{}{s.Date, index}}
+		}
+		return service.NewSession{}, &service.InputError{Field: "date", Message: "Invalid date format"}
+	}
+	return service.NewSession{
+		Key:         s.Key,
+		Order:       s.Order,
+		Description: s.Description,
+		Date:        date,
+		StartTime:   s.StartTime,
+		Duration:    s.Duration,
+		HFMax:       s.HFMax,
+	}, nil
+}
+
+// newPlan converts a plan with its sessions from a request.
+func (p apiPlan) newPlan() (service.NewPlan, error) {
+	plan := service.NewPlan{
+		Name:          p.Name,
+		WorkoutTypeID: p.WorkoutTypeID,
+		Description:   p.Description,
+		Source:        p.Source,
+		Author:        p.Author,
+	}
+	for i, s := range p.Sessions {
+		session, err := s.newSession(i + 1)
+		if err != nil {
+			return plan, err
+		}
+		plan.Sessions = append(plan.Sessions, session)
+	}
+	return plan, nil
+}
+
+// apiSessionChange is a change of a plan sync.
+type apiSessionChange struct {
+	Action service.SyncAction `json:"action"`
+	Number int                `json:"number,omitempty"`
+	Before *apiSession        `json:"before,omitempty"`
+	After  *apiSession        `json:"after,omitempty"`
+	Fields []string           `json:"fields,omitempty"`
+}
+
+// apiPlanSync is what syncing a plan changes, or changed if Applied is set.
+type apiPlanSync struct {
+	Before    apiPlan            `json:"before"`
+	After     apiPlan            `json:"after"`
+	Fields    []string           `json:"fields,omitempty"`
+	Changes   []apiSessionChange `json:"changes"`
+	Unchanged int                `json:"unchanged"`
+	Applied   bool               `json:"applied"`
+}
+
+func toAPIPlanSync(sync service.PlanSync, applied bool) apiPlanSync {
+	result := apiPlanSync{
+		Before:    toAPIPlan(sync.Before),
+		After:     toAPIPlan(sync.After),
+		Fields:    sync.Fields,
+		Changes:   []apiSessionChange{},
+		Unchanged: sync.Unchanged,
+		Applied:   applied,
+	}
+	result.Before.WorkoutType = sync.WorkoutType
+	result.After.WorkoutType = sync.WorkoutType
+	for _, c := range sync.Changes {
+		change := apiSessionChange{Action: c.Action, Number: c.Number, Fields: c.Fields}
+		if c.Before != nil {
+			before := toAPISession(*c.Before)
+			change.Before = &before
+		}
+		if c.After != nil {
+			after := toAPISession(*c.After)
+			change.After = &after
+		}
+		result.Changes = append(result.Changes, change)
+	}
+	return result
+}
+
+// apiError replies with the error as JSON.
+func apiError(w http.ResponseWriter, r *http.Request, err error) {
+	msg, code := errorStatus(r, err)
+	writeJSONStatus(w, code, map[string]string{"error": msg})
+}
+
+// apiMessage replies with a message translated into the request's language.
+func apiMessage(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	writeJSONStatus(w, code, map[string]string{"error": requestLocale(r).T(msg)})
+}
+
+// decodeJSON reads the request body into v.
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &service.InputError{Message: "Invalid JSON: %v", Args: []interface{}{err}}
+	}
+	return nil
+}
+
+// pathID splits a path like "/api/plans/3/sessions" below prefix into the
+// ID and whatever follows it.
+func pathID(path, prefix string) (int64, string, bool) {
+	rest := strings.TrimPrefix(path, prefix)
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, action, true
+}
+
+func handleAPIWorkoutTypes(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			apiMessage(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		types, err := svc.WorkoutTypes(r.Context())
+		if err != nil {
+			apiError(w, r, err)
+			return
+		}
+		if types == nil {
+			types = []models.WorkoutType{}
+		}
+		writeJSON(w, types)
+	}
+}
+
+// handleAPISettings returns the user's settings, so that clients agree with
+// the web calendar on the time zone and the day weeks start on.
+func handleAPISettings(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			apiMessage(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			apiError(w, r, err)
+			return
+		}
+		writeJSON(w, struct {
+			TimeZone  string `json:"time_zone"`
+			Locale    string `json:"locale"`
+			WeekStart string `json:"week_start"`
+			RestingHR int    `json:"resting_hr"`
+			MaxHR     int    `json:"max_hr"`
+		}{settings.TimeZone, settings.LocaleCode, settings.WeekStart, settings.RestingHR, settings.MaxHR})
+	}
+}
+
+// handleAPIPlans lists plans and creates new ones with all their sessions.
+func handleAPIPlans(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			plans, err := svc.Plans(r.Context())
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+			result := []apiPlan{}
+			for _, plan := range plans {
+				result = append(result, toAPIPlan(plan))
+			}
+			writeJSON(w, result)
+
+		case "POST":
+			var input apiPlan
+			if err := decodeJSON(r, &input); err != nil {
+				apiError(w, r, err)
+				return
+			}
+			plan, err := input.newPlan()
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+
+			created, err := svc.CreatePlan(r.Context(), plan)
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+			writePlanDetail(w, r, svc, created.ID, http.StatusCreated)
+
+		default:
+			apiMessage(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleAPIPlan serves GET /api/plans/{id}, POST /api/plans/{id}/sessions,
+// GET /api/plans/{id}/history, and POST /api/plans/{id}/sync and
+// /api/plans/{id}/revert?change={id}, which only show the changes with
+// ?dry_run=1.
+func handleAPIPlan(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		planID, action, ok := pathID(r.URL.Path, "/api/plans/")
+		if !ok {
+			apiMessage(w, r, "Plan not found", http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case action == "" && r.Method == "GET":
+			writePlanDetail(w, r, svc, planID, http.StatusOK)
+
+		case action == "sessions" && r.Method == "POST":
+			var input apiSession
+			if err := decodeJSON(r, &input); err != nil {
+				apiError(w, r, err)
+				return
+			}
+			session, err := input.newSession(0)
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+
+			created, err := svc.AddSession(r.Context(), planID, session)
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+			writeJSONStatus(w, http.StatusCreated, toAPISession(created))
+
+		case action == "sync" && r.Method == "POST":
+			var input apiPlan
+			if err := decodeJSON(r, &input); err != nil {
+				apiError(w, r, err)
+				return
+			}
+			plan, err := input.newPlan()
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+
+			dryRun := r.URL.Query().Get("dry_run") != ""
+			sync := svc.SyncPlan
+			if dryRun {
+				sync = svc.PreviewSync
+			}
+			result, err := sync(r.Context(), planID, plan)
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+			writeJSON(w, toAPIPlanSync(result, !dryRun))
+
+		case action == "history" && r.Method == "GET":
+			entries, err := svc.PlanHistory(r.Context(), planID)
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+			changes := []models.PlanChange{}
+			for _, e := range entries {
+				changes = append(changes, e.PlanChange)
+			}
+			writeJSON(w, changes)
+
+		case action == "revert" && r.Method == "POST":
+			changeID, err := strconv.ParseInt(r.URL.Query().Get("change"), 10, 64)
+			if err != nil {
+				apiMessage(w, r, "Version not found", http.StatusNotFound)
+				return
+			}
+			dryRun := r.URL.Query().Get("dry_run") != ""
+			revert := svc.RevertPlan
+			if dryRun {
+				revert = svc.PreviewRevert
+			}
+			result, err := revert(r.Context(), planID, changeID)
+			if err != nil {
+				apiError(w, r, err)
+				return
+			}
+			writeJSON(w, toAPIPlanSync(result, !dryRun))
+
+		case action == "" || action == "sessions" || action == "sync" || action == "history" || action == "revert":
+			apiMessage(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+
+		default:
+			apiMessage(w, r, "Not found", http.StatusNotFound)
+		}
+	}
+}
+
+// writePlanDetail replies with a plan and all of its sessions.
+func writePlanDetail(w http.ResponseWriter, r *http.Request, svc *service.Service, planID int64, code int) {
+	detail, err := svc.Plan(r.Context(), planID)
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+
+	plan := toAPIPlan(detail.TrainingPlan)
+	plan.WorkoutType = detail.WorkoutType
+	plan.Sessions = []apiSession{}
+	for _, s := range detail.Sessions {
+		plan.Sessions = append(plan.Sessions, toAPISession(s))
+	}
+	writeJSONStatus(w, code, plan)
+}
+
+// handleAPISession serves POST /api/sessions/{id}/complete.
+func handleAPISession(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, action, ok := pathID(r.URL.Path, "/api/sessions/")
+		if !ok || action != "complete" {
+			apiMessage(w, r, "Not found", http.StatusNotFound)
+			return
+		}
+		if r.Method != "POST" {
+			apiMessage(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Every detail is optional, so an empty body is fine
+		var completion models.Completion
+		if r.ContentLength != 0 {
+			if err := decodeJSON(r, &completion); err != nil {
+				apiError(w, r, err)
+				return
+			}
+		}
+		completion.SessionID = sessionID
+
+		if err := svc.CompleteSession(r.Context(), completion); err != nil {
+			apiError(w, r, err)
+			return
+		}
+
+		session, err := svc.Session(r.Context(), sessionID)
+		if err != nil {
+			apiError(w, r, err)
+			return
+		}
+		writeJSON(w, toAPISession(session))
+	}
+}
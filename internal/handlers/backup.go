@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"training-tracker/internal/backup"
+	"training-tracker/internal/locale"
+)
+
+func handleBackup(backups *backup.Manager, adminToken string) http.HandlerFunc {
+	tmpl := parseTemplate("backup.html", template.FuncMap{
+		"kb": func(size int64) int64 { return (size + 1023) / 1024 },
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			snapshots, err := backups.List()
+			if err != nil {
+				internalError(w, r, err)
+				return
+			}
+
+			data := struct {
+				Snapshots []backup.Snapshot
+				Dir       string
+				Downloads bool
+				Locale    locale.Locale
+			}{
+				Snapshots: snapshots,
+				Dir:       backups.Dir,
+				Downloads: adminToken != "",
+				Locale:    requestLocale(r),
+			}
+
+			render(w, r, tmpl, data)
+		case "POST":
+			if !checkAdminToken(w, r, adminToken, "Backup downloads are off until an admin token is set.") {
+				return
+			}
+
+			// A large database takes longer than the server's write timeout
+			// allows for ordinary pages
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+			// The snapshot is written in full before the response starts so
+			// that a failure still turns into a proper error page
+			snapshot, err := backups.Open(r.Context())
+			if err != nil {
+				if errors.Is(err, backup.ErrUnsupported) {
+					httpError(w, r, "Backups are only supported for SQLite databases.", http.StatusNotImplemented)
+					return
+				}
+				internalError(w, r, fmt.Errorf("backup download: %w", err))
+				return
+			}
+			defer snapshot.Close()
+
+			name := "training-" + time.Now().UTC().Format("20060102-150405") + ".db"
+			w.Header().Set("Content-Type", "application/vnd.sqlite3")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+			http.ServeContent(w, r, name, time.Time{}, snapshot)
+		default:
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
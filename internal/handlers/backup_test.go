@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/backup"
+	"training-tracker/internal/database"
+)
+
+func TestBackupDownloadNeedsToken(t *testing.T) {
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	backups := &backup.Manager{DB: db, Dialect: database.SQLite, Dir: t.TempDir()}
+
+	tests := []struct {
+		name       string
+		adminToken string
+		token      string
+		code       int
+	}{
+		{"no admin token", "", "", 403},
+		{"no admin token, any token", "", "guess", 403},
+		{"wrong token", "secret", "guess", 403},
+		{"missing token", "secret", "", 403},
+		{"token", "secret", "secret", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/admin/backup", strings.NewReader(url.Values{"token": {tt.token}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handleBackup(backups, tt.adminToken)(w, r)
+			if w.Code != tt.code {
+				t.Fatalf("status %d, want %d", w.Code, tt.code)
+			}
+			if tt.code != 200 {
+				return
+			}
+			if w.Header().Get("Content-Type") != "application/vnd.sqlite3" {
+				t.Errorf("downloaded %s", w.Header().Get("Content-Type"))
+			}
+			if !strings.HasPrefix(w.Body.String(), "SQLite format 3\x00") || w.Header().Get("Content-Length") != fmt.Sprint(w.Body.Len()) {
+				t.Errorf("downloaded %d bytes with length %s, want a whole database", w.Body.Len(), w.Header().Get("Content-Length"))
+			}
+		})
+	}
+
+	// Without a token the page offers no download
+	w := httptest.NewRecorder()
+	handleBackup(backups, "")(w, httptest.NewRequest("GET", "/admin/backup", nil))
+	if w.Code != 200 || strings.Contains(w.Body.String(), `method="POST"`) {
+		t.Errorf("status %d, page offers a download: %v", w.Code, strings.Contains(w.Body.String(), `method="POST"`))
+	}
+}
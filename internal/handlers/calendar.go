@@ -1,32 +1,59 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
 	"strconv"
 	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/locale"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
 )
 
 type MonthDay struct {
-    Date          time.Time
-    IsCurrentMonth bool
-    Sessions      []MonthSession
+	Date           time.Time
+	IsCurrentMonth bool
+	IsToday        bool
+	Sessions       []MonthSession
 }
 
-func handleCompleteSession(db *sql.DB) http.HandlerFunc {
+func handleCompleteSession(svc *service.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		sessionID, err := strconv.ParseInt(r.URL.Path[len("/complete-session/"):], 10, 64)
+		if err != nil {
+			httpError(w, r, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			httpError(w, r, "Invalid form data", http.StatusBadRequest)
 			return
 		}
 
-		sessionID := r.URL.Path[len("/complete-session/"):]
-		
-		_, err := db.Exec("UPDATE training_sessions SET completed = 1 WHERE id = ?", sessionID)
+		// Record how the session actually went; every detail is optional
+		completion, err := completionFromForm(r, sessionID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			serviceError(w, r, err)
+			return
+		}
+		if err := svc.CompleteSession(r.Context(), completion); err != nil {
+			if err == service.ErrNotFound {
+				httpError(w, r, "Session not found", http.StatusNotFound)
+				return
+			}
+			serviceError(w, r, err)
 			return
 		}
 
@@ -40,26 +67,63 @@ func handleCompleteSession(db *sql.DB) http.HandlerFunc {
 			}
 		}
 
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		redirect(w, r, redirectURL)
+	}
+}
+
+// completionFromForm reads the optional details of a completed session.
+func completionFromForm(r *http.Request, sessionID int64) (models.Completion, error) {
+	c := models.Completion{SessionID: sessionID}
+
+	ints := []struct {
+		field string
+		msg   string
+		dest  **int
+	}{
+		{"duration_minutes", "Invalid duration", &c.Duration},
+		{"avg_hr", "Invalid heart rate", &c.AvgHR},
+		{"rpe", "Invalid RPE", &c.RPE},
+	}
+	for _, f := range ints {
+		value := r.FormValue(f.field)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return c, &service.InputError{Field: f.field, Message: f.msg}
+		}
+		*f.dest = &v
+	}
+
+	if value := r.FormValue("distance_km"); value != "" {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return c, &service.InputError{Field: "distance_km", Message: "Invalid distance"}
+		}
+		c.DistanceKm = &v
 	}
+	return c, nil
 }
 
 type MonthSession struct {
-    PlanName    string
-    WorkoutType string
-    Date        time.Time
-    Completed   bool
+	PlanName    string
+	WorkoutType string
+	Date        time.Time
+	Completed   bool
 }
 
 type MonthData struct {
-    Days  []MonthDay
-    Month time.Month
-    Year  int
+	Days  []MonthDay
+	Month time.Month
+	Year  int
 }
 
 type CalendarDay struct {
-    Date     time.Time
-    Sessions []SessionWithPlan
+	Date     time.Time
+	IsToday  bool
+	Sessions []SessionWithPlan // Sessions without a start time first
+	Timed    []SessionWithPlan // Sessions with a start time, for the timeline
 }
 
 type SessionWithPlan struct {
@@ -69,29 +133,130 @@ type SessionWithPlan struct {
 	Description string
 	Date        time.Time
 	WorkoutType string
-	HFMax       sql.NullString  // For cycling
+	HFMax       sql.NullString // For cycling
 	Completed   bool
+	Duration    sql.NullInt64  // Planned minutes
+	StartTime   sql.NullString // "15:04", optional
+	// Position on the week timeline in pixels
+	TimelineTop    int
+	TimelineHeight int
+}
+
+const (
+	timelineHourHeight      = 48
+	timelineDefaultDuration = 60
+	timelineStartHour       = 6
+	timelineEndHour         = 22
+)
+
+// layoutTimeline positions the timed sessions of the week on a shared
+// timeline and returns the hours it spans. The default working day is
+// extended to fit early or late sessions.
+func layoutTimeline(days []CalendarDay) []int {
+	first, last := timelineStartHour, timelineEndHour
+	for _, day := range days {
+		for _, s := range day.Timed {
+			start, _ := dates.MinutesOfDay(s.StartTime.String)
+			end := start + timelineDefaultDuration
+			if s.Duration.Valid && s.Duration.Int64 > 0 {
+				end = start + int(s.Duration.Int64)
+			}
+			if start/60 < first {
+				first = start / 60
+			}
+			if (end+59)/60 > last {
+				last = (end + 59) / 60
+			}
+		}
+	}
+	if last > 24 {
+		last = 24
+	}
+
+	for i := range days {
+		for j := range days[i].Timed {
+			s := &days[i].Timed[j]
+			start, _ := dates.MinutesOfDay(s.StartTime.String)
+			duration := timelineDefaultDuration
+			if s.Duration.Valid && s.Duration.Int64 > 0 {
+				duration = int(s.Duration.Int64)
+			}
+			s.TimelineTop = (start - first*60) * timelineHourHeight / 60
+			s.TimelineHeight = duration * timelineHourHeight / 60
+		}
+	}
+
+	hours := make([]int, 0, last-first)
+	for h := first; h < last; h++ {
+		hours = append(hours, h)
+	}
+	return hours
 }
 
 type WorkoutProgress struct {
-    PlanName    string
-    WorkoutType string
-    Completed   int
-    Total       int
-    Percentage  float64
+	PlanID        int64
+	PlanName      string
+	WorkoutType   string
+	Completed     int
+	Total         int
+	Percentage    float64
+	CurrentStreak int
+	LongestStreak int
 }
 
 type CalendarData struct {
-    Days        []CalendarDay
-    CurrentWeek time.Time
-    WeekOffset  int
-    WeekNumber  int
-    Year        int
-    MonthData   MonthData
-    Progress    []WorkoutProgress
+	Days             []CalendarDay
+	CurrentWeek      time.Time
+	WeekOffset       int
+	WeekNumber       int
+	Year             int
+	MonthData        MonthData
+	Progress         []WorkoutProgress
+	Heatmap          HeatmapData
+	Plans            []models.TrainingPlan
+	WorkoutTypes     []models.WorkoutType
+	PlannedMinutes   int
+	CompletedMinutes int
+	TimelineHours    []int
+	TimelineHeight   int
+	Locale           locale.Locale
 }
 
-func handleCalendar(db *sql.DB) http.HandlerFunc {
+// calendarSessions loads the sessions in any of the given ranges with a
+// single query and groups them by date. Within a day they are ordered by
+// start time, sessions without one first.
+func calendarSessions(ctx context.Context, svc *service.Service, ranges ...storage.DateRange) (map[string][]SessionWithPlan, error) {
+	scheduled, err := svc.Schedule(ctx, ranges...)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionsByDate := make(map[string][]SessionWithPlan)
+	for _, s := range scheduled {
+		session := SessionWithPlan{
+			ID:          s.ID,
+			PlanID:      s.PlanID,
+			PlanName:    s.PlanName,
+			Description: s.Description,
+			Date:        s.Date,
+			WorkoutType: s.WorkoutType,
+			HFMax:       sql.NullString{String: s.HFMax, Valid: s.HFMax != ""},
+			Completed:   s.Completed,
+		}
+		if s.Duration != nil {
+			session.Duration = sql.NullInt64{Int64: int64(*s.Duration), Valid: true}
+		}
+		if s.StartTime != nil {
+			session.StartTime = sql.NullString{String: *s.StartTime, Valid: true}
+		}
+		key := session.Date.Format(dates.Layout)
+		sessionsByDate[key] = append(sessionsByDate[key], session)
+	}
+
+	return sessionsByDate, nil
+}
+
+func handleCalendar(svc *service.Service) http.HandlerFunc {
 	// Register template functions
 	funcMap := template.FuncMap{
 		"add": func(a, b int) int {
@@ -110,19 +275,28 @@ func handleCalendar(db *sql.DB) http.HandlerFunc {
 		"multiply": func(a, b int) int {
 			return a * b
 		},
-		"isToday": func(date time.Time) bool {
-			now := time.Now()
-			y1, m1, d1 := date.Date()
-			y2, m2, d2 := now.Date()
-			return y1 == y2 && m1 == m2 && d1 == d2
+		"hours": func(minutes int) string {
+			if minutes < 60 {
+				return fmt.Sprintf("%d min", minutes)
+			}
+			return fmt.Sprintf("%dh %02dmin", minutes/60, minutes%60)
+		},
+		"timelineOffset": func(index int) int {
+			return index * timelineHourHeight
 		},
 	}
-	
-	tmpl := template.Must(template.New("calendar.html").Funcs(funcMap).ParseFiles("internal/templates/calendar.html"))
+
+	tmpl := parseTemplate("calendar.html", funcMap)
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Registered for "/", so this also catches unknown paths, including
+		// those of switched off features
+		if r.URL.Path != "/" {
+			httpError(w, r, "Not found", http.StatusNotFound)
+			return
+		}
 		if r.Method != "GET" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -135,177 +309,143 @@ func handleCalendar(db *sql.DB) http.HandlerFunc {
 			}
 		}
 
-		// Get Monday of the requested week
-		now := time.Now()
-		monday := now.AddDate(0, 0, -int(now.Weekday())+1)
-		monday = monday.AddDate(0, 0, weekOffset*7)
-		
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		// Get the first day of the requested week in the user's time zone
+		lc := requestLocale(r)
+		now := dates.Today(settings.Location())
+		weekStart := lc.StartOfWeek(now).AddDate(0, 0, weekOffset*7)
+
+		// The month grid always shows the current month, which may be far
+		// from the requested week, and starts with a partial week
+		firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		firstDisplayDay := lc.StartOfWeek(firstOfMonth)
+
+		queryStart := time.Now()
+
+		sessionsByDate, err := calendarSessions(r.Context(), svc,
+			storage.DateRange{From: weekStart, To: weekStart.AddDate(0, 0, 7)},
+			storage.DateRange{From: firstDisplayDay, To: firstDisplayDay.AddDate(0, 0, 42)},
+		)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		streaks, err := svc.Streaks(r.Context(), now)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		// Query timings are for development only, they tell outsiders more
+		// than they need to know
+		if ReloadTemplates {
+			w.Header().Set("Server-Timing", fmt.Sprintf("db;dur=%.2f", float64(time.Since(queryStart).Microseconds())/1000))
+		}
+
+		// Choices of the heatmap filter
+		plans, err := svc.Plans(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		workoutTypes, err := svc.WorkoutTypes(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
 		// Create slice for 7 days
 		days := make([]CalendarDay, 7)
-		
-		// For each day of the week
-		for i := 0; i < 7; i++ {
-			currentDate := monday.AddDate(0, 0, i)
-			
-			// Get sessions with plan names for this day
-			rows, err := db.Query(`
-				SELECT 
-					ts.id, 
-					ts.plan_id, 
-					p.name, 
-					ts.description, 
-					ts.date,
-					wt.name as workout_type,
-					COALESCE(cs.hfmax, '') as hfmax,
-					ts.completed
-				FROM training_sessions ts 
-				JOIN training_plans p ON ts.plan_id = p.id
-				JOIN workout_types wt ON p.workout_type_id = wt.id
-				LEFT JOIN cycling_sessions cs ON ts.id = cs.session_id
-				WHERE DATE(ts.date) = DATE(?)
-				ORDER BY ts.date
-			`, currentDate.Format("2006-01-02"))
-			
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer rows.Close()
-
-			var sessions []SessionWithPlan
-			for rows.Next() {
-				var session SessionWithPlan
-				err := rows.Scan(
-					&session.ID, 
-					&session.PlanID, 
-					&session.PlanName, 
-					&session.Description, 
-					&session.Date,
-					&session.WorkoutType,
-					&session.HFMax,
-					&session.Completed,
-				)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
+		plannedMinutes, completedMinutes := 0, 0
+
+		for i := range days {
+			currentDate := weekStart.AddDate(0, 0, i)
+			sessions := sessionsByDate[currentDate.Format(dates.Layout)]
+
+			var timed []SessionWithPlan
+			for _, session := range sessions {
+				if _, ok := dates.MinutesOfDay(session.StartTime.String); ok {
+					timed = append(timed, session)
+				}
+				if session.Duration.Valid {
+					plannedMinutes += int(session.Duration.Int64)
+					if session.Completed {
+						completedMinutes += int(session.Duration.Int64)
+					}
 				}
-				sessions = append(sessions, session)
 			}
 
 			days[i] = CalendarDay{
 				Date:     currentDate,
+				IsToday:  currentDate.Equal(now),
 				Sessions: sessions,
+				Timed:    timed,
 			}
 		}
 
-		year, week := monday.ISOWeek()
-		// Calculate progress for each workout type
+		timelineHours := layoutTimeline(days)
+
+		year, week := lc.ISOWeek(weekStart)
+
+		// Progress per plan comes with the streaks, which see every session
+		// due up to today anyway
 		progress := []WorkoutProgress{}
-		
-		progressRows, err := db.Query(`
-			WITH workout_sessions AS (
-				SELECT 
-					p.name as plan_name,
-					wt.name as workout_type,
-					ts.completed,
-					ts.date
-				FROM training_sessions ts 
-				JOIN training_plans p ON ts.plan_id = p.id
-				JOIN workout_types wt ON p.workout_type_id = wt.id
-				WHERE ts.date <= ?
-			)
-			SELECT 
-				plan_name,
-				workout_type,
-				SUM(CASE WHEN completed = 1 THEN 1 ELSE 0 END) as completed,
-				COUNT(*) as total
-			FROM workout_sessions
-			GROUP BY plan_name, workout_type
-			HAVING total > 0
-		`, 
-			time.Now().Format("2006-01-02"),
-		)
-		
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer progressRows.Close()
-
-		for progressRows.Next() {
-			var p WorkoutProgress
-			var completed, total int
-			err := progressRows.Scan(&p.PlanName, &p.WorkoutType, &completed, &total)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			p.Completed = completed
-			p.Total = total
-			p.Percentage = float64(completed) / float64(total) * 100
-			progress = append(progress, p)
+		for _, s := range streaks {
+			progress = append(progress, WorkoutProgress{
+				PlanID:        s.PlanID,
+				PlanName:      s.PlanName,
+				WorkoutType:   s.WorkoutType,
+				Completed:     s.Completed,
+				Total:         s.Total,
+				Percentage:    float64(s.Completed) / float64(s.Total) * 100,
+				CurrentStreak: s.Current,
+				LongestStreak: s.Longest,
+			})
 		}
 
 		data := CalendarData{
-			Days:        days,
-			CurrentWeek: monday,
-			WeekOffset:  weekOffset,
-			WeekNumber:  week,
-			Year:        year,
-			Progress:    progress,
+			Days:             days,
+			CurrentWeek:      weekStart,
+			WeekOffset:       weekOffset,
+			WeekNumber:       week,
+			Year:             year,
+			Progress:         progress,
+			Heatmap:          heatmapSelection(r, settings),
+			Plans:            plans,
+			WorkoutTypes:     workoutTypes,
+			PlannedMinutes:   plannedMinutes,
+			CompletedMinutes: completedMinutes,
+			TimelineHours:    timelineHours,
+			TimelineHeight:   len(timelineHours) * timelineHourHeight,
+			Locale:           lc,
 		}
 
-		// Get the first day of the current month
-		firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-
-		// Get the first day to display (might be from previous month)
-		firstDisplayDay := firstOfMonth.AddDate(0, 0, -int(firstOfMonth.Weekday()))
-		if firstOfMonth.Weekday() == 0 { // If month starts on Sunday
-			firstDisplayDay = firstDisplayDay.AddDate(0, 0, -6)
-		}
-
-		// Create slice for up to 42 days (6 weeks)
+		// Fill in the month days, up to 42 days (6 weeks)
 		monthDays := make([]MonthDay, 42)
+		for i := range monthDays {
+			currentDate := firstDisplayDay.AddDate(0, 0, i)
 
-		// Get all sessions for the displayed date range
-		monthSessions, err := db.Query(`
-			SELECT p.name, wt.name, ts.date, ts.completed 
-			FROM training_sessions ts 
-			JOIN training_plans p ON ts.plan_id = p.id
-			JOIN workout_types wt ON p.workout_type_id = wt.id
-			WHERE ts.date >= ? AND ts.date <= ?
-			ORDER BY ts.date
-		`, firstDisplayDay.Format("2006-01-02"), firstDisplayDay.AddDate(0, 0, 41).Format("2006-01-02"))
-
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer monthSessions.Close()
-
-		// Create a map to store sessions by date
-		sessionsByDate := make(map[string][]MonthSession)
-		for monthSessions.Next() {
-			var session MonthSession
-			var date time.Time
-			err := monthSessions.Scan(&session.PlanName, &session.WorkoutType, &date, &session.Completed)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			var sessions []MonthSession
+			for _, session := range sessionsByDate[currentDate.Format(dates.Layout)] {
+				sessions = append(sessions, MonthSession{
+					PlanName:    session.PlanName,
+					WorkoutType: session.WorkoutType,
+					Date:        session.Date,
+					Completed:   session.Completed,
+				})
 			}
-			dateKey := date.Format("2006-01-02")
-			sessionsByDate[dateKey] = append(sessionsByDate[dateKey], session)
-		}
 
-		// Fill in the month days
-		for i := 0; i < 42; i++ {
-			currentDate := firstDisplayDay.AddDate(0, 0, i)
-			dateKey := currentDate.Format("2006-01-02")
-			
 			monthDays[i] = MonthDay{
-				Date:          currentDate,
+				Date:           currentDate,
 				IsCurrentMonth: currentDate.Month() == now.Month(),
-				Sessions:      sessionsByDate[dateKey],
+				IsToday:        currentDate.Equal(now),
+				Sessions:       sessions,
 			}
 		}
 
@@ -316,9 +456,6 @@ func handleCalendar(db *sql.DB) http.HandlerFunc {
 			Year:  now.Year(),
 		}
 
-		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		render(w, r, tmpl, data)
 	}
 }
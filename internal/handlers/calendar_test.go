@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+)
+
+// seedYears fills a service with a session every day for the given number
+// of years around today, one plan per workout type, the past ones mostly
+// completed.
+func seedYears(tb testing.TB, svc *service.Service, years int) {
+	tb.Helper()
+	ctx := context.Background()
+	today := dates.Today(time.Local)
+	first := today.AddDate(-years+1, 0, 0)
+	last := today.AddDate(1, 0, 0)
+
+	for i, workoutType := range []string{"cycling", "mobility", "sandbag", "core"} {
+		var sessions []service.NewSession
+		for day := first; day.Before(last); day = day.AddDate(0, 0, 1) {
+			sessions = append(sessions, service.NewSession{
+				Description: "45 min " + workoutType,
+				Date:        day,
+				StartTime:   fmt.Sprintf("%02d:00", 6+4*i),
+			})
+		}
+		planID := createTestPlan(tb, svc, workoutType, sessions...)
+
+		plan, err := svc.Plan(ctx, planID)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for j, s := range plan.Sessions {
+			if s.Date.Before(today) && j%5 != 0 {
+				if err := svc.CompleteSession(ctx, models.Completion{SessionID: s.ID}); err != nil {
+					tb.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestCalendarServerTiming(t *testing.T) {
+	svc := newTestService(t)
+	handler := handleCalendar(svc)
+
+	for _, dev := range []bool{false, true} {
+		ReloadTemplates = dev
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != 200 {
+			t.Fatalf("status %d", w.Code)
+		}
+		if got := w.Header().Get("Server-Timing") != ""; got != dev {
+			t.Errorf("Server-Timing sent: %v, want %v in development mode %v", got, dev, dev)
+		}
+	}
+	ReloadTemplates = false
+}
+
+func BenchmarkCalendar(b *testing.B) {
+	svc := newTestService(b)
+	seedYears(b, svc, 5)
+	handler := handleCalendar(svc)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/?weekOffset=-3", nil))
+		if w.Code != 200 {
+			b.Fatalf("status %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"training-tracker/internal/locale"
+	"training-tracker/internal/service"
+)
+
+func handleBackfillDurations(svc *service.Service, adminToken string) http.HandlerFunc {
+	tmpl := parseTemplate("backfill_durations.html", nil)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "POST" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if r.Method == "POST" && !checkAdminToken(w, r, adminToken, "Storing durations is off until an admin token is set.") {
+			return
+		}
+
+		candidates, err := svc.DurationCandidates(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		var parsed, unparsed []service.DurationCandidate
+		for _, c := range candidates {
+			if c.Parsed {
+				parsed = append(parsed, c)
+			} else {
+				unparsed = append(unparsed, c)
+			}
+		}
+
+		applied := false
+		if r.Method == "POST" {
+			if err := svc.BackfillDurations(r.Context(), parsed); err != nil {
+				internalError(w, r, err)
+				return
+			}
+			applied = true
+		}
+
+		data := struct {
+			Parsed   []service.DurationCandidate
+			Unparsed []service.DurationCandidate
+			Applied  bool
+			Backfill bool
+			Locale   locale.Locale
+		}{
+			Parsed:   parsed,
+			Unparsed: unparsed,
+			Applied:  applied,
+			Backfill: adminToken != "",
+			Locale:   requestLocale(r),
+		}
+
+		render(w, r, tmpl, data)
+	}
+}
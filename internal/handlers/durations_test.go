@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+func TestBackfillDurationsNeedsToken(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	svc := service.New(storage.NewSQLite(db))
+	createTestPlan(t, svc, "cycling", service.NewSession{Description: "GA1 60min", Date: mustDate(t, "2025-03-03")})
+
+	// Like sessions stored before durations were parsed on import
+	missing := func() {
+		t.Helper()
+		if _, err := db.Exec("UPDATE training_sessions SET duration_minutes = NULL"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := func() bool {
+		t.Helper()
+		candidates, err := svc.DurationCandidates(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(candidates) == 0
+	}
+
+	tests := []struct {
+		name       string
+		adminToken string
+		token      string
+		code       int
+	}{
+		{"no admin token", "", "", 403},
+		{"no admin token, any token", "", "guess", 403},
+		{"wrong token", "secret", "guess", 403},
+		{"missing token", "secret", "", 403},
+		{"token", "secret", "secret", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing()
+			r := httptest.NewRequest("POST", "/admin/durations", strings.NewReader(url.Values{"token": {tt.token}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handleBackfillDurations(svc, tt.adminToken)(w, r)
+			if w.Code != tt.code {
+				t.Fatalf("status %d, want %d", w.Code, tt.code)
+			}
+			if got := stored(); got != (tt.code == 200) {
+				t.Errorf("stored durations: %v", got)
+			}
+		})
+	}
+
+	// Without a token the page lists the durations but offers no form
+	missing()
+	w := httptest.NewRecorder()
+	handleBackfillDurations(svc, "")(w, httptest.NewRequest("GET", "/admin/durations", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "GA1 60min") || strings.Contains(w.Body.String(), `method="POST"`) {
+		t.Errorf("status %d, page offers a form: %v", w.Code, strings.Contains(w.Body.String(), `method="POST"`))
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/service"
+)
+
+// Bad requests are answered with a message of our own, not the parser's.
+func TestBadRequestMessages(t *testing.T) {
+	svc := newTestService(t)
+	planID := createTestPlan(t, svc, "core", service.NewSession{Description: "Plank", Date: mustDate(t, "2025-03-03")})
+	detail, err := svc.Plan(context.Background(), planID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+		want    string
+	}{
+		{
+			name:    "calendar range",
+			handler: handleICS(svc),
+			req:     httptest.NewRequest("GET", "/calendar.ics?from=3.3.2025", nil),
+			want:    `Invalid from date "3.3.2025"`,
+		},
+		{
+			name:    "sessions range",
+			handler: handleSessionsJSON(svc),
+			req:     httptest.NewRequest("GET", "/api/sessions?to=soon", nil),
+			want:    `Invalid to date "soon"`,
+		},
+		{
+			name:    "form",
+			handler: handleCompleteSession(svc),
+			req:     httptest.NewRequest("POST", fmt.Sprint("/complete-session/", detail.Sessions[0].ID), strings.NewReader("rpe=%zz")),
+			want:    "Invalid form data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			tt.handler(rec, tt.req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if body := strings.TrimSpace(rec.Body.String()); body != tt.want {
+				t.Errorf("got %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONUnencodable(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, map[string]interface{}{"channel": make(chan int)})
+	if rec.Code != http.StatusInternalServerError || rec.Body.Len() != 0 {
+		t.Errorf("got status %d with %q, want an empty internal error", rec.Code, rec.Body.String())
+	}
+}
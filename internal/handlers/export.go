@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+type SessionEvent struct {
+	ID          int64      `json:"id"`
+	PlanID      int64      `json:"plan_id"`
+	PlanName    string     `json:"plan_name"`
+	WorkoutType string     `json:"workout_type"`
+	Description string     `json:"description"`
+	Date        string     `json:"date"`
+	StartTime   string     `json:"start_time,omitempty"`
+	Duration    int        `json:"duration_minutes,omitempty"`
+	Start       *time.Time `json:"start,omitempty"`
+	End         *time.Time `json:"end,omitempty"`
+	Completed   bool       `json:"completed"`
+}
+
+// sessionsBetween loads all sessions from the from day up to but excluding
+// the to day, ordered by day and start time. Sessions with a start time get
+// their start and end filled in.
+func sessionsBetween(ctx context.Context, svc *service.Service, from, to time.Time, loc *time.Location) ([]SessionEvent, error) {
+	sessions, err := svc.Schedule(ctx, storage.DateRange{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+
+	events := []SessionEvent{}
+	for _, s := range sessions {
+		e := SessionEvent{
+			ID:          s.ID,
+			PlanID:      s.PlanID,
+			PlanName:    s.PlanName,
+			WorkoutType: s.WorkoutType,
+			Description: s.Description,
+			Date:        s.Date.Format(dates.Layout),
+			Completed:   s.Completed,
+		}
+		if s.StartTime != nil {
+			e.StartTime = *s.StartTime
+		}
+		if s.Duration != nil {
+			e.Duration = *s.Duration
+		}
+
+		if start, ok := dates.Start(s.Date, e.StartTime, loc); ok {
+			duration := e.Duration
+			if duration <= 0 {
+				duration = timelineDefaultDuration
+			}
+			end := start.Add(time.Duration(duration) * time.Minute)
+			e.Start, e.End = &start, &end
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// rangeFromRequest reads the from and to query parameters (YYYY-MM-DD) and
+// falls back to the given number of days around today in loc.
+func rangeFromRequest(r *http.Request, loc *time.Location, daysBack, daysAhead int) (time.Time, time.Time, error) {
+	today := dates.Today(loc)
+	from := today.AddDate(0, 0, -daysBack)
+	to := today.AddDate(0, 0, daysAhead)
+
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = dates.Parse(value); err != nil {
+			return from, to, &service.InputError{Field: "from", Message: "Invalid from date %q", Args: []interface{}{value}}
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = dates.Parse(value); err != nil {
+			return from, to, &service.InputError{Field: "to", Message: "Invalid to date %q", Args: []interface{}{value}}
+		}
+	}
+	return from, to, nil
+}
+
+func handleSessionsJSON(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		from, to, err := rangeFromRequest(r, settings.Location(), 7, 28)
+		if err != nil {
+			serviceError(w, r, err)
+			return
+		}
+
+		events, err := sessionsBetween(r.Context(), svc, from, to, settings.Location())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		writeJSON(w, events)
+	}
+}
+
+// icsEscape escapes a text value as required by RFC 5545.
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icsLine writes a content line, folded at 75 octets.
+func icsLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Do not split multi-byte characters
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space
+		limit = 74
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// renderICS builds an iCalendar feed. Sessions with a start time become
+// timed events in UTC, all others all-day events on their calendar date.
+func renderICS(events []SessionEvent, now time.Time) string {
+	var b strings.Builder
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//training-tracker//EN")
+	icsLine(&b, "CALSCALE:GREGORIAN")
+
+	for _, e := range events {
+		icsLine(&b, "BEGIN:VEVENT")
+		icsLine(&b, fmt.Sprintf("UID:session-%d@training-tracker", e.ID))
+		icsLine(&b, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+		if e.Start != nil {
+			icsLine(&b, "DTSTART:"+e.Start.UTC().Format("20060102T150405Z"))
+			icsLine(&b, "DTEND:"+e.End.UTC().Format("20060102T150405Z"))
+		} else {
+			day, _ := dates.Parse(e.Date)
+			icsLine(&b, "DTSTART;VALUE=DATE:"+day.Format("20060102"))
+			icsLine(&b, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format("20060102"))
+		}
+		icsLine(&b, "SUMMARY:"+icsEscape(fmt.Sprintf("%s (%s)", e.PlanName, e.WorkoutType)))
+		if e.Description != "" {
+			icsLine(&b, "DESCRIPTION:"+icsEscape(e.Description))
+		}
+		if e.Completed {
+			icsLine(&b, "STATUS:CONFIRMED")
+		} else {
+			icsLine(&b, "STATUS:TENTATIVE")
+		}
+		icsLine(&b, "END:VEVENT")
+	}
+
+	icsLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+func handleICS(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		from, to, err := rangeFromRequest(r, settings.Location(), 90, 365)
+		if err != nil {
+			serviceError(w, r, err)
+			return
+		}
+
+		events, err := sessionsBetween(r.Context(), svc, from, to, settings.Location())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		fmt.Fprint(w, renderICS(events, time.Now()))
+	}
+}
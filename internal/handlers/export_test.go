@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"training-tracker/internal/service"
+)
+
+func TestSessionsBetweenStartInZone(t *testing.T) {
+	svc := newTestService(t)
+	createTestPlan(t, svc, "core",
+		service.NewSession{Description: "Evening", Date: mustDate(t, "2025-03-03"), StartTime: "18:30", Duration: 20},
+		service.NewSession{Description: "After the clocks changed", Date: mustDate(t, "2025-03-31"), StartTime: "07:00"},
+		service.NewSession{Description: "Any time", Date: mustDate(t, "2025-03-04"), Duration: 15},
+	)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := sessionsBetween(context.Background(), svc, mustDate(t, "2025-03-01"), mustDate(t, "2025-04-01"), berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []struct {
+		Description string `json:"description"`
+		Start       string `json:"start"`
+		End         string `json:"end"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][2]string{
+		"Evening":                  {"2025-03-03T18:30:00+01:00", "2025-03-03T18:50:00+01:00"},
+		"Any time":                 {"", ""},
+		"After the clocks changed": {"2025-03-31T07:00:00+02:00", "2025-03-31T08:00:00+02:00"},
+	}
+	if len(decoded) != len(want) {
+		t.Fatalf("got %d events, want %d", len(decoded), len(want))
+	}
+	for _, e := range decoded {
+		if w := want[e.Description]; e.Start != w[0] || e.End != w[1] {
+			t.Errorf("%s: start %q, end %q, want %q, %q", e.Description, e.Start, e.End, w[0], w[1])
+		}
+	}
+}
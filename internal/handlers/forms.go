@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"training-tracker/internal/service"
+)
+
+// formErrors are the translated problems of a submitted form by field, for
+// showing them next to the fields when the form is shown again.
+type formErrors map[string][]string
+
+// addError adds a problem of a field, translated into the request's
+// language, unless the field has the same problem already.
+func (f formErrors) addError(r *http.Request, field, msg string, args ...interface{}) {
+	msg = requestLocale(r).T(msg, args...)
+	if !slices.Contains(f[field], msg) {
+		f[field] = append(f[field], msg)
+	}
+}
+
+// add sorts the problems in err from the service by field. Problems of
+// fields that are not in fields go to fallback, and fields that already have
+// a problem keep only that one, so that a value that could not be read is not
+// reported again as missing. It reports false if err is not about the input;
+// such errors are for serviceError.
+func (f formErrors) add(r *http.Request, err error, fallback string, fields ...string) bool {
+	var problems service.FieldErrors
+	var input *service.InputError
+	switch {
+	case errors.As(err, &problems):
+	case errors.As(err, &input):
+		problems = service.FieldErrors{input}
+	default:
+		return false
+	}
+
+	before := make(map[string]bool, len(f))
+	for field := range f {
+		before[field] = true
+	}
+	for _, p := range problems {
+		field := fallback
+		for _, known := range fields {
+			if p.Field == known {
+				field = known
+			}
+		}
+		if !before[field] || field == fallback {
+			f.addError(r, field, p.Message, p.Args...)
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+// newTestService returns a service on a fresh in-memory SQLite database
+// with the default workout types.
+func newTestService(t testing.TB) *service.Service {
+	t.Helper()
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	return service.New(storage.NewSQLite(db))
+}
+
+// createTestPlan creates a plan of the given workout type with sessions.
+func createTestPlan(t testing.TB, svc *service.Service, workoutType string, sessions ...service.NewSession) int64 {
+	t.Helper()
+	ctx := context.Background()
+	types, err := svc.WorkoutTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := service.NewPlan{Name: "Test plan", Sessions: sessions}
+	for _, wt := range types {
+		if wt.Name == workoutType {
+			plan.WorkoutTypeID = wt.ID
+		}
+	}
+	created, err := svc.CreatePlan(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.ID
+}
+
+func mustDate(t testing.TB, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
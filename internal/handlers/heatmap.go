@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/locale"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+type HeatmapDay struct {
+	Date     string `json:"date"`
+	Sessions int    `json:"sessions"`
+	Minutes  int    `json:"minutes"`
+}
+
+type HeatmapData struct {
+	Year        int          `json:"year"`
+	Metric      string       `json:"metric"`
+	WorkoutType string       `json:"workout_type,omitempty"`
+	PlanID      int64        `json:"plan_id,omitempty"`
+	Max         int          `json:"max"`
+	Days        []HeatmapDay `json:"days"`
+}
+
+// value returns the number the heatmap is colored by for the given day.
+func (d HeatmapData) value(day HeatmapDay) int {
+	if d.Metric == "duration" {
+		return day.Minutes
+	}
+	return day.Sessions
+}
+
+// Query returns the query string that selects the same heatmap, escaped
+// already for use in links.
+func (d HeatmapData) Query() template.URL {
+	query := url.Values{"year": {strconv.Itoa(d.Year)}}
+	if d.Metric != "count" {
+		query.Set("metric", d.Metric)
+	}
+	if d.WorkoutType != "" {
+		query.Set("type", d.WorkoutType)
+	}
+	if d.PlanID != 0 {
+		query.Set("plan", strconv.FormatInt(d.PlanID, 10))
+	}
+	return template.URL(query.Encode())
+}
+
+// heatmapSelection reads the year, metric, type and plan query parameters.
+func heatmapSelection(r *http.Request, settings service.Settings) HeatmapData {
+	query := r.URL.Query()
+
+	data := HeatmapData{
+		Year:        dates.Today(settings.Location()).Year(),
+		Metric:      "count",
+		WorkoutType: query.Get("type"),
+	}
+	if year, err := strconv.Atoi(query.Get("year")); err == nil {
+		data.Year = year
+	}
+	if query.Get("metric") == "duration" {
+		data.Metric = "duration"
+	}
+	if planID, err := strconv.ParseInt(query.Get("plan"), 10, 64); err == nil {
+		data.PlanID = planID
+	}
+	return data
+}
+
+// heatmapFromRequest loads the completed sessions per day for the selection
+// of the query parameters.
+func heatmapFromRequest(svc *service.Service, r *http.Request, settings service.Settings) (HeatmapData, error) {
+	data := heatmapSelection(r, settings)
+
+	from := time.Date(data.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	days, err := svc.CompletedPerDay(r.Context(),
+		storage.DateRange{From: from, To: to},
+		storage.StatsFilter{WorkoutType: data.WorkoutType, PlanID: data.PlanID},
+	)
+	if err != nil {
+		return data, err
+	}
+
+	data.Days = []HeatmapDay{}
+	for _, total := range days {
+		day := HeatmapDay{Date: total.Date, Sessions: total.Sessions, Minutes: total.Minutes}
+		if v := data.value(day); v > data.Max {
+			data.Max = v
+		}
+		data.Days = append(data.Days, day)
+	}
+
+	return data, nil
+}
+
+// heatmapColors are the fill colors from "nothing done" to "busiest day".
+var heatmapColors = []string{"#ebedf0", "#9be9a8", "#40c463", "#30a14e", "#216e39"}
+
+const (
+	heatmapCell   = 11
+	heatmapGap    = 2
+	heatmapLeft   = 28
+	heatmapTop    = 16
+	heatmapStride = heatmapCell + heatmapGap
+)
+
+// renderHeatmapSVG draws one column per week and one row per weekday,
+// starting on the locale's first day of the week, in the style of the GitHub
+// contribution graph.
+func renderHeatmapSVG(data HeatmapData, lc locale.Locale) string {
+	values := make(map[string]HeatmapDay, len(data.Days))
+	for _, day := range data.Days {
+		values[day.Date] = day
+	}
+
+	first := time.Date(data.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(1, 0, -1)
+	// Align the grid to the start of the week containing January 1st
+	start := lc.StartOfWeek(first)
+	weeks := int(last.Sub(start).Hours()/24)/7 + 1
+
+	width := heatmapLeft + weeks*heatmapStride
+	height := heatmapTop + 7*heatmapStride
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Arial, sans-serif" font-size="9" fill="#666">`,
+		width, height, width, height)
+
+	// Label every other row, like the contribution graph
+	for i, label := range lc.ShortWeekdays() {
+		if i%2 == 0 && i < 6 {
+			fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, heatmapTop+i*heatmapStride+heatmapCell-2, label)
+		}
+	}
+
+	for month := time.January; month <= time.December; month++ {
+		firstOfMonth := time.Date(data.Year, month, 1, 0, 0, 0, 0, time.UTC)
+		week := int(firstOfMonth.Sub(start).Hours()/24) / 7
+		fmt.Fprintf(&b, `<text x="%d" y="10">%s</text>`, heatmapLeft+week*heatmapStride, lc.ShortMonth(month))
+	}
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		offset := int(day.Sub(start).Hours() / 24)
+		x := heatmapLeft + (offset/7)*heatmapStride
+		y := heatmapTop + (offset%7)*heatmapStride
+
+		key := day.Format(dates.Layout)
+		entry := values[key]
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"><title>%s</title></rect>`,
+			x, y, heatmapCell, heatmapCell, heatmapColor(data.value(entry), data.Max),
+			lc.T("%s: %d sessions, %d min", lc.LongDate(day), entry.Sessions, entry.Minutes))
+	}
+
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// heatmapColor maps a value onto one of the heatmap color levels.
+func heatmapColor(value, max int) string {
+	if value <= 0 || max <= 0 {
+		return heatmapColors[0]
+	}
+	levels := len(heatmapColors) - 1
+	level := (value*levels + max - 1) / max
+	if level > levels {
+		level = levels
+	}
+	return heatmapColors[level]
+}
+
+func handleHeatmapSVG(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		data, err := heatmapFromRequest(svc, r, settings)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, renderHeatmapSVG(data, requestLocale(r)))
+	}
+}
+
+func handleHeatmapJSON(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		data, err := heatmapFromRequest(svc, r, settings)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		writeJSON(w, data)
+	}
+}
+
+func handleStreaks(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		streaks, err := svc.Streaks(r.Context(), dates.Today(settings.Location()))
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		writeJSON(w, streaks)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"training-tracker/internal/locale"
+)
+
+var (
+	heatmapRect  = regexp.MustCompile(`<rect x="(\d+)" y="(\d+)" [^>]*fill="([^"]+)"><title>([^<:]*):`)
+	heatmapLabel = regexp.MustCompile(`<text x="(\d+)" y="(\d+)">([^<]+)</text>`)
+)
+
+type heatmapCellAt struct {
+	x, y int
+	fill string
+}
+
+func TestRenderHeatmapSVG(t *testing.T) {
+	tests := []struct {
+		name      string
+		year      int
+		weekStart time.Weekday
+		// Cells of January 1st and December 31st
+		first, last heatmapCellAt
+		// Columns of the labels of March and December
+		march, december int
+	}{
+		{
+			name:      "year starting midweek",
+			year:      2025,
+			weekStart: time.Monday,
+			first:     heatmapCellAt{28, 42, "#216e39"},
+			last:      heatmapCellAt{704, 42, "#ebedf0"},
+			march:     132,
+			december:  652,
+		},
+		{
+			name:      "weeks from Sunday",
+			year:      2025,
+			weekStart: time.Sunday,
+			first:     heatmapCellAt{28, 55, "#216e39"},
+			last:      heatmapCellAt{704, 55, "#ebedf0"},
+			march:     132,
+			december:  652,
+		},
+		{
+			name:      "leap year starting on the week start",
+			year:      2024,
+			weekStart: time.Monday,
+			first:     heatmapCellAt{28, 16, "#216e39"},
+			last:      heatmapCellAt{704, 29, "#ebedf0"},
+			march:     132,
+			december:  639,
+		},
+		{
+			name:      "year ending on the week start",
+			year:      2023,
+			weekStart: time.Sunday,
+			first:     heatmapCellAt{28, 16, "#216e39"},
+			last:      heatmapCellAt{704, 16, "#ebedf0"},
+			march:     132,
+			december:  639,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := locale.Get("en").WithWeekStart(tt.weekStart)
+			first := time.Date(tt.year, time.January, 1, 0, 0, 0, 0, time.UTC)
+			last := time.Date(tt.year, time.December, 31, 0, 0, 0, 0, time.UTC)
+			data := HeatmapData{
+				Year:   tt.year,
+				Metric: "count",
+				Max:    2,
+				Days:   []HeatmapDay{{Date: first.Format("2006-01-02"), Sessions: 2}},
+			}
+			svg := renderHeatmapSVG(data, lc)
+
+			// 53 columns of weeks after the weekday labels
+			if !strings.Contains(svg, `width="717" height="107"`) {
+				t.Errorf("unexpected size: %.200s", svg)
+			}
+
+			cells := make(map[string]heatmapCellAt)
+			for _, m := range heatmapRect.FindAllStringSubmatch(svg, -1) {
+				x, _ := strconv.Atoi(m[1])
+				y, _ := strconv.Atoi(m[2])
+				cells[m[4]] = heatmapCellAt{x, y, m[3]}
+			}
+			if days := first.AddDate(1, 0, 0).Sub(first).Hours() / 24; len(cells) != int(days) {
+				t.Errorf("got %d days, want %v", len(cells), days)
+			}
+			if got := cells[lc.LongDate(first)]; got != tt.first {
+				t.Errorf("January 1st at %+v, want %+v", got, tt.first)
+			}
+			if got := cells[lc.LongDate(last)]; got != tt.last {
+				t.Errorf("December 31st at %+v, want %+v", got, tt.last)
+			}
+
+			labels := make(map[string]int)
+			for _, m := range heatmapLabel.FindAllStringSubmatch(svg, -1) {
+				x, _ := strconv.Atoi(m[1])
+				if m[2] == "10" {
+					labels[m[3]] = x
+				} else if x != 0 {
+					t.Errorf("weekday label %s in column %d", m[3], x)
+				}
+			}
+			want := map[string]int{"Jan": 28, "Mar": tt.march, "Dec": tt.december}
+			for month, x := range want {
+				if labels[month] != x {
+					t.Errorf("%s labeled in column %d, want %d", month, labels[month], x)
+				}
+			}
+			if len(labels) != 12 {
+				t.Errorf("got month labels %v, want 12", labels)
+			}
+
+			// Rows are labeled from the first day of the week on
+			if label := `<text x="0" y="25">` + lc.ShortWeekdays()[0] + `</text>`; !strings.Contains(svg, label) {
+				t.Errorf("first row is not labeled %s", lc.ShortWeekdays()[0])
+			}
+		})
+	}
+}
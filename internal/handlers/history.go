@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+)
+
+// historyActions are the labels of the actions of the plan history.
+var historyActions = map[string]string{
+	service.ActionCreatePlan:      "Plan created",
+	service.ActionAddSession:      "Session added",
+	service.ActionCompleteSession: "Session completed",
+	service.ActionSetDuration:     "Planned duration filled in",
+	service.ActionSyncPlan:        "Synced from YAML",
+	service.ActionRevertPlan:      "Reverted",
+}
+
+// historyRow is an entry of the history page.
+type historyRow struct {
+	ID      int64
+	Time    string
+	Actor   string
+	Action  string
+	Summary string
+	// The newest version is the current one, which there is no point in
+	// reverting to
+	Revertable bool
+}
+
+// handlePlanHistory lists who changed a plan and its sessions, and when.
+func handlePlanHistory(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("plan_history.html", nil)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		plan, ok := historyPlan(w, r, svc)
+		if !ok {
+			return
+		}
+		entries, err := svc.PlanHistory(r.Context(), plan.ID)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		loc := requestLocale(r)
+		zone := settings.Location()
+		rows := []historyRow{}
+		for i, e := range entries {
+			at := e.CreatedAt.In(zone)
+			row := historyRow{
+				ID:         e.ID,
+				Time:       loc.LongDate(at) + ", " + at.Format("15:04"),
+				Actor:      e.Actor,
+				Action:     loc.T(historyActions[e.Action]),
+				Revertable: i > 0,
+			}
+			if row.Action == "" {
+				row.Action = e.Action
+			}
+			switch {
+			case e.Session != nil:
+				row.Summary = loc.LongDate(e.Session.Date) + ": " + e.Session.Description
+			case e.Action == service.ActionCreatePlan:
+				row.Summary = loc.T("%d sessions", e.Diff.Count(service.SyncAdd))
+			default:
+				row.Summary = loc.T("%d added, %d updated, %d restored, %d deleted and %d archived",
+					e.Diff.Count(service.SyncAdd), e.Diff.Count(service.SyncUpdate), e.Diff.Count(service.SyncRestore),
+					e.Diff.Count(service.SyncDelete), e.Diff.Count(service.SyncArchive))
+			}
+			rows = append(rows, row)
+		}
+
+		render(w, r, tmpl, struct {
+			Plan    models.TrainingPlan
+			Entries []historyRow
+		}{plan, rows})
+	}
+}
+
+// handleRevertPlan shows what reverting a plan to an earlier version changes
+// and, on POST, reverts it.
+func handleRevertPlan(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("revert_plan.html", nil)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "POST" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		plan, ok := historyPlan(w, r, svc)
+		if !ok {
+			return
+		}
+		changeID, err := strconv.ParseInt(r.FormValue("change"), 10, 64)
+		if err != nil {
+			httpError(w, r, "Version not found", http.StatusNotFound)
+			return
+		}
+
+		revert := svc.PreviewRevert
+		if r.Method == "POST" {
+			revert = svc.RevertPlan
+		}
+		result, err := revert(r.Context(), plan.ID, changeID)
+		if err == service.ErrNotFound {
+			httpError(w, r, "Version not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			serviceError(w, r, err)
+			return
+		}
+		if r.Method == "POST" {
+			redirect(w, r, fmt.Sprintf("/plans/%d/history", plan.ID))
+			return
+		}
+
+		render(w, r, tmpl, struct {
+			Plan     models.TrainingPlan
+			ChangeID int64
+			Fields   []syncField
+			Rows     []syncRow
+			Added    int
+			Updated  int
+			Deleted  int
+			Archived int
+			Restored int
+		}{
+			Plan:     plan,
+			ChangeID: changeID,
+			Fields:   planFields(result),
+			Rows:     syncRows(r, result),
+			Added:    result.Count(service.SyncAdd),
+			Updated:  result.Count(service.SyncUpdate),
+			Deleted:  result.Count(service.SyncDelete),
+			Archived: result.Count(service.SyncArchive),
+			Restored: result.Count(service.SyncRestore),
+		})
+	}
+}
+
+// historyPlan looks up the plan of a history page, replying with an error if
+// there is none.
+func historyPlan(w http.ResponseWriter, r *http.Request, svc *service.Service) (models.TrainingPlan, bool) {
+	id, _, ok := pathID(r.URL.Path, "/plans/")
+	if !ok {
+		httpError(w, r, "Plan not found", http.StatusNotFound)
+		return models.TrainingPlan{}, false
+	}
+	plan, err := svc.Plan(r.Context(), id)
+	if err == service.ErrNotFound {
+		httpError(w, r, "Plan not found", http.StatusNotFound)
+		return models.TrainingPlan{}, false
+	}
+	if err != nil {
+		internalError(w, r, err)
+		return models.TrainingPlan{}, false
+	}
+	return plan.TrainingPlan, true
+}
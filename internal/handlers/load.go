@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/locale"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+const (
+	// Time constants of the fitness-fatigue model in days
+	atlDays = 7
+	ctlDays = 42
+
+	// Weighting factor of Banister's TRIMP
+	trimpWeighting = 1.92
+
+	// Effort assumed for sessions without heart rate, RPE or target zone
+	defaultRPE = 5
+
+	defaultLoadHistoryDays = 90
+	maxLoadProjectionDays  = 180
+)
+
+type LoadDay struct {
+	Date      string  `json:"date"`
+	Load      float64 `json:"load"`
+	ATL       float64 `json:"atl"`
+	CTL       float64 `json:"ctl"`
+	TSB       float64 `json:"tsb"`
+	Projected bool    `json:"projected"`
+}
+
+type LoadData struct {
+	PlanID      int64     `json:"plan_id,omitempty"`
+	WorkoutType string    `json:"workout_type,omitempty"`
+	HistoryDays int       `json:"history_days"`
+	Today       string    `json:"today"`
+	Days        []LoadDay `json:"days"`
+}
+
+// Current returns the values for today, or the zero day if the series does
+// not reach today.
+func (d LoadData) Current() LoadDay {
+	for _, day := range d.Days {
+		if day.Date == d.Today {
+			return day
+		}
+	}
+	return LoadDay{}
+}
+
+// trimp computes Banister's training impulse from the duration in minutes
+// and the average heart rate.
+func trimp(minutes, hr float64, settings service.Settings) float64 {
+	reserve := (hr - float64(settings.RestingHR)) / float64(settings.MaxHR-settings.RestingHR)
+	reserve = math.Max(0, math.Min(1, reserve))
+	return minutes * reserve * 0.64 * math.Exp(trimpWeighting*reserve)
+}
+
+// sessionRPE is the load of a session without heart rate: the rating of
+// perceived exertion (1-10) times the duration in minutes.
+func sessionRPE(minutes, rpe float64) float64 {
+	return rpe * minutes
+}
+
+// targetHR turns a cycling target like "68-73" (percent of HFmax) or "150"
+// (bpm) into a single heart rate.
+func targetHR(hfmax string, settings service.Settings) (float64, bool) {
+	parts := strings.Split(hfmax, "-")
+	sum := 0.0
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(part), "%")), 64)
+		if err != nil {
+			return 0, false
+		}
+		sum += v
+	}
+	value := sum / float64(len(parts))
+
+	if value <= 100 {
+		return value / 100 * float64(settings.MaxHR), true
+	}
+	return value, true
+}
+
+// estimateLoad returns the training load of a single session. Completed
+// sessions use the TRIMP of the recorded heart rate, or RPE × duration when
+// heart rate is missing. Everything else falls back to the planned target zone or a
+// moderate effort, applied to the best known duration.
+func estimateLoad(s storage.LoadSession, settings service.Settings) float64 {
+	if !s.Duration.Valid || s.Duration.Int64 <= 0 {
+		return 0
+	}
+	minutes := float64(s.Duration.Int64)
+
+	if s.Completed {
+		if s.AvgHR.Valid {
+			return trimp(minutes, float64(s.AvgHR.Int64), settings)
+		}
+		if s.RPE.Valid {
+			return sessionRPE(minutes, float64(s.RPE.Int64))
+		}
+	}
+
+	if hr, ok := targetHR(s.HFMax, settings); ok {
+		return trimp(minutes, hr, settings)
+	}
+	return sessionRPE(minutes, defaultRPE)
+}
+
+// loadFromRequest reads the plan, type and days query parameters and builds
+// the daily load series with acute (ATL) and chronic (CTL) load and the
+// resulting balance (TSB). Open sessions from today on are projected from
+// their planned duration.
+func loadFromRequest(svc *service.Service, r *http.Request) (LoadData, error) {
+	query := r.URL.Query()
+
+	settings, err := svc.Settings(r.Context())
+	if err != nil {
+		return LoadData{}, err
+	}
+	today := dates.Today(settings.Location())
+
+	data := LoadData{
+		WorkoutType: query.Get("type"),
+		HistoryDays: defaultLoadHistoryDays,
+		Today:       today.Format(dates.Layout),
+		Days:        []LoadDay{},
+	}
+	if planID, err := strconv.ParseInt(query.Get("plan"), 10, 64); err == nil {
+		data.PlanID = planID
+	}
+	if days, err := strconv.Atoi(query.Get("days")); err == nil && days > 0 {
+		data.HistoryDays = days
+	}
+
+	sessions, err := svc.LoadSessions(r.Context(), storage.StatsFilter{WorkoutType: data.WorkoutType, PlanID: data.PlanID})
+	if err != nil {
+		return data, err
+	}
+
+	loads := make(map[string]float64)
+	var first, last string
+	for _, s := range sessions {
+		// Missed sessions in the past add nothing
+		if !s.Completed && s.Date < data.Today {
+			continue
+		}
+		loads[s.Date] += estimateLoad(s, settings)
+		if first == "" {
+			first = s.Date
+		}
+		last = s.Date
+	}
+
+	if first == "" {
+		return data, nil
+	}
+
+	start, err := dates.Parse(first)
+	if err != nil {
+		return data, err
+	}
+	end, err := dates.Parse(last)
+	if err != nil {
+		return data, err
+	}
+	if end.Before(today) {
+		end = today
+	}
+	if limit := today.AddDate(0, 0, maxLoadProjectionDays); end.After(limit) {
+		end = limit
+	}
+	shown := today.AddDate(0, 0, -data.HistoryDays)
+
+	// The averages start at the very first session so that the shown window
+	// is not distorted by a cold start.
+	var atl, ctl float64
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format(dates.Layout)
+		load := loads[key]
+
+		tsb := ctl - atl
+		atl += (load - atl) / atlDays
+		ctl += (load - ctl) / ctlDays
+
+		if day.Before(shown) {
+			continue
+		}
+		data.Days = append(data.Days, LoadDay{
+			Date:      key,
+			Load:      math.Round(load*10) / 10,
+			ATL:       math.Round(atl*10) / 10,
+			CTL:       math.Round(ctl*10) / 10,
+			TSB:       math.Round(tsb*10) / 10,
+			Projected: day.After(today),
+		})
+	}
+
+	return data, nil
+}
+
+const (
+	loadChartWidth  = 900
+	loadChartHeight = 320
+	loadChartLeft   = 40
+	loadChartRight  = 10
+	loadChartTop    = 24
+	loadChartBottom = 24
+)
+
+// renderLoadSVG draws the daily load as bars and ATL, CTL and TSB as lines.
+// The projected part of every line is dashed.
+func renderLoadSVG(data LoadData, lc locale.Locale) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Arial, sans-serif" font-size="10" fill="#666">`,
+		loadChartWidth, loadChartHeight, loadChartWidth, loadChartHeight)
+
+	if len(data.Days) == 0 {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%s</text></svg>`, loadChartWidth/2, loadChartHeight/2, lc.T("No sessions"))
+		return b.String()
+	}
+
+	low, high := 0.0, 1.0
+	for _, day := range data.Days {
+		for _, v := range []float64{day.Load, day.ATL, day.CTL, day.TSB} {
+			low = math.Min(low, v)
+			high = math.Max(high, v)
+		}
+	}
+
+	plotWidth := float64(loadChartWidth - loadChartLeft - loadChartRight)
+	plotHeight := float64(loadChartHeight - loadChartTop - loadChartBottom)
+	step := plotWidth / float64(len(data.Days))
+	x := func(i int) float64 {
+		return loadChartLeft + step*(float64(i)+0.5)
+	}
+	y := func(v float64) float64 {
+		return loadChartTop + plotHeight*(high-v)/(high-low)
+	}
+
+	// Axis with zero line and a few labels
+	for i := 0; i <= 4; i++ {
+		v := low + (high-low)*float64(i)/4
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#eee"/>`, loadChartLeft, loadChartWidth-loadChartRight, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%.0f</text>`, loadChartLeft-4, y(v)+3, v)
+	}
+	fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" stroke="#999"/>`, loadChartLeft, loadChartWidth-loadChartRight, y(0), y(0))
+
+	for i, day := range data.Days {
+		if day.Load > 0 {
+			opacity := 0.6
+			if day.Projected {
+				opacity = 0.25
+			}
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#9e9e9e" fill-opacity="%.2f"><title>%s: %.1f</title></rect>`,
+				x(i)-step*0.4, y(day.Load), step*0.8, y(0)-y(day.Load), opacity, day.Date, day.Load)
+		}
+		if day.Date == data.Today {
+			fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%d" stroke="#ff9800" stroke-dasharray="2,2"/>`,
+				x(i), x(i), loadChartTop, loadChartHeight-loadChartBottom)
+		}
+		if strings.HasSuffix(day.Date, "-01") {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x(i), loadChartHeight-8, day.Date[:7])
+		}
+	}
+
+	lines := []struct {
+		name  string
+		color string
+		value func(LoadDay) float64
+	}{
+		{"ATL", "#e53935", func(d LoadDay) float64 { return d.ATL }},
+		{"CTL", "#1e88e5", func(d LoadDay) float64 { return d.CTL }},
+		{"TSB", "#fbc02d", func(d LoadDay) float64 { return d.TSB }},
+	}
+	for n, line := range lines {
+		var past, projected []string
+		for i, day := range data.Days {
+			point := fmt.Sprintf("%.1f,%.1f", x(i), y(line.value(day)))
+			if !day.Projected {
+				past = append(past, point)
+			}
+			// Start the projection at the last real point so the lines join
+			if day.Projected || (i+1 < len(data.Days) && data.Days[i+1].Projected) {
+				projected = append(projected, point)
+			}
+		}
+		if len(past) > 0 {
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(past, " "), line.color)
+		}
+		if len(projected) > 0 {
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2" stroke-dasharray="5,4"/>`, strings.Join(projected, " "), line.color)
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="6" width="10" height="10" fill="%s"/><text x="%d" y="15">%s</text>`,
+			loadChartLeft+n*60, line.color, loadChartLeft+n*60+14, line.name)
+	}
+
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+func handleLoadSVG(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := loadFromRequest(svc, r)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, renderLoadSVG(data, requestLocale(r)))
+	}
+}
+
+func handleLoadJSON(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := loadFromRequest(svc, r)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		writeJSON(w, data)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+func TestTRIMP(t *testing.T) {
+	settings := service.Settings{RestingHR: 50, MaxHR: 190}
+	tests := []struct {
+		name    string
+		minutes float64
+		hr      float64
+		want    float64
+	}{
+		{"at rest", 60, 50, 0},
+		{"below rest", 60, 40, 0},
+		{"half the reserve", 60, 120, 60 * 0.5 * 0.64 * math.Exp(1.92*0.5)},
+		{"maximum", 30, 190, 30 * 0.64 * math.Exp(1.92)},
+		{"above maximum", 30, 210, 30 * 0.64 * math.Exp(1.92)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimp(tt.minutes, tt.hr, settings); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("trimp(%v, %v) = %v, want %v", tt.minutes, tt.hr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateLoad(t *testing.T) {
+	settings := service.Settings{RestingHR: 50, MaxHR: 190}
+	minutes := sql.NullInt64{Int64: 60, Valid: true}
+	n := func(v int64) sql.NullInt64 { return sql.NullInt64{Int64: v, Valid: true} }
+	halfReserve := trimp(60, 120, settings)
+
+	tests := []struct {
+		name    string
+		session storage.LoadSession
+		want    float64
+	}{
+		{"no duration", storage.LoadSession{Completed: true, AvgHR: n(120)}, 0},
+		{"heart rate", storage.LoadSession{Completed: true, Duration: minutes, AvgHR: n(120), RPE: n(9)}, halfReserve},
+		{"RPE times duration", storage.LoadSession{Completed: true, Duration: minutes, RPE: n(6)}, 360},
+		{"planned zone in percent", storage.LoadSession{Duration: minutes, HFMax: "60-65%"}, trimp(60, 0.625*190, settings)},
+		{"planned zone in bpm", storage.LoadSession{Duration: minutes, HFMax: "120"}, halfReserve},
+		{"recorded values of open sessions are ignored", storage.LoadSession{Duration: minutes, RPE: n(9)}, 300},
+		{"moderate effort", storage.LoadSession{Completed: true, Duration: minutes}, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateLoad(tt.session, settings); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("estimateLoad() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompletionFromForm(t *testing.T) {
+	tests := []struct {
+		name  string
+		form  url.Values
+		field string
+	}{
+		{"empty", url.Values{}, ""},
+		{"all details", url.Values{"duration_minutes": {"45"}, "avg_hr": {"140"}, "rpe": {"6"}, "distance_km": {"21.5"}}, ""},
+		{"text heart rate", url.Values{"avg_hr": {"fast"}}, "avg_hr"},
+		{"fractional RPE", url.Values{"rpe": {"6.5"}}, "rpe"},
+		{"text duration", url.Values{"duration_minutes": {"1h"}}, "duration_minutes"},
+		{"text distance", url.Values{"distance_km": {"far"}}, "distance_km"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/complete-session/1", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			c, err := completionFromForm(r, 1)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if c.SessionID != 1 {
+					t.Errorf("session ID %d, want 1", c.SessionID)
+				}
+				return
+			}
+			input, ok := err.(*service.InputError)
+			if !ok || input.Field != tt.field {
+				t.Errorf("got %v, want an input error for %s", err, tt.field)
+			}
+		})
+	}
+}
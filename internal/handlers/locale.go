@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"training-tracker/internal/locale"
+	"training-tracker/internal/service"
+)
+
+type contextKey int
+
+const localeKey contextKey = 0
+
+// Localize resolves the locale of every request from the settings, or from
+// the browser's Accept-Language header if the user did not choose one.
+func Localize(svc *service.Service, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			settings = service.DefaultSettings
+		}
+		lc := settings.Locale(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeKey, lc)))
+	})
+}
+
+// requestLocale returns the locale resolved by Localize.
+func requestLocale(r *http.Request) locale.Locale {
+	if lc, ok := r.Context().Value(localeKey).(locale.Locale); ok {
+		return lc
+	}
+	return locale.Get(locale.Match(r.Header.Get("Accept-Language")))
+}
+
+// httpError replies with msg translated into the request's language.
+func httpError(w http.ResponseWriter, r *http.Request, msg string, code int) {
+	http.Error(w, requestLocale(r).T(msg), code)
+}
+
+// serviceError replies with the status fitting an error from the service.
+func serviceError(w http.ResponseWriter, r *http.Request, err error) {
+	msg, code := errorStatus(r, err)
+	if code == http.StatusInternalServerError {
+		writeError(w, r, code, msg, err.Error())
+		return
+	}
+	http.Error(w, msg, code)
+}
+
+// errorStatus maps an error from the service to a message and status:
+// invalid input is translated, unknown records are not found and anything
+// else is an internal error, which is logged rather than shown.
+func errorStatus(r *http.Request, err error) (string, int) {
+	var fields service.FieldErrors
+	var input *service.InputError
+	switch {
+	case errors.As(err, &fields):
+		messages := make([]string, len(fields))
+		for i, field := range fields {
+			messages[i] = requestLocale(r).T(field.Message, field.Args...)
+		}
+		return strings.Join(messages, "\n"), http.StatusBadRequest
+	case errors.As(err, &input):
+		return requestLocale(r).T(input.Message, input.Args...), http.StatusBadRequest
+	case errors.Is(err, service.ErrNotFound):
+		return requestLocale(r).T("Not found"), http.StatusNotFound
+	default:
+		logger(r).Error("internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+		return internalMessage(r), http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/metrics"
+	"training-tracker/internal/service"
+)
+
+// Instrument counts the requests next answers and measures how long they
+// take, by the pattern of mux they match so that IDs in paths do not make
+// for a series each.
+func Instrument(reg *metrics.Registry, mux *http.ServeMux, next http.Handler) http.Handler {
+	requests := reg.NewCounter("http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	durations := reg.NewHistogram("http_request_duration_seconds",
+		"Time to answer HTTP requests by method and route.", metrics.DefaultBuckets, "method", "route")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		method := r.Method
+		switch method {
+		case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS":
+		default:
+			method = "other"
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			// Also counted when next panics, as Recover sits inside
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			requests.Inc(method, route, strconv.Itoa(rec.status))
+			durations.Observe(time.Since(start).Seconds(), method, route)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// RegisterMetricsRoutes adds /metrics for Prometheus, with gauges of how the
+// plans are going next to the metrics of reg.
+func RegisterMetricsRoutes(mux *http.ServeMux, reg *metrics.Registry, svc *service.Service) {
+	reg.Collect(func(ctx context.Context) ([]metrics.Family, error) {
+		return trainingMetrics(ctx, svc)
+	})
+	mux.Handle("/metrics", reg.Handler())
+}
+
+// trainingMetrics computes the gauges per plan, for the day it is in the
+// configured time zone.
+func trainingMetrics(ctx context.Context, svc *service.Service) ([]metrics.Family, error) {
+	settings, err := svc.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	streaks, err := svc.Streaks(ctx, dates.Today(settings.Location()))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := []string{"plan_id", "plan", "workout_type"}
+	families := []metrics.Family{
+		{Name: "training_sessions_due_today", Help: "Sessions planned for today.", Type: "gauge", Labels: labels},
+		{Name: "training_sessions_completed_today", Help: "Sessions planned for today that are completed.", Type: "gauge", Labels: labels},
+		{Name: "training_sessions_overdue", Help: "Sessions of past days that are not completed.", Type: "gauge", Labels: labels},
+		{Name: "training_streak_current", Help: "Sessions completed in a row up to today.", Type: "gauge", Labels: labels},
+	}
+	for _, s := range streaks {
+		values := []string{strconv.FormatInt(s.PlanID, 10), s.PlanName, s.WorkoutType}
+		for i, v := range []int{s.DueToday, s.CompletedToday, s.Overdue, s.Current} {
+			families[i].Samples = append(families[i].Samples, metrics.Sample{Labels: values, Value: float64(v)})
+		}
+	}
+	return families, nil
+}
+
+// RegisterHealthRoutes adds /healthz, which tells that the server is up
+// and reaches the database, and /readyz, which also checks that the
+// database answers queries on its tables.
+func RegisterHealthRoutes(mux *http.ServeMux, db *sql.DB) {
+	mux.HandleFunc("/healthz", handleHealth(func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}))
+	mux.HandleFunc("/readyz", handleHealth(func(ctx context.Context) error {
+		var n int
+		return db.QueryRowContext(ctx, "SELECT COUNT(*) FROM workout_types").Scan(&n)
+	}))
+}
+
+// healthTimeout bounds a check, so that a hanging database fails it rather
+// than the prober's timeout.
+const healthTimeout = 2 * time.Second
+
+func handleHealth(check func(ctx context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+		defer cancel()
+		w.Header().Set("Cache-Control", "no-store")
+		if err := check(ctx); err != nil {
+			logger(r).Warn("health check failed", "path", r.URL.Path, "error", err)
+			http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	}
+}
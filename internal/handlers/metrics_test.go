@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/metrics"
+	"training-tracker/internal/service"
+)
+
+func scrapeMetrics(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plans/", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	reg := &metrics.Registry{}
+	handler := Instrument(reg, mux, Recover(mux))
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/plans/1"},
+		{"GET", "/plans/2"},
+		{"BREW", "/"},
+		{"GET", "/boom"},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	got := scrapeMetrics(t, reg.Handler())
+	for _, line := range []string{
+		// IDs in paths are counted by the route they match
+		`http_requests_total{method="GET",route="/plans/",status="404"} 2`,
+		`http_requests_total{method="other",route="/",status="200"} 1`,
+		// A panic is counted with the status Recover answers it with
+		`http_requests_total{method="GET",route="/boom",status="500"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/plans/"} 2`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("metrics lack %s:\n%s", line, got)
+		}
+	}
+}
+
+func TestTrainingMetrics(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	settings, err := svc.Settings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := dates.Today(settings.Location())
+	id := createTestPlan(t, svc, "core",
+		service.NewSession{Description: "Plank", Date: today.AddDate(0, 0, -2)},
+		service.NewSession{Description: "Plank", Date: today.AddDate(0, 0, -1)},
+		service.NewSession{Description: "Plank", Date: today},
+	)
+
+	mux := http.NewServeMux()
+	RegisterMetricsRoutes(mux, &metrics.Registry{}, svc)
+	got := scrapeMetrics(t, mux)
+
+	labels := fmt.Sprintf(`{plan_id="%d",plan="Test plan",workout_type="core"}`, id)
+	for _, line := range []string{
+		"training_sessions_due_today" + labels + " 1",
+		"training_sessions_completed_today" + labels + " 0",
+		"training_sessions_overdue" + labels + " 2",
+		"training_streak_current" + labels + " 0",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("metrics lack %s:\n%s", line, got)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mux := http.NewServeMux()
+	RegisterHealthRoutes(mux, db)
+
+	check := func(method, path string, want int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if rec.Code != want {
+			t.Errorf("%s %s: got status %d, want %d", method, path, rec.Code, want)
+		}
+		if want == http.StatusServiceUnavailable && strings.Contains(rec.Body.String(), "workout_types") {
+			t.Errorf("%s %s: error details in %q", method, path, rec.Body.String())
+		}
+	}
+
+	// Up, but without the tables it is not ready
+	check("GET", "/healthz", http.StatusOK)
+	check("GET", "/readyz", http.StatusServiceUnavailable)
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	check("GET", "/readyz", http.StatusOK)
+	check("HEAD", "/healthz", http.StatusOK)
+	check("POST", "/healthz", http.StatusMethodNotAllowed)
+
+	db.Close()
+	check("GET", "/healthz", http.StatusServiceUnavailable)
+	check("GET", "/readyz", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const requestIDKey contextKey = featuresKey + 1
+
+// errorPage shows users what went wrong.
+var errorPage = parseTemplate("error.html", nil)
+
+// LogRequests gives every request an ID, which it sends back as the
+// X-Request-ID header, and logs the request once it is answered. An ID set
+// by a reverse proxy is kept, so that both logs can be matched.
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("request_id", id),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// Recover turns a panic in next into an internal error, logged with its
+// stack. It belongs inside Localize and Mount so that the error page is
+// translated and links to the right place.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// Deliberately cut off response, which the server handles
+				panic(v)
+			}
+			logger(r).Error("panic", "error", v, "stack", string(debug.Stack()))
+			if rec, ok := w.(*statusRecorder); !ok || rec.status == 0 {
+				writeError(w, r, http.StatusInternalServerError, internalMessage(r), fmt.Sprint(v))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers the status and size of a response for the log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs of a proxy that are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID LogRequests gave the request.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// logger returns the logger for records about a request.
+func logger(r *http.Request) *slog.Logger {
+	return slog.Default().With("request_id", requestID(r))
+}
+
+// internalMessage is what users see of an internal error: only the ID to
+// report it with.
+func internalMessage(r *http.Request) string {
+	return requestLocale(r).T("Something went wrong on our side. If it keeps happening, report error ID %s.", requestID(r))
+}
+
+// internalError logs err with the request's ID and replies with a message
+// that leaves out the details, such as failed SQL.
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	logger(r).Error("internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeError(w, r, http.StatusInternalServerError, internalMessage(r), err.Error())
+}
+
+// writeError replies with an error: as JSON for the API and as a page
+// otherwise. The detail is only shown in development mode.
+func writeError(w http.ResponseWriter, r *http.Request, code int, msg, detail string) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSONStatus(w, code, map[string]string{"error": msg, "request_id": requestID(r)})
+		return
+	}
+	if !ReloadTemplates {
+		detail = ""
+	}
+
+	data := struct {
+		Status  int
+		Message string
+		Detail  string
+	}{code, msg, detail}
+
+	page, err := errorPage.execute(r, data)
+	if err != nil {
+		// The error page itself is broken; plain text still tells the user
+		logger(r).Error("rendering error page", "error", err)
+		http.Error(w, msg, code)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(page)
+}
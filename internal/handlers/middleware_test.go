@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the default logger's records to a buffer as JSON for the
+// rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func TestRecover(t *testing.T) {
+	logs := captureLog(t)
+	handler := LogRequests(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret detail")
+	})))
+
+	for _, path := range []string{"/plans/1", "/api/plans/1"} {
+		t.Run(path, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set("X-Request-ID", "proxy-id-1")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("got status %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			if got := rec.Header().Get("X-Request-ID"); got != "proxy-id-1" {
+				t.Errorf("got request ID header %q, want the proxy's", got)
+			}
+			body := rec.Body.String()
+			if !strings.Contains(body, "proxy-id-1") {
+				t.Errorf("response does not carry the request ID:\n%s", body)
+			}
+			if strings.Contains(body, "secret detail") || strings.Contains(body, "goroutine") {
+				t.Errorf("response shows the panic:\n%s", body)
+			}
+
+			// The log has the details, and the request with its status
+			var records []map[string]interface{}
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				var record map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, record)
+			}
+			if len(records) != 2 || records[0]["msg"] != "panic" || records[0]["error"] != "secret detail" || records[0]["stack"] == "" {
+				t.Fatalf("got log records %v, want the panic with its stack first", records)
+			}
+			if records[1]["msg"] != "request" || records[1]["status"] != float64(500) || records[1]["request_id"] != "proxy-id-1" {
+				t.Errorf("got request record %v", records[1])
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	captureLog(t)
+	var seen string
+	handler := LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r)
+	}))
+
+	for _, header := range []string{"", "bad id with spaces", strings.Repeat("a", 65)} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		got := rec.Header().Get("X-Request-ID")
+		if got == header || len(got) != 16 || got != seen {
+			t.Errorf("header %q: got ID %q, handler saw %q, want a new one", header, got, seen)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"training-tracker/internal/config"
+	"training-tracker/internal/service"
+)
+
+const (
+	basePathKey contextKey = iota + 1
+	featuresKey
+)
+
+// Mount serves next below the configured base path, for a reverse proxy
+// that forwards e.g. /training/... unchanged. Handlers see paths without the
+// prefix; links in pages get it back through the base template function.
+func Mount(cfg config.Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), basePathKey, cfg.BasePath)
+		ctx = context.WithValue(ctx, featuresKey, cfg.Features)
+		ctx = service.WithActor(ctx, requestActor(cfg, r))
+		r = r.WithContext(ctx)
+
+		if cfg.BasePath == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == cfg.BasePath {
+			http.Redirect(w, r, cfg.BasePath+"/", http.StatusMovedPermanently)
+			return
+		}
+		rest, ok := strings.CutPrefix(r.URL.Path, cfg.BasePath)
+		if !ok || !strings.HasPrefix(rest, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		stripped := r.Clone(ctx)
+		stripped.URL.Path = rest
+		stripped.URL.RawPath = ""
+		next.ServeHTTP(w, stripped)
+	})
+}
+
+// requestActor returns who the plan history names for the changes of a
+// request: the user the proxy passes, or else the client's address.
+func requestActor(cfg config.Config, r *http.Request) string {
+	if cfg.UserHeader != "" {
+		if user := strings.TrimSpace(r.Header.Get(cfg.UserHeader)); user != "" {
+			return user
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// basePath returns the prefix the request came in under.
+func basePath(r *http.Request) string {
+	base, _ := r.Context().Value(basePathKey).(string)
+	return base
+}
+
+// featureEnabled reports whether the named optional part of the server is
+// switched on. Outside of Mount everything is.
+func featureEnabled(r *http.Request, name string) bool {
+	features, ok := r.Context().Value(featuresKey).(config.Features)
+	if !ok {
+		return true
+	}
+	switch name {
+	case "api":
+		return features.API
+	case "analytics":
+		return features.Analytics
+	case "ics":
+		return features.ICS
+	case "admin":
+		return features.Admin
+	case "metrics":
+		return features.Metrics
+	default:
+		return false
+	}
+}
+
+// redirect sends the browser to a path of this server after a form was
+// submitted.
+func redirect(w http.ResponseWriter, r *http.Request, path string) {
+	http.Redirect(w, r, basePath(r)+path, http.StatusSeeOther)
+}
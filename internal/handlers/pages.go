@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+
+	"training-tracker/internal/locale"
+	"training-tracker/internal/templates"
+)
+
+// Templates is where pages are parsed from: the templates embedded into the
+// binary, or a directory on disk during development.
+var Templates fs.FS = templates.FS
+
+// ReloadTemplates parses every page again on each request, so that changes
+// to templates on disk show up without restarting the server.
+var ReloadTemplates bool
+
+// page is a template of a page with the functions it needs.
+type page struct {
+	name  string
+	funcs template.FuncMap
+	tmpl  *template.Template
+	err   error
+}
+
+// pages are all pages parsed so far, for CheckTemplates.
+var pages []*page
+
+// parseTemplate parses a page from Templates. Its t, base and feature
+// functions only become bound to a request in render. A page that fails to
+// parse is reported by CheckTemplates.
+func parseTemplate(name string, funcs template.FuncMap) *page {
+	p := &page{name: name, funcs: funcs}
+	p.tmpl, p.err = p.parse()
+	pages = append(pages, p)
+	return p
+}
+
+func (p *page) parse() (*template.Template, error) {
+	tmpl := template.New(p.name).Funcs(template.FuncMap{
+		"t":       locale.Get(locale.Default).T,
+		"base":    func() string { return "" },
+		"feature": func(string) bool { return true },
+	})
+	if p.funcs != nil {
+		tmpl = tmpl.Funcs(p.funcs)
+	}
+	return tmpl.ParseFS(Templates, p.name)
+}
+
+// CheckTemplates returns the errors of all pages registered so far that
+// failed to parse, so that the server can refuse to start with them.
+func CheckTemplates() error {
+	var errs []error
+	for _, p := range pages {
+		if p.err != nil {
+			errs = append(errs, p.err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// execute runs a page for a request, with its strings translated into the
+// request's language and its links below the request's base path. The page
+// is only returned once complete, so that an error does not leave half of
+// it sent.
+func (p *page) execute(r *http.Request, data interface{}) ([]byte, error) {
+	tmpl, err := p.tmpl, p.err
+	if ReloadTemplates {
+		tmpl, err = p.parse()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	clone.Funcs(template.FuncMap{
+		"t":       requestLocale(r).T,
+		"base":    func() string { return basePath(r) },
+		"feature": func(name string) bool { return featureEnabled(r, name) },
+	})
+
+	var buf bytes.Buffer
+	if err := clone.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// render replies with a page.
+func render(w http.ResponseWriter, r *http.Request, p *page, data interface{}) {
+	renderStatus(w, r, p, data, http.StatusOK)
+}
+
+// renderStatus is render with another status, such as for a form shown
+// again with its errors.
+func renderStatus(w http.ResponseWriter, r *http.Request, p *page, data interface{}, code int) {
+	body, err := p.execute(r, data)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(body)
+}
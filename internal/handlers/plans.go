@@ -1,350 +1,373 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
-	"html/template"
+	"io"
 	"net/http"
-	"time"
+	"net/url"
+	"strconv"
+	"strings"
 
-	"gopkg.in/yaml.v3"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/locale"
 	"training-tracker/internal/models"
+	"training-tracker/internal/service"
 )
 
-type SessionsYAML struct {
-	Sessions []struct {
-		Order       int       `yaml:"order"`
-		Description string    `yaml:"description"`
-		Date        time.Time `yaml:"date"`
-		// Type-specific fields
-		HFMax       string    `yaml:"hfmax,omitempty"`      // For cycling
-		// Mobility has no additional fields
-		// Sandbag has no additional fields yet
-	} `yaml:"sessions"`
+// planForm is the form to create plans, with what was entered and what is
+// wrong with it when it is shown again.
+type planForm struct {
+	WorkoutTypes []models.WorkoutType
+	Form         url.Values
+	Errors       formErrors
 }
 
-func handleCreatePlan(db *sql.DB) http.HandlerFunc {
-	tmpl := template.Must(template.ParseFiles("internal/templates/create_plan.html"))
+// planSource is YAML to import plans from: the text pasted into the form or
+// an uploaded file.
+type planSource struct {
+	// File name, empty for the pasted text
+	Name string
+	Data string
+}
+
+// planPreview is a plan of an import preview with the warnings of its
+// sessions translated.
+type planPreview struct {
+	service.NewPlan
+	WorkoutType string
+	Rows        []previewRow
+	Flagged     int
+}
+
+// previewRow is a session of an import preview with its warnings translated.
+type previewRow struct {
+	service.PreviewSession
+	Notes []string
+}
+
+// maxUploadSize bounds the files of one import.
+const maxUploadSize = 10 << 20
+
+// planSources returns the YAML of a submitted plan form. Files uploaded
+// before a preview come back as the hidden upload_name and upload_data
+// fields.
+func planSources(r *http.Request) ([]planSource, error) {
+	var sources []planSource
+	if text := r.PostFormValue("yaml_sessions"); strings.TrimSpace(text) != "" {
+		sources = append(sources, planSource{Data: text})
+	}
+
+	names, contents := r.PostForm["upload_name"], r.PostForm["upload_data"]
+	for i := 0; i < len(names) && i < len(contents); i++ {
+		sources = append(sources, planSource{Name: names[i], Data: contents[i]})
+	}
+
+	if r.MultipartForm == nil {
+		return sources, nil
+	}
+	for _, header := range r.MultipartForm.File["plan_files"] {
+		if header.Filename == "" && header.Size == 0 {
+			// No file chosen
+			continue
+		}
+		f, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, planSource{Name: header.Filename, Data: string(data)})
+	}
+	return sources, nil
+}
+
+// addSource adds the problems of YAML to import, those of a file with the
+// file's name. It reports false if err is not about the input.
+func (f formErrors) addSource(r *http.Request, source planSource, err error) bool {
+	problems := formErrors{}
+	if !problems.add(r, err, "yaml_sessions") {
+		return false
+	}
+	for _, msg := range problems["yaml_sessions"] {
+		if source.Name == "" {
+			f["yaml_sessions"] = append(f["yaml_sessions"], msg)
+		} else {
+			f["plan_files"] = append(f["plan_files"], source.Name+": "+msg)
+		}
+	}
+	return true
+}
+
+func handleCreatePlan(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("create_plan.html", nil)
+	previewTmpl := parseTemplate("preview_plan.html", nil)
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "POST" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Get workout types for the dropdown
+		workoutTypes, err := svc.WorkoutTypes(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		data := planForm{WorkoutTypes: workoutTypes, Errors: formErrors{}}
+
 		if r.Method == "GET" {
-			// Get workout types for the dropdown
-			rows, err := db.Query("SELECT id, name FROM workout_types")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			render(w, r, tmpl, data)
+			return
+		}
+
+		// Parse form data, which carries files when some are uploaded
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil && err != http.ErrNotMultipart {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				httpError(w, r, "The upload is too large", http.StatusRequestEntityTooLarge)
 				return
 			}
-			defer rows.Close()
-
-			var workoutTypes []models.WorkoutType
-			for rows.Next() {
-				var wt models.WorkoutType
-				if err := rows.Scan(&wt.ID, &wt.Name); err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				workoutTypes = append(workoutTypes, wt)
-			}
+			httpError(w, r, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		data.Form = r.PostForm
+		if r.FormValue("edit") != "" {
+			// Back from the preview to change the plan
+			render(w, r, tmpl, data)
+			return
+		}
 
-			data := struct {
-				WorkoutTypes []models.WorkoutType
-			}{
-				WorkoutTypes: workoutTypes,
+		// Name and workout type of the form apply to plans whose YAML gives
+		// none
+		defaults := service.NewPlan{Name: r.FormValue("name")}
+		if value := r.FormValue("workout_type_id"); value != "" {
+			if defaults.WorkoutTypeID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				data.Errors.addError(r, "workout_type_id", "Unknown workout type")
 			}
+		}
 
-			tmpl.Execute(w, data)
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		sources, err := planSources(r)
+		if err != nil {
+			internalError(w, r, err)
 			return
 		}
 
-		if r.Method == "POST" {
-			// Parse form data
-			if err := r.ParseForm(); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
+		plans := []service.NewPlan{defaults}
+		if len(sources) > 0 {
+			plans = nil
+		}
+		for _, source := range sources {
+			docs, err := service.ParsePlans([]byte(source.Data), settings.Location())
+			for _, doc := range docs {
+				var plan service.NewPlan
+				plan, err = doc.Plan(workoutTypes, defaults)
+				if err != nil {
+					break
+				}
+				plans = append(plans, plan)
 			}
-
-			name := r.FormValue("name")
-			workoutTypeID := r.FormValue("workout_type_id")
-
-			// Start a transaction
-			tx, err := db.Begin()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if err == nil && len(docs) == 0 {
+				err = &service.InputError{Field: "yaml_sessions", Message: "No plan found in the YAML"}
+			}
+			if err != nil && !data.Errors.addSource(r, source, err) {
+				serviceError(w, r, err)
 				return
 			}
-			defer tx.Rollback()
-
-			// Insert new plan into database
-			result, err := tx.Exec(`
-				INSERT INTO training_plans (name, workout_type_id, created_at)
-				VALUES (?, ?, ?)
-			`, name, workoutTypeID, time.Now())
+		}
 
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Report the plans' own problems along with those of the YAML
+		for _, plan := range plans {
+			if plan.WorkoutTypeID == 0 {
+				data.Errors.addError(r, "workout_type_id", "Select a workout type")
+			}
+			err = svc.ValidatePlan(r.Context(), plan)
+			if err != nil && !data.Errors.add(r, err, "yaml_sessions", "name", "workout_type_id") {
+				serviceError(w, r, err)
 				return
 			}
+		}
+		if len(data.Errors) > 0 {
+			renderStatus(w, r, tmpl, data, http.StatusBadRequest)
+			return
+		}
 
-			// Get the ID of the newly inserted plan
-			planID, err := result.LastInsertId()
+		// Imports are only stored once the preview is confirmed
+		if len(sources) > 0 && r.FormValue("confirm") == "" {
+			previewed, err := svc.PreviewPlans(r.Context(), plans, dates.Today(settings.Location()))
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				serviceError(w, r, err)
 				return
 			}
-
-			// Process YAML sessions if provided
-			yamlData := r.FormValue("yaml_sessions")
-			if yamlData != "" {
-				var sessions SessionsYAML
-				if err := yaml.Unmarshal([]byte(yamlData), &sessions); err != nil {
-					http.Error(w, "Invalid YAML format: "+err.Error(), http.StatusBadRequest)
-					return
-				}
-
-				// Insert all sessions
-				for _, s := range sessions.Sessions {
-					// Start with base session insertion
-					result, err := tx.Exec(`
-						INSERT INTO training_sessions (plan_id, session_order, description, date)
-						VALUES (?, ?, ?, ?)
-					`, planID, s.Order, s.Description, s.Date)
-					if err != nil {
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return
-					}
-
-					sessionID, err := result.LastInsertId()
-					if err != nil {
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return
+			previews := make([]planPreview, len(plans))
+			for i, plan := range plans {
+				preview := previewed[i]
+				previews[i] = planPreview{NewPlan: plan, WorkoutType: preview.WorkoutType, Flagged: preview.Flagged}
+				previews[i].Name = strings.TrimSpace(plan.Name)
+				for _, session := range preview.Sessions {
+					row := previewRow{PreviewSession: session}
+					for _, warning := range session.Warnings {
+						row.Notes = append(row.Notes, requestLocale(r).T(warning.Message, warning.Args...))
 					}
+					previews[i].Rows = append(previews[i].Rows, row)
+				}
+			}
 
-					// Handle type-specific fields based on workout type
-					switch workoutTypeID {
-					case "1": // cycling
-						if s.HFMax != "" {
-							_, err = tx.Exec(`
-								INSERT INTO cycling_sessions (session_id, hfmax)
-								VALUES (?, ?)
-							`, sessionID, s.HFMax)
-							if err != nil {
-								http.Error(w, err.Error(), http.StatusInternalServerError)
-								return
-							}
-						}
-					case "2": // mobility
-						_, err = tx.Exec(`
-							INSERT INTO mobility_sessions (session_id)
-							VALUES (?)
-						`, sessionID)
-						if err != nil {
-							http.Error(w, err.Error(), http.StatusInternalServerError)
-							return
-						}
-					case "3": // sandbag
-						_, err = tx.Exec(`
-							INSERT INTO sandbag_sessions (session_id)
-							VALUES (?)
-						`, sessionID)
-						if err != nil {
-							http.Error(w, err.Error(), http.StatusInternalServerError)
-							return
-						}
-					case "4": // core
-						_, err = tx.Exec(`
-							INSERT INTO core_sessions (session_id)
-							VALUES (?)
-						`, sessionID)
-						if err != nil {
-							http.Error(w, err.Error(), http.StatusInternalServerError)
-							return
-						}
-					}
+			var uploads []planSource
+			for _, source := range sources {
+				if source.Name != "" {
+					uploads = append(uploads, source)
 				}
 			}
+			render(w, r, previewTmpl, struct {
+				Form    url.Values
+				Uploads []planSource
+				Plans   []planPreview
+				Locale  locale.Locale
+			}{data.Form, uploads, previews, requestLocale(r)})
+			return
+		}
 
-			// Commit the transaction
-			if err := tx.Commit(); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		created, err := svc.CreatePlans(r.Context(), plans)
+		if err != nil {
+			if data.Errors.add(r, err, "yaml_sessions", "name", "workout_type_id") {
+				renderStatus(w, r, tmpl, data, http.StatusBadRequest)
 				return
 			}
-
-			// Redirect to plan view
-			http.Redirect(w, r, fmt.Sprintf("/plans/%d", planID), http.StatusSeeOther)
+			serviceError(w, r, err)
 			return
 		}
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		if len(created) > 1 {
+			redirect(w, r, "/plans")
+			return
+		}
+		// Redirect to plan view
+		redirect(w, r, fmt.Sprintf("/plans/%d", created[0].ID))
 	}
 }
 
-func handleListPlans(db *sql.DB) http.HandlerFunc {
-	tmpl := template.Must(template.ParseFiles("internal/templates/list_plans.html"))
+func handleListPlans(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("list_plans.html", nil)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		// Get all plans
-		rows, err := db.Query(`
-			SELECT id, name, workout_type_id, created_at 
-			FROM training_plans 
-			ORDER BY created_at DESC`)
+		plans, err := svc.Plans(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
-		defer rows.Close()
-
-		var plans []models.TrainingPlan
-		for rows.Next() {
-			var plan models.TrainingPlan
-			if err := rows.Scan(&plan.ID, &plan.Name, &plan.WorkoutTypeID, &plan.CreatedAt); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			plans = append(plans, plan)
-		}
 
 		// Get all workout types to create a map of ID to name
-		workoutTypeRows, err := db.Query("SELECT id, name FROM workout_types")
+		workoutTypes, err := svc.WorkoutTypes(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
-		defer workoutTypeRows.Close()
 
 		workoutTypeNames := make(map[int64]string)
-		for workoutTypeRows.Next() {
-			var id int64
-			var name string
-			if err := workoutTypeRows.Scan(&id, &name); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			workoutTypeNames[id] = name
+		for _, wt := range workoutTypes {
+			workoutTypeNames[wt.ID] = wt.Name
 		}
 
 		data := struct {
 			Plans            []models.TrainingPlan
 			WorkoutTypeNames map[int64]string
+			Locale           locale.Locale
 		}{
 			Plans:            plans,
 			WorkoutTypeNames: workoutTypeNames,
+			Locale:           requestLocale(r),
 		}
 
-		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		render(w, r, tmpl, data)
+	}
+}
+
+// handlePlanPages serves the page of a plan and the pages below it.
+func handlePlanPages(svc *service.Service) http.HandlerFunc {
+	view := handleViewPlan(svc)
+	sync := handleSyncPlan(svc)
+	history := handlePlanHistory(svc)
+	revert := handleRevertPlan(svc)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, action, _ := pathID(r.URL.Path, "/plans/")
+		switch action {
+		case "sync":
+			sync(w, r)
+		case "history":
+			history(w, r)
+		case "revert":
+			revert(w, r)
+		default:
+			view(w, r)
 		}
 	}
 }
 
-func handleViewPlan(db *sql.DB) http.HandlerFunc {
-	tmpl := template.Must(template.ParseFiles("internal/templates/view_plan.html"))
+func handleViewPlan(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("view_plan.html", nil)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		// Extract plan ID from URL path
 		planID := r.URL.Path[len("/plans/"):]
 		if planID == "" {
-			http.Error(w, "Plan ID is required", http.StatusBadRequest)
+			httpError(w, r, "Plan ID is required", http.StatusBadRequest)
 			return
 		}
 
-		// Get plan details
-		var plan models.TrainingPlan
-		err := db.QueryRow(`
-			SELECT id, name, workout_type_id, created_at 
-			FROM training_plans 
-			WHERE id = ?`, planID).Scan(&plan.ID, &plan.Name, &plan.WorkoutTypeID, &plan.CreatedAt)
+		id, err := strconv.ParseInt(planID, 10, 64)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Plan not found", http.StatusNotFound)
-				return
-			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpError(w, r, "Plan not found", http.StatusNotFound)
 			return
 		}
 
-		// Get workout type name
-		var workoutTypeName string
-		err = db.QueryRow(`
-			SELECT name 
-			FROM workout_types 
-			WHERE id = ?`, plan.WorkoutTypeID).Scan(&workoutTypeName)
+		// Get plan details with its sessions
+		plan, err := svc.Plan(r.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Get training sessions with type-specific details
-		rows, err := db.Query(`
-			SELECT 
-				ts.id, 
-				ts.session_order, 
-				ts.description, 
-				ts.date,
-				COALESCE(cs.hfmax, '') as hfmax
-			FROM training_sessions ts
-			LEFT JOIN cycling_sessions cs ON ts.id = cs.session_id
-			LEFT JOIN mobility_sessions ms ON ts.id = ms.session_id
-			LEFT JOIN sandbag_sessions ss ON ts.id = ss.session_id
-			WHERE ts.plan_id = ? 
-			ORDER BY ts.session_order`, plan.ID)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var sessions []struct {
-			models.TrainingSession
-			HFMax string `json:"hfmax,omitempty"`
-		}
-
-		for rows.Next() {
-			var session struct {
-				models.TrainingSession
-				HFMax string `json:"hfmax,omitempty"`
-			}
-			if err := rows.Scan(
-				&session.ID,
-				&session.SessionOrder,
-				&session.Description,
-				&session.Date,
-				&session.HFMax,
-			); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if err == service.ErrNotFound {
+				httpError(w, r, "Plan not found", http.StatusNotFound)
 				return
 			}
-			sessions = append(sessions, session)
+			internalError(w, r, err)
+			return
 		}
 
 		data := struct {
 			Plan            models.TrainingPlan
 			WorkoutTypeName string
-			Sessions        []struct {
-				models.TrainingSession
-				HFMax string `json:"hfmax,omitempty"`
-			}
+			Sessions        []models.TrainingSession
 			WorkoutTypeID   int64
+			Locale          locale.Locale
 		}{
-			Plan:            plan,
-			WorkoutTypeName: workoutTypeName,
-			Sessions:        sessions,
+			Plan:            plan.TrainingPlan,
+			WorkoutTypeName: plan.WorkoutType,
+			Sessions:        plan.Sessions,
 			WorkoutTypeID:   plan.WorkoutTypeID,
+			Locale:          requestLocale(r),
 		}
 
-		if err := tmpl.Execute(w, data); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
+		render(w, r, tmpl, data)
 	}
 }
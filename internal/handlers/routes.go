@@ -1,22 +1,60 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
+
+	"training-tracker/internal/config"
+	"training-tracker/internal/service"
 )
 
-func RegisterRoutes(mux *http.ServeMux, db *sql.DB) {
+// RegisterRoutes adds the pages and, as far as they are enabled, the
+// optional parts of the server.
+func RegisterRoutes(mux *http.ServeMux, svc *service.Service, features config.Features) {
 	// Session completion handler
-	mux.HandleFunc("/complete-session/", handleCompleteSession(db))
-	
+	mux.HandleFunc("/complete-session/", handleCompleteSession(svc))
+
 	// Plans handlers
-	mux.HandleFunc("/plans", handleListPlans(db))
-	mux.HandleFunc("/plans/create", handleCreatePlan(db))
-	mux.HandleFunc("/plans/", handleViewPlan(db))
-	
+	mux.HandleFunc("/plans", handleListPlans(svc))
+	mux.HandleFunc("/plans/create", handleCreatePlan(svc))
+	mux.HandleFunc("/plans/", handlePlanPages(svc))
+
 	// Sessions handlers
-	mux.HandleFunc("/sessions/create/", handleCreateSession(db))
-	
+	mux.HandleFunc("/sessions/create/", handleCreateSession(svc))
+
+	// JSON Schema of the YAML import format, for editors
+	mux.HandleFunc("/schema/plan.json", handlePlanSchema(svc))
+
+	// Export handlers
+	if features.ICS {
+		mux.HandleFunc("/calendar.ics", handleICS(svc))
+	}
+
+	// JSON API
+	if features.API {
+		mux.HandleFunc("/api/sessions", handleSessionsJSON(svc))
+		mux.HandleFunc("/api/workout-types", handleAPIWorkoutTypes(svc))
+		mux.HandleFunc("/api/settings", handleAPISettings(svc))
+		mux.HandleFunc("/api/plans", handleAPIPlans(svc))
+		mux.HandleFunc("/api/plans/", handleAPIPlan(svc))
+		mux.HandleFunc("/api/sessions/", handleAPISession(svc))
+		mux.HandleFunc("/api/heatmap", handleHeatmapJSON(svc))
+		mux.HandleFunc("/api/streaks", handleStreaks(svc))
+	}
+
+	// Heatmap handler
+	mux.HandleFunc("/heatmap.svg", handleHeatmapSVG(svc))
+
+	// Analytics handlers
+	if features.Analytics {
+		mux.HandleFunc("/analytics", handleAnalytics(svc))
+		mux.HandleFunc("/analytics/load.svg", handleLoadSVG(svc))
+		mux.HandleFunc("/api/analytics/load", handleLoadJSON(svc))
+		mux.HandleFunc("/api/analytics/volume", handleVolume(svc))
+	}
+
+	// Settings handler
+	mux.HandleFunc("/settings", handleSettings(svc))
+
 	// Calendar handler
-	mux.HandleFunc("/", handleCalendar(db))
+	mux.HandleFunc("/", handleCalendar(svc))
 }
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"training-tracker/internal/service"
+)
+
+// handlePlanSchema serves the JSON Schema of the YAML import format, which
+// editors can check plans against while they are written, e.g. with
+//
+//	# yaml-language-server: $schema=https://example.org/schema/plan.json
+func handlePlanSchema(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		types, err := svc.WorkoutTypes(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		// Editors fetch it from other origins
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeJSON(w, service.PlanSchema(types))
+	}
+}
@@ -1,131 +1,114 @@
 package handlers
 
 import (
-	"database/sql"
-	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/service"
 )
 
-func handleCreateSession(db *sql.DB) http.HandlerFunc {
-	tmpl := template.Must(template.ParseFiles("internal/templates/create_session.html"))
+// sessionForm is the form to add a session to a plan, with what was entered
+// and what is wrong with it when it is shown again.
+type sessionForm struct {
+	PlanID      string
+	WorkoutType string
+	Form        url.Values
+	Errors      formErrors
+}
+
+// sessionFields are the fields of the session form that show their own
+// errors; problems of others are shown above the form.
+var sessionFields = []string{"date", "description", "start_time", "duration_minutes", "session_order", "hfmax"}
+
+func handleCreateSession(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("create_session.html", nil)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract plan ID from URL
 		planID := strings.TrimPrefix(r.URL.Path, "/sessions/create/")
 		if planID == "" {
-			http.Error(w, "Plan ID is required", http.StatusBadRequest)
+			httpError(w, r, "Plan ID is required", http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.ParseInt(planID, 10, 64)
+		if err != nil {
+			httpError(w, r, "Plan not found", http.StatusNotFound)
 			return
 		}
 
 		// Get workout type for the plan
-		var workoutType string
-		err := db.QueryRow(`
-			SELECT wt.name 
-			FROM workout_types wt 
-			JOIN training_plans tp ON tp.workout_type_id = wt.id 
-			WHERE tp.id = ?`, planID).Scan(&workoutType)
+		workoutType, err := svc.PlanWorkoutType(r.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			if err == service.ErrNotFound {
+				httpError(w, r, "Plan not found", http.StatusNotFound)
+				return
+			}
+			internalError(w, r, err)
 			return
 		}
 
+		data := sessionForm{PlanID: planID, WorkoutType: workoutType, Errors: formErrors{}}
+
 		if r.Method == "GET" {
-			data := struct {
-				PlanID      string
-				WorkoutType string
-			}{
-				PlanID:      planID,
-				WorkoutType: workoutType,
-			}
-			if err := tmpl.Execute(w, data); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+			render(w, r, tmpl, data)
 			return
 		}
 
 		if r.Method == "POST" {
 			// Parse form data
 			if err := r.ParseForm(); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				httpError(w, r, "Invalid form data", http.StatusBadRequest)
 				return
 			}
+			data.Form = r.PostForm
 
-			// Begin transaction
-			tx, err := db.Begin()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			session := service.NewSession{
+				Description: r.FormValue("description"),
+				StartTime:   r.FormValue("start_time"),
+				HFMax:       r.FormValue("hfmax"),
 			}
-			defer tx.Rollback()
-
-			// Parse and validate date
-			date, err := time.Parse("2006-01-02", r.FormValue("date"))
-			if err != nil {
-				http.Error(w, "Invalid date format", http.StatusBadRequest)
-				return
+			if value := r.FormValue("date"); value == "" {
+				data.Errors.addError(r, "date", "Date is required")
+			} else if session.Date, err = dates.Parse(value); err != nil {
+				data.Errors.addError(r, "date", "Invalid date format")
 			}
-
-			// Insert base session
-			result, err := tx.Exec(`
-				INSERT INTO training_sessions (plan_id, session_order, description, date)
-				VALUES (?, NULLIF(?, ''), ?, ?)`,
-				planID,
-				r.FormValue("session_order"),
-				r.FormValue("description"),
-				date)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			if value := r.FormValue("session_order"); value != "" {
+				order, err := strconv.Atoi(value)
+				if err != nil {
+					data.Errors.addError(r, "session_order", "Invalid session order")
+				}
+				session.Order = &order
 			}
-
-			sessionID, err := result.LastInsertId()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+			if value := r.FormValue("duration_minutes"); value != "" {
+				if session.Duration, err = strconv.Atoi(value); err != nil {
+					data.Errors.addError(r, "duration_minutes", "Invalid duration")
+				}
 			}
 
-			// Handle workout-type specific data
-			switch workoutType {
-			case "cycling":
-				hfMax, _ := strconv.Atoi(r.FormValue("hf_max"))
-				_, err = tx.Exec(`
-					INSERT INTO cycling_sessions (session_id, hfmax)
-					VALUES (?, ?)`,
-					sessionID, hfMax)
-			case "mobility":
-				_, err = tx.Exec(`
-					INSERT INTO mobility_sessions (session_id)
-					VALUES (?)`,
-					sessionID)
-			case "sandbag":
-				_, err = tx.Exec(`
-					INSERT INTO sandbag_sessions (session_id)
-					VALUES (?)`,
-					sessionID)
-			case "core":
-				_, err = tx.Exec(`
-					INSERT INTO core_sessions (session_id)
-					VALUES (?)`,
-					sessionID)
+			err = svc.ValidateSession(session)
+			if err != nil && !data.Errors.add(r, err, "", sessionFields...) {
+				serviceError(w, r, err)
+				return
 			}
-
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if len(data.Errors) > 0 {
+				renderStatus(w, r, tmpl, data, http.StatusBadRequest)
 				return
 			}
 
-			// Commit transaction
-			if err := tx.Commit(); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if _, err := svc.AddSession(r.Context(), id, session); err != nil {
+				if data.Errors.add(r, err, "", sessionFields...) {
+					renderStatus(w, r, tmpl, data, http.StatusBadRequest)
+					return
+				}
+				serviceError(w, r, err)
 				return
 			}
 
 			// Redirect back to plan view
-			http.Redirect(w, r, "/plans/"+planID, http.StatusSeeOther)
+			redirect(w, r, "/plans/"+planID)
 		}
 	}
 }
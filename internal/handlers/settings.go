@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"training-tracker/internal/locale"
+	"training-tracker/internal/service"
+)
+
+// commonTimeZones are offered as suggestions on the settings page.
+var commonTimeZones = []string{
+	"UTC",
+	"Europe/Berlin",
+	"Europe/Vienna",
+	"Europe/Zurich",
+	"Europe/London",
+	"America/New_York",
+	"America/Chicago",
+	"America/Los_Angeles",
+	"Asia/Tokyo",
+	"Australia/Sydney",
+}
+
+func handleSettings(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("settings.html", nil)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			settings, err := svc.Settings(r.Context())
+			if err != nil {
+				internalError(w, r, err)
+				return
+			}
+
+			data := struct {
+				service.Settings
+				TimeZones  []string
+				ServerZone string
+				Locales    []locale.Locale
+			}{
+				Settings:   settings,
+				TimeZones:  commonTimeZones,
+				ServerZone: time.Local.String(),
+			}
+			for _, code := range locale.Codes {
+				data.Locales = append(data.Locales, locale.Get(code))
+			}
+
+			render(w, r, tmpl, data)
+			return
+		}
+
+		if r.Method == "POST" {
+			if err := r.ParseForm(); err != nil {
+				httpError(w, r, "Invalid form data", http.StatusBadRequest)
+				return
+			}
+
+			restingHR, err := strconv.Atoi(r.FormValue("resting_hr"))
+			if err != nil {
+				httpError(w, r, "Invalid resting heart rate", http.StatusBadRequest)
+				return
+			}
+			maxHR, err := strconv.Atoi(r.FormValue("max_hr"))
+			if err != nil {
+				httpError(w, r, "Invalid maximum heart rate", http.StatusBadRequest)
+				return
+			}
+
+			err = svc.SaveSettings(r.Context(), service.Settings{
+				RestingHR:  restingHR,
+				MaxHR:      maxHR,
+				TimeZone:   r.FormValue("timezone"),
+				LocaleCode: r.FormValue("locale"),
+				WeekStart:  r.FormValue("week_start"),
+			})
+			if err != nil {
+				serviceError(w, r, err)
+				return
+			}
+
+			redirect(w, r, "/settings")
+			return
+		}
+
+		httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
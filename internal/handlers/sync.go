@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"training-tracker/internal/locale"
+	"training-tracker/internal/models"
+	"training-tracker/internal/service"
+)
+
+// syncForm is the form to sync a plan with an updated YAML.
+type syncForm struct {
+	Plan   models.TrainingPlan
+	Form   url.Values
+	Errors formErrors
+}
+
+// syncField is a value of a sync preview. Old is what a changed value was.
+type syncField struct {
+	Label   string
+	Value   string
+	Old     string
+	Changed bool
+}
+
+// syncRow is a session of a sync preview.
+type syncRow struct {
+	Action    string
+	Number    int
+	Completed bool
+	Fields    []syncField
+}
+
+// syncColumns are the columns of a sync preview with the fields of the
+// import format they show.
+var syncColumns = []struct {
+	label  string
+	fields []string
+}{
+	{"Order", []string{"order"}},
+	{"Date", []string{"date", "time"}},
+	{"Description", []string{"description"}},
+	{"Minutes", []string{"duration"}},
+	{"HF Max", []string{"hfmax"}},
+	{"Key", []string{"key"}},
+}
+
+// syncActions are the labels of what a sync does to a session.
+var syncActions = map[service.SyncAction]string{
+	service.SyncAdd:     "Added",
+	service.SyncUpdate:  "Updated",
+	service.SyncDelete:  "Deleted",
+	service.SyncArchive: "Archived",
+	service.SyncRestore: "Restored",
+}
+
+// sessionValues returns what the columns of a sync preview show of a
+// session.
+func sessionValues(loc locale.Locale, s *models.TrainingSession) []string {
+	values := make([]string, len(syncColumns))
+	if s == nil {
+		return values
+	}
+	if s.SessionOrder != nil {
+		values[0] = strconv.Itoa(*s.SessionOrder)
+	}
+	values[1] = loc.LongDate(s.Date)
+	if s.StartTime != nil {
+		values[1] += ", " + *s.StartTime
+	}
+	values[2] = s.Description
+	if s.Duration != nil {
+		values[3] = strconv.Itoa(*s.Duration)
+	}
+	values[4] = s.HFMax
+	values[5] = s.Key
+	return values
+}
+
+// syncRows lays out the changes of a sync for the preview. The HF Max column
+// is left out unless the plan is for cycling.
+func syncRows(r *http.Request, sync service.PlanSync) []syncRow {
+	loc := requestLocale(r)
+	var rows []syncRow
+	for _, c := range sync.Changes {
+		row := syncRow{Action: loc.T(syncActions[c.Action]), Number: c.Number}
+		before, after := sessionValues(loc, c.Before), sessionValues(loc, c.After)
+		if c.Before != nil {
+			row.Completed = c.Before.Completed
+		}
+		for i, column := range syncColumns {
+			if column.label == "HF Max" && sync.WorkoutType != "cycling" {
+				continue
+			}
+			field := syncField{Label: column.label, Value: after[i]}
+			if c.After == nil {
+				field.Value = before[i]
+			}
+			for _, name := range column.fields {
+				if slices.Contains(c.Fields, name) {
+					field.Changed = true
+					field.Old = before[i]
+				}
+			}
+			row.Fields = append(row.Fields, field)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// planFields lists the changes a sync makes to the plan itself.
+func planFields(sync service.PlanSync) []syncField {
+	values := map[string][2]string{
+		"name":        {sync.Before.Name, sync.After.Name},
+		"description": {sync.Before.Description, sync.After.Description},
+		"source":      {sync.Before.Source, sync.After.Source},
+		"author":      {sync.Before.Author, sync.After.Author},
+	}
+	labels := map[string]string{
+		"name":        "Plan Name",
+		"description": "Description",
+		"source":      "Source",
+		"author":      "Author",
+	}
+	var fields []syncField
+	for _, name := range sync.Fields {
+		v := values[name]
+		fields = append(fields, syncField{Label: labels[name], Old: v[0], Value: v[1], Changed: true})
+	}
+	return fields
+}
+
+// handleSyncPlan updates a plan from a new version of its YAML, such as a
+// fixed file of the coach. The changes are shown before they are stored.
+func handleSyncPlan(svc *service.Service) http.HandlerFunc {
+	tmpl := parseTemplate("sync_plan.html", nil)
+	previewTmpl := parseTemplate("preview_sync.html", nil)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "POST" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, _, ok := pathID(r.URL.Path, "/plans/")
+		if !ok {
+			httpError(w, r, "Plan not found", http.StatusNotFound)
+			return
+		}
+		plan, err := svc.Plan(r.Context(), id)
+		if err != nil {
+			if err == service.ErrNotFound {
+				httpError(w, r, "Plan not found", http.StatusNotFound)
+				return
+			}
+			internalError(w, r, err)
+			return
+		}
+		data := syncForm{Plan: plan.TrainingPlan, Errors: formErrors{}}
+
+		if r.Method == "GET" {
+			render(w, r, tmpl, data)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil && err != http.ErrNotMultipart {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				httpError(w, r, "The upload is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			httpError(w, r, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		data.Form = r.PostForm
+		if r.FormValue("edit") != "" {
+			// Back from the preview to change the YAML
+			render(w, r, tmpl, data)
+			return
+		}
+
+		workoutTypes, err := svc.WorkoutTypes(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		settings, err := svc.Settings(r.Context())
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		sources, err := planSources(r)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		if len(sources) == 0 {
+			data.Errors.addError(r, "yaml_sessions", "Paste the plan's YAML or choose its file")
+		}
+
+		var plans []service.NewPlan
+		var origin planSource
+		for _, source := range sources {
+			docs, err := service.ParsePlans([]byte(source.Data), settings.Location())
+			for _, doc := range docs {
+				var p service.NewPlan
+				p, err = doc.Plan(workoutTypes, service.NewPlan{})
+				if err != nil {
+					break
+				}
+				plans = append(plans, p)
+				origin = source
+			}
+			if err == nil && len(docs) == 0 {
+				err = &service.InputError{Field: "yaml_sessions", Message: "No plan found in the YAML"}
+			}
+			if err != nil && !data.Errors.addSource(r, source, err) {
+				serviceError(w, r, err)
+				return
+			}
+		}
+		if len(data.Errors) == 0 && len(plans) > 1 {
+			data.Errors.addError(r, "yaml_sessions", "The YAML holds %d plans, but only one can be synced", len(plans))
+		}
+		if len(data.Errors) > 0 {
+			renderStatus(w, r, tmpl, data, http.StatusBadRequest)
+			return
+		}
+
+		sync := svc.PreviewSync
+		if r.FormValue("confirm") != "" {
+			sync = svc.SyncPlan
+		}
+		result, err := sync(r.Context(), id, plans[0])
+		if err != nil {
+			if data.Errors.addSource(r, origin, err) {
+				renderStatus(w, r, tmpl, data, http.StatusBadRequest)
+				return
+			}
+			serviceError(w, r, err)
+			return
+		}
+		if r.FormValue("confirm") != "" {
+			redirect(w, r, fmt.Sprintf("/plans/%d", id))
+			return
+		}
+
+		var uploads []planSource
+		for _, source := range sources {
+			if source.Name != "" {
+				uploads = append(uploads, source)
+			}
+		}
+		render(w, r, previewTmpl, struct {
+			Plan      models.TrainingPlan
+			Form      url.Values
+			Uploads   []planSource
+			Sync      service.PlanSync
+			Fields    []syncField
+			Rows      []syncRow
+			Added     int
+			Updated   int
+			Deleted   int
+			Archived  int
+			Restored  int
+			Unchanged int
+		}{
+			Plan:      plan.TrainingPlan,
+			Form:      data.Form,
+			Uploads:   uploads,
+			Sync:      result,
+			Fields:    planFields(result),
+			Rows:      syncRows(r, result),
+			Added:     result.Count(service.SyncAdd),
+			Updated:   result.Count(service.SyncUpdate),
+			Deleted:   result.Count(service.SyncDelete),
+			Archived:  result.Count(service.SyncArchive),
+			Restored:  result.Count(service.SyncRestore),
+			Unchanged: result.Unchanged,
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/service"
+	"training-tracker/internal/storage"
+)
+
+type VolumeRow struct {
+	Period            string  `json:"period"`
+	PlanID            int64   `json:"plan_id"`
+	PlanName          string  `json:"plan_name"`
+	WorkoutType       string  `json:"workout_type"`
+	PlannedSessions   int     `json:"planned_sessions"`
+	CompletedSessions int     `json:"completed_sessions"`
+	PlannedMinutes    int     `json:"planned_minutes"`
+	CompletedMinutes  int     `json:"completed_minutes"`
+	DistanceKm        float64 `json:"distance_km"`
+}
+
+type VolumeData struct {
+	Period      string      `json:"period"`
+	Year        int         `json:"year"`
+	PlanID      int64       `json:"plan_id,omitempty"`
+	WorkoutType string      `json:"workout_type,omitempty"`
+	Rows        []VolumeRow `json:"rows"`
+	Totals      []VolumeRow `json:"totals"`
+}
+
+// add sums the counters of other into r.
+func (r *VolumeRow) add(other VolumeRow) {
+	r.PlannedSessions += other.PlannedSessions
+	r.CompletedSessions += other.CompletedSessions
+	r.PlannedMinutes += other.PlannedMinutes
+	r.CompletedMinutes += other.CompletedMinutes
+	r.DistanceKm += other.DistanceKm
+}
+
+// volumePeriod returns the ISO week ("2025-W03") or month ("2025-01") a day
+// belongs to.
+func volumePeriod(day time.Time, period string) string {
+	if period == "month" {
+		return day.Format("2006-01")
+	}
+	year, week := day.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// volumeRange returns the days of a year, from the first to after the last.
+// By week, these are the days of the ISO year, which runs from the Monday of
+// the week with January 4th, so that weeks at the turn of the year are not
+// cut in two.
+func volumeRange(year int, period string) (from, to time.Time) {
+	if period == "month" {
+		from = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, 0)
+	}
+	return isoYearStart(year), isoYearStart(year + 1)
+}
+
+// isoYearStart returns the Monday of the first ISO week of a year.
+func isoYearStart(year int) time.Time {
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	return jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7)
+}
+
+// volumeFromRequest reads the period, year, plan and type query parameters
+// and sums up planned and completed sessions per period and plan.
+func volumeFromRequest(svc *service.Service, r *http.Request) (VolumeData, error) {
+	query := r.URL.Query()
+
+	settings, err := svc.Settings(r.Context())
+	if err != nil {
+		return VolumeData{}, err
+	}
+
+	data := VolumeData{
+		Period:      "week",
+		Year:        dates.Today(settings.Location()).Year(),
+		WorkoutType: query.Get("type"),
+		Rows:        []VolumeRow{},
+		Totals:      []VolumeRow{},
+	}
+	if query.Get("period") == "month" {
+		data.Period = "month"
+	}
+	if year, err := strconv.Atoi(query.Get("year")); err == nil {
+		data.Year = year
+	}
+	if planID, err := strconv.ParseInt(query.Get("plan"), 10, 64); err == nil {
+		data.PlanID = planID
+	}
+
+	from, to := volumeRange(data.Year, data.Period)
+
+	// Aggregate per day in the database; ISO weeks are bucketed below since
+	// SQLite has no reliable ISO week format.
+	days, err := svc.VolumePerDay(r.Context(),
+		storage.DateRange{From: from, To: to},
+		storage.StatsFilter{WorkoutType: data.WorkoutType, PlanID: data.PlanID},
+	)
+	if err != nil {
+		return data, err
+	}
+
+	type key struct {
+		period string
+		planID int64
+	}
+	byPlan := make(map[key]*VolumeRow)
+	byPeriod := make(map[string]*VolumeRow)
+	for _, day := range days {
+		row := VolumeRow{
+			PlanID:            day.PlanID,
+			PlanName:          day.PlanName,
+			WorkoutType:       day.WorkoutType,
+			PlannedSessions:   day.PlannedSessions,
+			CompletedSessions: day.CompletedSessions,
+			PlannedMinutes:    day.PlannedMinutes,
+			CompletedMinutes:  day.CompletedMinutes,
+			DistanceKm:        day.DistanceKm,
+		}
+
+		date, err := dates.Parse(day.Date)
+		if err != nil {
+			return data, err
+		}
+		row.Period = volumePeriod(date, data.Period)
+
+		k := key{row.Period, row.PlanID}
+		if existing, ok := byPlan[k]; ok {
+			existing.add(row)
+		} else {
+			planRow := row
+			byPlan[k] = &planRow
+		}
+
+		if total, ok := byPeriod[row.Period]; ok {
+			total.add(row)
+		} else {
+			byPeriod[row.Period] = &VolumeRow{Period: row.Period}
+			byPeriod[row.Period].add(row)
+		}
+	}
+
+	for _, row := range byPlan {
+		data.Rows = append(data.Rows, *row)
+	}
+	sort.Slice(data.Rows, func(i, j int) bool {
+		if data.Rows[i].Period != data.Rows[j].Period {
+			return data.Rows[i].Period < data.Rows[j].Period
+		}
+		return data.Rows[i].PlanName < data.Rows[j].PlanName
+	})
+
+	for _, total := range byPeriod {
+		data.Totals = append(data.Totals, *total)
+	}
+	sort.Slice(data.Totals, func(i, j int) bool {
+		return data.Totals[i].Period < data.Totals[j].Period
+	})
+
+	return data, nil
+}
+
+// writeVolumeCSV writes one line per period and plan.
+func writeVolumeCSV(w http.ResponseWriter, data VolumeData) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="volume-%s-%d.csv"`, data.Period, data.Year))
+
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"period",
+		"plan_id",
+		"plan",
+		"workout_type",
+		"planned_sessions",
+		"completed_sessions",
+		"planned_minutes",
+		"completed_minutes",
+		"distance_km",
+	})
+	for _, row := range data.Rows {
+		out.Write([]string{
+			row.Period,
+			strconv.FormatInt(row.PlanID, 10),
+			row.PlanName,
+			row.WorkoutType,
+			strconv.Itoa(row.PlannedSessions),
+			strconv.Itoa(row.CompletedSessions),
+			strconv.Itoa(row.PlannedMinutes),
+			strconv.Itoa(row.CompletedMinutes),
+			strconv.FormatFloat(row.DistanceKm, 'f', 1, 64),
+		})
+	}
+	out.Flush()
+}
+
+func handleVolume(svc *service.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := volumeFromRequest(svc, r)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "csv" {
+			writeVolumeCSV(w, data)
+			return
+		}
+		writeJSON(w, data)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVolumePeriod(t *testing.T) {
+	tests := []struct {
+		day    string
+		period string
+		want   string
+	}{
+		{"2025-03-05", "week", "2025-W10"},
+		{"2025-03-05", "month", "2025-03"},
+		// Days of the first week of the next ISO year
+		{"2024-12-30", "week", "2025-W01"},
+		{"2024-12-30", "month", "2024-12"},
+		// Days of the last week of the previous ISO year
+		{"2021-01-03", "week", "2020-W53"},
+		{"2027-01-01", "week", "2026-W53"},
+		{"2026-12-28", "week", "2026-W53"},
+	}
+	for _, tt := range tests {
+		day, err := time.Parse("2006-01-02", tt.day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := volumePeriod(day, tt.period); got != tt.want {
+			t.Errorf("volumePeriod(%s, %s) = %s, want %s", tt.day, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestVolumeRange(t *testing.T) {
+	tests := []struct {
+		year     int
+		period   string
+		from, to string
+	}{
+		{2025, "month", "2025-01-01", "2026-01-01"},
+		{2025, "week", "2024-12-30", "2025-12-29"},
+		{2020, "week", "2019-12-30", "2021-01-04"},
+		{2026, "week", "2025-12-29", "2027-01-04"},
+		{2027, "week", "2027-01-04", "2028-01-03"},
+	}
+	for _, tt := range tests {
+		from, to := volumeRange(tt.year, tt.period)
+		if got := from.Format("2006-01-02"); got != tt.from {
+			t.Errorf("volumeRange(%d, %s) starts %s, want %s", tt.year, tt.period, got, tt.from)
+		}
+		if got := to.Format("2006-01-02"); got != tt.to {
+			t.Errorf("volumeRange(%d, %s) ends %s, want %s", tt.year, tt.period, got, tt.to)
+		}
+		if tt.period != "week" {
+			continue
+		}
+		// Every day of the range is in a week of the year, the days around
+		// it are not
+		for day := from.AddDate(0, 0, -1); !day.After(to); day = day.AddDate(0, 0, 1) {
+			year, _ := day.ISOWeek()
+			inside := !day.Before(from) && day.Before(to)
+			if (year == tt.year) != inside {
+				t.Errorf("%s is in ISO year %d, but inside range of %d is %v", day.Format("2006-01-02"), year, tt.year, inside)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package locale formats dates and lays out weeks the way the user is used
+// to, independent of the server's environment.
+package locale
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Locale struct {
+	Code string
+	Name string
+	// Key into the message catalog
+	Language  string
+	WeekStart time.Weekday
+	// Indexed by time.Weekday and time.Month - 1
+	weekdays      [7]string
+	shortWeekdays [7]string
+	months        [12]string
+	shortMonths   [12]string
+	// Go layouts, month and weekday names are translated
+	longDate    string
+	shortDate   string
+	numericDate string
+	monthYear   string
+}
+
+var locales = map[string]Locale{
+	"de": {
+		Code:          "de",
+		Name:          "Deutsch",
+		Language:      "de",
+		WeekStart:     time.Monday,
+		weekdays:      [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortWeekdays: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		months:        [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths:   [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		longDate:      "2. January 2006",
+		shortDate:     "2. Jan",
+		numericDate:   "02.01.2006",
+		monthYear:     "January 2006",
+	},
+	"en": {
+		Code:          "en",
+		Name:          "English",
+		Language:      "en",
+		WeekStart:     time.Monday,
+		weekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		shortWeekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		months:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		longDate:      "2 January 2006",
+		shortDate:     "2 Jan",
+		numericDate:   "02/01/2006",
+		monthYear:     "January 2006",
+	},
+	"en-US": {
+		Code:          "en-US",
+		Name:          "English (US)",
+		Language:      "en",
+		WeekStart:     time.Sunday,
+		weekdays:      [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		shortWeekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		months:        [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		longDate:      "January 2, 2006",
+		shortDate:     "Jan 2",
+		numericDate:   "01/02/2006",
+		monthYear:     "January 2006",
+	},
+}
+
+// Default is used when no or an unknown locale is configured.
+const Default = "en"
+
+// Codes lists the available locales in a stable order.
+var Codes = []string{"de", "en", "en-US"}
+
+// Get returns the locale for code, or the default locale if code is unknown.
+func Get(code string) Locale {
+	if l, ok := locales[code]; ok {
+		return l
+	}
+	return locales[Default]
+}
+
+// Exists reports whether code names a known locale.
+func Exists(code string) bool {
+	_, ok := locales[code]
+	return ok
+}
+
+// Match picks the best available locale for an Accept-Language header,
+// like "de-AT,de;q=0.9,en;q=0.8", and returns the default if none fits.
+func Match(acceptLanguage string) string {
+	best, bestQ := Default, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, q := strings.TrimSpace(part), 1.0
+		if i := strings.Index(tag, ";"); i >= 0 {
+			param := strings.TrimSpace(tag[i+1:])
+			if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+				q = v
+			}
+			tag = strings.TrimSpace(tag[:i])
+		}
+		if code := match(tag); code != "" && q > bestQ {
+			best, bestQ = code, q
+		}
+	}
+	return best
+}
+
+// match returns the locale for a language tag, falling back from a regional
+// variant ("de-AT") to the language ("de").
+func match(tag string) string {
+	for _, code := range Codes {
+		if strings.EqualFold(code, tag) {
+			return code
+		}
+	}
+	language := strings.SplitN(tag, "-", 2)[0]
+	for _, code := range Codes {
+		if strings.EqualFold(code, language) {
+			return code
+		}
+	}
+	return ""
+}
+
+// WithWeekStart returns a copy of l whose weeks start on day.
+func (l Locale) WithWeekStart(day time.Weekday) Locale {
+	l.WeekStart = day
+	return l
+}
+
+// StartOfWeek returns the first day of the week containing day.
+func (l Locale) StartOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(l.WeekStart) + 7) % 7))
+}
+
+// ISOWeek returns the ISO 8601 week of the week starting at start. Weeks
+// that start on another day than Monday are numbered after their Thursday,
+// which always lies in the ISO week most of their days belong to.
+func (l Locale) ISOWeek(start time.Time) (year, week int) {
+	thursday := start.AddDate(0, 0, (int(time.Thursday)-int(start.Weekday())+7)%7)
+	return thursday.ISOWeek()
+}
+
+// Weekdays returns the weekday names in the order of the locale's week.
+func (l Locale) Weekdays() []string {
+	return l.ordered(l.weekdays)
+}
+
+// ShortWeekdays returns the abbreviated weekday names in the order of the
+// locale's week.
+func (l Locale) ShortWeekdays() []string {
+	return l.ordered(l.shortWeekdays)
+}
+
+func (l Locale) ordered(names [7]string) []string {
+	out := make([]string, 7)
+	for i := range out {
+		out[i] = names[(int(l.WeekStart)+i)%7]
+	}
+	return out
+}
+
+// Month returns the name of m.
+func (l Locale) Month(m time.Month) string {
+	return l.months[m-1]
+}
+
+// ShortMonth returns the abbreviated name of m.
+func (l Locale) ShortMonth(m time.Month) string {
+	return l.shortMonths[m-1]
+}
+
+// LongDate formats t like "2. Januar 2025" or "January 2, 2025".
+func (l Locale) LongDate(t time.Time) string {
+	return l.Format(t, l.longDate)
+}
+
+// ShortDate formats t without the year, like "2. Jan." or "Jan 2".
+func (l Locale) ShortDate(t time.Time) string {
+	return l.Format(t, l.shortDate)
+}
+
+// NumericDate formats t with digits only, like "02.01.2025".
+func (l Locale) NumericDate(t time.Time) string {
+	return l.Format(t, l.numericDate)
+}
+
+// MonthYear formats t like "Januar 2025".
+func (l Locale) MonthYear(t time.Time) string {
+	return l.Format(t, l.monthYear)
+}
+
+// Format formats t with a Go layout and translates month and weekday names.
+func (l Locale) Format(t time.Time, layout string) string {
+	// Replace the name tokens by placeholders first; translated names could
+	// otherwise contain layout tokens themselves ("Montag" starts with "Mon").
+	names := []string{
+		l.months[t.Month()-1],
+		l.weekdays[t.Weekday()],
+		l.shortMonths[t.Month()-1],
+		l.shortWeekdays[t.Weekday()],
+	}
+	tokens := []string{"January", "Monday", "Jan", "Mon"}
+	for i, token := range tokens {
+		layout = strings.ReplaceAll(layout, token, placeholder(i))
+	}
+
+	out := t.Format(layout)
+	for i, name := range names {
+		out = strings.ReplaceAll(out, placeholder(i), name)
+	}
+	return out
+}
+
+// placeholder returns a character from the private use area, which neither
+// appears in layouts nor in real text.
+func placeholder(i int) string {
+	return string(rune(0xE000 + i))
+}
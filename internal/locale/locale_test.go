@@ -0,0 +1,136 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestStartOfWeek(t *testing.T) {
+	tests := []struct {
+		day       string
+		weekStart time.Weekday
+		want      string
+	}{
+		{"2025-01-01", time.Monday, "2024-12-30"},
+		{"2025-01-01", time.Sunday, "2024-12-29"},
+		{"2025-01-01", time.Saturday, "2024-12-28"},
+		{"2025-01-05", time.Monday, "2024-12-30"},
+		{"2025-01-05", time.Sunday, "2025-01-05"},
+		{"2025-01-04", time.Saturday, "2025-01-04"},
+		{"2025-01-03", time.Saturday, "2024-12-28"},
+	}
+	for _, tt := range tests {
+		l := Get("de").WithWeekStart(tt.weekStart)
+		if got := l.StartOfWeek(date(t, tt.day)).Format("2006-01-02"); got != tt.want {
+			t.Errorf("StartOfWeek(%s) with weeks from %s = %s, want %s", tt.day, tt.weekStart, got, tt.want)
+		}
+	}
+}
+
+func TestISOWeek(t *testing.T) {
+	tests := []struct {
+		start string
+		year  int
+		week  int
+	}{
+		// Monday starts are the ISO weeks themselves
+		{"2024-12-30", 2025, 1},
+		{"2020-12-28", 2020, 53},
+		{"2025-03-03", 2025, 10},
+		// Sunday and Saturday starts are numbered after their Thursday
+		{"2024-12-29", 2025, 1},
+		{"2024-12-28", 2025, 1},
+		{"2020-12-27", 2020, 53},
+		{"2021-01-03", 2021, 1},
+		{"2021-01-02", 2021, 1},
+		{"2025-12-27", 2026, 1},
+		{"2026-12-27", 2026, 53},
+	}
+	for _, tt := range tests {
+		start := date(t, tt.start)
+		l := Get("en").WithWeekStart(start.Weekday())
+		if year, week := l.ISOWeek(start); year != tt.year || week != tt.week {
+			t.Errorf("ISOWeek(%s %s) = %d-W%02d, want %d-W%02d", start.Weekday(), tt.start, year, week, tt.year, tt.week)
+		}
+	}
+
+	// Consecutive weeks get consecutive numbers, whatever day they start on
+	for _, weekStart := range []time.Weekday{time.Monday, time.Sunday, time.Saturday} {
+		l := Get("en").WithWeekStart(weekStart)
+		start := l.StartOfWeek(date(t, "2019-12-01"))
+		prevYear, prevWeek := l.ISOWeek(start)
+		for i := 0; i < 52*8; i++ {
+			start = start.AddDate(0, 0, 7)
+			year, week := l.ISOWeek(start)
+			if !(year == prevYear && week == prevWeek+1 || year == prevYear+1 && week == 1) {
+				t.Fatalf("weeks from %s: %d-W%02d follows %d-W%02d", weekStart, year, week, prevYear, prevWeek)
+			}
+			prevYear, prevWeek = year, week
+		}
+	}
+}
+
+func TestWeekdays(t *testing.T) {
+	tests := []struct {
+		code      string
+		weekStart time.Weekday
+		first     string
+		last      string
+	}{
+		{"de", time.Monday, "Mo", "So"},
+		{"en-US", time.Sunday, "Sun", "Sat"},
+		{"en", time.Saturday, "Sat", "Fri"},
+	}
+	for _, tt := range tests {
+		days := Get(tt.code).WithWeekStart(tt.weekStart).ShortWeekdays()
+		if days[0] != tt.first || days[6] != tt.last {
+			t.Errorf("%s weeks from %s: %v, want %s to %s", tt.code, tt.weekStart, days, tt.first, tt.last)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", Default},
+		{"de-AT,de;q=0.9,en;q=0.8", "de"},
+		{"en-US,en;q=0.9", "en-US"},
+		{"en-GB", "en"},
+		{"fr-FR,fr;q=0.9,de;q=0.5", "de"},
+		{"fr", Default},
+		{"en;q=0.5, de;q=0.8", "de"},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.header); got != tt.want {
+			t.Errorf("Match(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLongDate(t *testing.T) {
+	day := date(t, "2025-03-02")
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"de", "2. März 2025"},
+		{"en", "2 March 2025"},
+		{"en-US", "March 2, 2025"},
+	}
+	for _, tt := range tests {
+		if got := Get(tt.code).LongDate(day); got != tt.want {
+			t.Errorf("%s: LongDate = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package locale
+
+import "fmt"
+
+// T translates msg into the locale's language and formats it with args like
+// fmt.Sprintf. Messages are written in English; anything without a
+// translation is used as it is.
+func (l Locale) T(msg string, args ...interface{}) string {
+	if translated, ok := messages[l.Language][msg]; ok {
+		msg = translated
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// messages maps a language to the translations of the English messages.
+var messages = map[string]map[string]string{
+	"de": {
+		"%d / %d completed": "%d / %d erledigt",
+		"%d added, %d updated, %d restored, %d deleted and %d archived": "%d hinzugefügt, %d geändert, %d wiederhergestellt, %d gelöscht und %d archiviert",
+		"%d sessions": "%d Einheiten",
+		"%d sessions of this import on the same day":                                                           "%d Einheiten dieses Imports am selben Tag",
+		"%d sessions will be added, %d updated, %d restored, %d deleted and %d archived; %d stay as they are.": "%d Einheiten werden hinzugefügt, %d aktualisiert, %d wiederhergestellt, %d gelöscht und %d archiviert; %d bleiben unverändert.",
+		"%d sessions will be created, %d of them with warnings.":                                               "%d Einheiten werden angelegt, %d davon mit Hinweisen.",
+		"%s: %d sessions, %d min":                                                                              "%s: %d Einheiten, %d min",
+		"%s must be a whole number":                                                                            "%s muss eine ganze Zahl sein",
+		"… and %d more problems":                                                                               "… und %d weitere Probleme",
+		"Add New Session":                                                                                      "Neue Einheit hinzufügen",
+		"Added":                                                                                                "Hinzugefügt",
+		"Admin token:":                                                                                         "Admin-Token:",
+		"All plans":                                                                                            "Alle Pläne",
+		"All workout types":                                                                                    "Alle Trainingsarten",
+		"Analytics":                                                                                            "Auswertung",
+		"Apply Changes":                                                                                        "Änderungen übernehmen",
+		"Archived":                                                                                             "Archiviert",
+		"Archived: completed, but no longer in the plan's YAML": "Archiviert: erledigt, aber nicht mehr im YAML des Plans",
+		"Author":                     "Autor",
+		"Author: %s":                 "Autor: %s",
+		"Avg HR (bpm)":               "Ø Puls (bpm)",
+		"Back to Calendar":           "Zurück zum Kalender",
+		"Back to the history":        "Zurück zum Verlauf",
+		"Back to the plan":           "Zurück zum Plan",
+		"Backfill Planned Durations": "Geplante Dauer nachtragen",
+		"Backup downloads are off until an admin token is set.": "Das Herunterladen von Sicherungen ist ausgeschaltet, bis ein Admin-Token gesetzt ist.",
+		"Backups": "Sicherungen",
+		"Backups are only supported for SQLite databases.": "Sicherungen werden nur für SQLite-Datenbanken unterstützt.",
+		"Back to editing":        "Zurück zur Bearbeitung",
+		"Browser language":       "Sprache des Browsers",
+		"Calendar Week %d of %d": "Kalenderwoche %d/%d",
+		"Cancel":                 "Abbrechen",
+		"Change":                 "Änderung",
+		"Color by:":              "Färben nach:",
+		"Complete":               "Erledigt",
+		"Completed":              "Erledigt",
+		"Completed against planned sessions and minutes.": "Erledigte im Vergleich zu geplanten Einheiten und Minuten.",
+		"Completed sessions per day in %d":                "Erledigte Einheiten pro Tag in %d",
+		"Completions are kept.":                           "Erledigungen bleiben erhalten.",
+		"Controls the language, day and month names and how dates are written. Week numbers always follow ISO 8601.": "Bestimmt die Sprache, Tages- und Monatsnamen und wie Daten geschrieben werden. Kalenderwochen folgen immer ISO 8601.",
+		"Could not be parsed (%d)":    "Nicht erkannt (%d)",
+		"Create New Plan":             "Neuen Plan erstellen",
+		"Create New Training Plan":    "Neuen Trainingsplan erstellen",
+		"Create New Training Session": "Neue Trainingseinheit erstellen",
+		"Create Plan":                 "Plan erstellen",
+		"Create Session":              "Einheit erstellen",
+		"Create Training Plan":        "Trainingsplan erstellen",
+		"Create Training Session":     "Trainingseinheit erstellen",
+		"Created: %s":                 "Erstellt: %s",
+		"Current Week":                "Aktuelle Woche",
+		"Daily load is the TRIMP of the recorded heart rate. Without heart rate, it is the RPE (1-10) times the minutes.": "Die Tageslast ist der TRIMP des aufgezeichneten Pulses. Ohne Puls ist sie der RPE (1-10) mal die Minuten.",
+		"Date":                "Datum",
+		"Date:":               "Datum:",
+		"Date is required":    "Datum fehlt",
+		"Date is in the past": "Datum liegt in der Vergangenheit",
+		"Decides which day \"today\" is in the calendar and at what time sessions start.": "Bestimmt, welcher Tag im Kalender „heute“ ist und zu welcher Uhrzeit Einheiten beginnen.",
+		"Deleted":                 "Gelöscht",
+		"Description":             "Beschreibung",
+		"Description:":            "Beschreibung:",
+		"Description is required": "Beschreibung fehlt",
+		"Details":                 "Details",
+		"Distance (km)":           "Distanz (km)",
+		"Download":                "Herunterladen",
+		"Download Backup":         "Sicherung herunterladen",
+		"Download CSV":            "CSV herunterladen",
+		"Duration":                "Dauer",
+		"Duration (min)":          "Dauer (min)",
+		"Error":                   "Fehler",
+		"Every session with a duration in its description already has one.": "Jede Einheit mit einer Dauer in der Beschreibung hat bereits eine.",
+		"Fatigue (ATL)":                  "Ermüdung (ATL)",
+		"File":                           "Datei",
+		"Files have to be chosen again.": "Dateien müssen erneut ausgewählt werden.",
+		"Fitness (CTL)":                  "Fitness (CTL)",
+		"Fitness and Fatigue":            "Fitness und Ermüdung",
+		"Form (TSB)":                     "Form (TSB)",
+		"HF Max: %s":                     "HF max: %s",
+		"HF Max":                         "HF max",
+		"Heart Rate":                     "Puls",
+		"Heart Rate Max (%):":            "Maximalpuls (%):",
+		"Heart Rate Max: %s bpm":         "Maximalpuls: %s bpm",
+		"History":                        "Verlauf",
+		"History (days):":                "Verlauf (Tage):",
+		"History of %s":                  "Verlauf von %s",
+		"Import %d sessions":             "%d Einheiten importieren",
+		"Import %d plans":                "%d Pläne importieren",
+		"Invalid admin token":            "Ungültiges Admin-Token",
+		"Invalid form data":              "Ungültige Formulardaten",
+		"Invalid YAML format: %v":        "Ungültiges YAML-Format: %v",
+		"Invalid YAML on line %d: %s":    "Ungültiges YAML in Zeile %d: %s",
+		"Invalid date format":            "Ungültiges Datumsformat",
+		"Invalid distance":               "Ungültige Distanz",
+		"Invalid duration":               "Ungültige Dauer",
+		"Invalid date %q in session %d":  "Ungültiges Datum %q in Einheit %d",
+		"Invalid from date %q":           "Ungültiges Startdatum %q",
+		"Invalid heart rate":             "Ungültiger Puls",
+		"Invalid JSON: %v":               "Ungültiges JSON: %v",
+		"Invalid maximum heart rate":     "Ungültiger Maximalpuls",
+		"Invalid RPE":                    "Ungültiger RPE-Wert",
+		"Invalid resting heart rate":     "Ungültiger Ruhepuls",
+		"Invalid session order":          "Ungültige Reihenfolge",
+		"Invalid start time":             "Ungültige Startzeit",
+		"Invalid time %q in session %d, expected HH:MM": "Ungültige Uhrzeit %q in Einheit %d, erwartet wird HH:MM",
+		"Invalid to date %q":                            "Ungültiges Enddatum %q",
+		"Invalid week start":                            "Ungültiger Wochenbeginn",
+		"JSON Schema of the format, for editors":        "JSON Schema des Formats, für Editoren",
+		"Key":                                           "Schlüssel",
+		"Key %q is given to sessions %d and %d":         "Schlüssel %q ist bei den Einheiten %d und %d angegeben",
+		"Language and Calendar":                         "Sprache und Kalender",
+		"Locale default":                                "Standard der Sprache",
+		"Locale:":                                       "Sprache und Region:",
+		"Log details":                                   "Details erfassen",
+		"Line %d: expected \"sessions:\" with a list of sessions": "Zeile %d: erwartet wird „sessions:“ mit einer Liste von Einheiten",
+		"Line %d: %s must be text":                                "Zeile %d: %s muss Text sein",
+		"Line %d: unknown field %q":                               "Zeile %d: unbekanntes Feld %q",
+		"Line %d: unknown workout type %q":                        "Zeile %d: unbekannte Trainingsart %q",
+		"Mark as complete":                                        "Als erledigt markieren",
+		"Maximum Heart Rate (bpm):":                               "Maximalpuls (bpm):",
+		"Method not allowed":                                      "Methode nicht erlaubt",
+		"Minutes":                                                 "Minuten",
+		"Monday":                                                  "Montag",
+		"Name and workout type may be left empty for plans whose YAML gives them.": "Name und Trainingsart dürfen leer bleiben, wenn das YAML sie angibt.",
+		"Month":                          "Monat",
+		"Month Overview - %s %d":         "Monatsübersicht – %s %d",
+		"Next Week":                      "Nächste Woche",
+		"No changes recorded yet.":       "Noch keine Änderungen erfasst.",
+		"No sessions":                    "Keine Einheiten",
+		"No sessions created yet.":       "Noch keine Einheiten angelegt.",
+		"No sessions in %d.":             "Keine Einheiten in %d.",
+		"No training plans created yet.": "Noch keine Trainingspläne angelegt.",
+		"No %s given, which %s sessions should have": "Kein %s angegeben, das %s-Einheiten haben sollten",
+		"No plan found in the YAML":                  "Kein Plan im YAML gefunden",
+		"Not found":                                  "Nicht gefunden",
+		"None.":                                      "Keine.",
+		"Nothing has been changed yet.":              "Noch wurde nichts geändert.",
+		"Nothing has been imported yet.":             "Noch wurde nichts importiert.",
+		"Number of sessions":                         "Anzahl der Einheiten",
+		"Open sessions are projected from their planned duration and target zone (dashed).": "Offene Einheiten werden aus geplanter Dauer und Zielbereich hochgerechnet (gestrichelt).",
+		"Or paste the YAML:":               "Oder das YAML einfügen:",
+		"Order":                            "Reihenfolge",
+		"Parsed from the description (%d)": "Aus der Beschreibung erkannt (%d)",
+		"Paste the plan's YAML or choose its file": "Bitte das YAML des Plans einfügen oder seine Datei auswählen",
+		"Period": "Zeitraum",
+		"Plan":   "Plan",
+		"Plan %q of this import also has a session on this day": "Plan %q dieses Imports hat an diesem Tag ebenfalls eine Einheit",
+		"Plan created":                              "Plan angelegt",
+		"Plan File (YAML):":                         "Plandatei (YAML):",
+		"Plan ID is required":                       "Plan-ID fehlt",
+		"Plan Files (YAML, optional):":              "Plandateien (YAML, optional):",
+		"Plan %q already has a session on this day": "Plan %q hat an diesem Tag bereits eine Einheit",
+		"Plan Name":                                 "Name des Plans",
+		"Plan Name:":                                "Name des Plans:",
+		"Plan name is required":                     "Name des Plans fehlt",
+		"Plan not found":                            "Plan nicht gefunden",
+		"Plan:":                                     "Plan:",
+		"Planned duration filled in":                "Geplante Dauer ergänzt",
+		"Planned Duration in Minutes (optional, read from the description if empty):": "Geplante Dauer in Minuten (optional, wird sonst aus der Beschreibung gelesen):",
+		"Planned: %s (%s completed)": "Geplant: %s (%s erledigt)",
+		"Planned:":                   "Geplant:",
+		"Preview Sync":               "Vorschau der Synchronisierung",
+		"Previous Week":              "Vorherige Woche",
+		"Preview Import":             "Import-Vorschau",
+		"Restored":                   "Wiederhergestellt",
+		"Revert":                     "Zurücksetzen",
+		"Revert %s":                  "%s zurücksetzen",
+		"Revert to this version":     "Auf diese Version zurücksetzen",
+		"Reverted":                   "Zurückgesetzt",
+		"RPE (1-10)":                 "RPE (1-10)",
+		"Resting Heart Rate (bpm):":  "Ruhepuls (bpm):",
+		"Saturday":                   "Samstag",
+		"Save":                       "Speichern",
+		"Select a type":              "Art auswählen",
+		"Select a workout type":      "Bitte eine Trainingsart auswählen",
+		"Session %d, line %d: %s is only for %s sessions":                        "Einheit %d, Zeile %d: %s gibt es nur bei Einheiten der Art %s",
+		"Session %d, line %d: invalid duration %q, expected a number of minutes": "Einheit %d, Zeile %d: ungültige Dauer %q, erwartet wird eine Anzahl Minuten",
+		"Session added":                                  "Einheit hinzugefügt",
+		"Session completed":                              "Einheit erledigt",
+		"Session Order (optional):":                      "Reihenfolge (optional):",
+		"Session %d, line %d: %s is given twice":         "Einheit %d, Zeile %d: %s ist doppelt angegeben",
+		"Session %d, line %d: %s must be a whole number": "Einheit %d, Zeile %d: %s muss eine ganze Zahl sein",
+		"Session %d, line %d: %s must be text":           "Einheit %d, Zeile %d: %s muss Text sein",
+		"Session %d, line %d: date is required":          "Einheit %d, Zeile %d: Datum fehlt",
+		"Session %d, line %d: expected fields like \"date:\" and \"description:\"": "Einheit %d, Zeile %d: erwartet werden Felder wie „date:“ und „description:“",
+		"Session %d, line %d: invalid date %q, expected YYYY-MM-DD":                "Einheit %d, Zeile %d: ungültiges Datum %q, erwartet wird JJJJ-MM-TT",
+		"Session %d, line %d: invalid time %q, expected HH:MM":                     "Einheit %d, Zeile %d: ungültige Uhrzeit %q, erwartet wird HH:MM",
+		"Session %d, line %d: unknown field %q":                                    "Einheit %d, Zeile %d: unbekanntes Feld %q",
+		"Session not found":                                                        "Einheit nicht gefunden",
+		"Sessions":                                                                 "Einheiten",
+		"Sessions YAML (Optional):":                                                "Einheiten als YAML (optional):",
+		"Scheduled snapshots in %s":                                                "Geplante Sicherungen in %s",
+		"Show Changes":                                                             "Änderungen anzeigen",
+		"Size (KB)":                                                                "Größe (KB)",
+		"Source":                                                                   "Quelle",
+		"Source: %s":                                                               "Quelle: %s",
+		"Settings":                                                                 "Einstellungen",
+		"Show":                                                                     "Anzeigen",
+		"Start Time (optional):":                                                   "Startzeit (optional):",
+		"Store %d durations":                                                       "%d Dauern speichern",
+		"Something went wrong on our side. If it keeps happening, report error ID %s.": "Bei uns ist etwas schiefgelaufen. Falls das wiederholt passiert, bitte die Fehler-ID %s melden.",
+		"Stored the planned duration of %d sessions.":                                  "Die geplante Dauer von %d Einheiten wurde gespeichert.",
+		"Storing durations is off until an admin token is set.":                        "Das Speichern von Dauern ist ausgeschaltet, bis ein Admin-Token gesetzt ist.",
+		"Streak: %d (best %d)": "Serie: %d (beste %d)",
+		"Subscribe (ICS)":      "Abonnieren (ICS)",
+		"Sunday":               "Sonntag",
+		"Sync %s":              "%s synchronisieren",
+		"Sync from YAML":       "Aus YAML synchronisieren",
+		"Sync Training Plan":   "Trainingsplan synchronisieren",
+		"Synced from YAML":     "Aus YAML abgeglichen",
+		"Taken (UTC)":          "Erstellt (UTC)",
+		"Takes a consistent snapshot of the database while the server keeps running.": "Erstellt eine konsistente Kopie der Datenbank, während der Server weiterläuft.",
+		"The plan already matches the YAML.":                                          "Der Plan entspricht bereits dem YAML.",
+		"The plan is already at this version.":                                        "Der Plan ist bereits auf diesem Stand.",
+		"The plan was not created. Please correct the marked fields.":                 "Der Plan wurde nicht erstellt. Bitte die markierten Felder korrigieren.",
+		"The plan was not synced. Please correct the marked fields.":                  "Der Plan wurde nicht synchronisiert. Bitte die markierten Felder korrigieren.",
+		"The session was not added. Please correct the marked fields.":                "Die Einheit wurde nicht hinzugefügt. Bitte die markierten Felder korrigieren.",
+		"The upload is too large":                                                     "Die hochgeladenen Dateien sind zu groß",
+		"The YAML holds %d plans, but only one can be synced":                         "Das YAML enthält %d Pläne, synchronisiert werden kann aber nur einer",
+		"The YAML is for %s sessions, but the plan is for %s sessions":                "Das YAML ist für Einheiten der Art %s, der Plan aber für die Art %s",
+		"Time Zone": "Zeitzone",
+		"Time Zone (empty for the server's zone, %s):": "Zeitzone (leer für die Zeitzone des Servers, %s):",
+		"Total":                "Summe",
+		"Total Progress":       "Gesamtfortschritt",
+		"Training Calendar":    "Trainingskalender",
+		"Training Plans":       "Trainingspläne",
+		"Training Sessions":    "Trainingseinheiten",
+		"Training load chart":  "Diagramm der Trainingslast",
+		"Type":                 "Art",
+		"Unknown workout type": "Unbekannte Trainingsart",
+		"Unknown locale":       "Unbekannte Sprache",
+		"Unknown time zone":    "Unbekannte Zeitzone",
+		"Updated":              "Aktualisiert",
+		"Updates the plan from a new version of its YAML. Sessions are matched by their key, or else by their date and description, or else by their order or position. Completions are kept, and completed sessions the YAML dropped are archived instead of deleted.": "Aktualisiert den Plan aus einer neuen Fassung seines YAML. Einheiten werden über ihren Schlüssel zugeordnet, sonst über Datum und Beschreibung, sonst über ihre Reihenfolge oder Position. Erledigungen bleiben erhalten, und erledigte Einheiten, die im YAML fehlen, werden archiviert statt gelöscht.",
+		"Used to compute the training load (TRIMP) of sessions with heart rate data.": "Wird für die Trainingslast (TRIMP) von Einheiten mit Pulsdaten verwendet.",
+		"Version not found":      "Version nicht gefunden",
+		"View All Plans":         "Alle Pläne anzeigen",
+		"View Plan":              "Plan anzeigen",
+		"View Training Plan":     "Trainingsplan anzeigen",
+		"Volume per month in %d": "Umfang pro Monat in %d",
+		"Volume per week in %d":  "Umfang pro Woche in %d",
+		"Volume per:":            "Umfang pro:",
+		"Warnings":               "Hinweise",
+		"Week":                   "Woche",
+		"Week starts on:":        "Woche beginnt am:",
+		"When":                   "Wann",
+		"Who":                    "Wer",
+		"Workout Type:":          "Trainingsart:",
+		"Workout Type: %s":       "Trainingsart: %s",
+		"Year Overview %d":       "Jahresübersicht %d",
+		"Year:":                  "Jahr:",
+		// Default workout types
+		"cycling":  "Radfahren",
+		"mobility": "Mobilität",
+		"sandbag":  "Sandsack",
+		"core":     "Rumpf",
+	},
+}
@@ -0,0 +1,277 @@
+// Package metrics keeps counters and histograms and serves them, along with
+// values computed when scraped, in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds in seconds for request and query
+// durations.
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics of a process.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []func(ctx context.Context) ([]Family, error)
+}
+
+// Family is a metric with its samples, as written in one scrape.
+type Family struct {
+	Name string
+	Help string
+	// "counter", "gauge" or "histogram"
+	Type   string
+	Labels []string
+	// Values of counters and gauges; histograms write their own lines
+	Samples []Sample
+	lines   []string
+}
+
+// Sample is one value of a family, with a value for each of its labels.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// Collect adds a function that computes families on every scrape, such as
+// gauges of the database's contents.
+func (r *Registry) Collect(fn func(ctx context.Context) ([]Family, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, fn)
+}
+
+// Handler serves the metrics. A collector that fails is logged and left
+// out, so that the other metrics are still scraped.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		collectors := append([]func(context.Context) ([]Family, error){}, r.collectors...)
+		r.mu.Unlock()
+
+		var families []Family
+		for _, collect := range collectors {
+			fs, err := collect(req.Context())
+			if err != nil {
+				slog.Error("collecting metrics", "error", err)
+				continue
+			}
+			families = append(families, fs...)
+		}
+		sort.SliceStable(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		out := bufio.NewWriter(w)
+		for _, f := range families {
+			f.write(out)
+		}
+		out.Flush()
+	})
+}
+
+func (f Family) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.Name, helpEscaper.Replace(f.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, s := range f.Samples {
+		fmt.Fprintf(w, "%s%s %s\n", f.Name, labelSet(f.Labels, s.Labels, ""), formatValue(s.Value))
+	}
+	for _, line := range f.lines {
+		w.WriteString(line)
+	}
+}
+
+// labelSet formats label pairs as {a="x",b="y"}, with an le label added for
+// histogram buckets.
+func labelSet(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	if le != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `le="%s"`, le)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// key joins label values into a map key.
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec counts events by label values.
+type CounterVec struct {
+	name, help string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*Sample
+}
+
+// NewCounter registers a counter with the given labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*Sample)}
+	r.Collect(func(context.Context) ([]Family, error) {
+		return []Family{c.family()}, nil
+	})
+	return c
+}
+
+// Inc counts one event with the given label values.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add counts v events with the given label values.
+func (c *CounterVec) Add(v float64, values ...string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", c.name, len(c.labels), len(values)))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key(values)]
+	if !ok {
+		s = &Sample{Labels: append([]string(nil), values...)}
+		c.values[key(values)] = s
+	}
+	s.Value += v
+}
+
+func (c *CounterVec) family() Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := Family{Name: c.name, Help: c.help, Type: "counter", Labels: c.labels}
+	for _, s := range c.values {
+		f.Samples = append(f.Samples, *s)
+	}
+	sortSamples(f.Samples)
+	return f
+}
+
+func sortSamples(samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool { return key(samples[i].Labels) < key(samples[j].Labels) })
+}
+
+// HistogramVec counts observations, such as durations, into buckets by
+// label values.
+type HistogramVec struct {
+	name, help string
+	buckets    []float64
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds,
+// in increasing order, and labels.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, buckets: buckets, labels: labels, values: make(map[string]*histogram)}
+	r.Collect(func(context.Context) ([]Family, error) {
+		return []Family{h.family()}, nil
+	})
+	return h
+}
+
+// Observe records v with the given label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	if len(values) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", h.name, len(h.labels), len(values)))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key(values)]
+	if !ok {
+		s = &histogram{labels: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.values[key(values)] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) family() Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	f := Family{Name: h.name, Help: h.help, Type: "histogram", Labels: h.labels}
+	for _, k := range keys {
+		s := h.values[k]
+		for i, upper := range h.buckets {
+			f.lines = append(f.lines, fmt.Sprintf("%s_bucket%s %d\n",
+				h.name, labelSet(h.labels, s.labels, formatValue(upper)), s.counts[i]))
+		}
+		f.lines = append(f.lines,
+			fmt.Sprintf("%s_bucket%s %d\n", h.name, labelSet(h.labels, s.labels, "+Inf"), s.count),
+			fmt.Sprintf("%s_sum%s %s\n", h.name, labelSet(h.labels, s.labels, ""), formatValue(s.sum)),
+			fmt.Sprintf("%s_count%s %d\n", h.name, labelSet(h.labels, s.labels, ""), s.count))
+	}
+	return f
+}
+
+// CollectRuntime adds gauges of the Go runtime: goroutines and heap.
+func (r *Registry) CollectRuntime() {
+	r.Collect(func(context.Context) ([]Family, error) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		return []Family{
+			{Name: "go_goroutines", Help: "Number of goroutines that currently exist.", Type: "gauge",
+				Samples: []Sample{{Value: float64(runtime.NumGoroutine())}}},
+			{Name: "go_memstats_heap_alloc_bytes", Help: "Number of heap bytes allocated and still in use.", Type: "gauge",
+				Samples: []Sample{{Value: float64(mem.HeapAlloc)}}},
+		}, nil
+	})
+}
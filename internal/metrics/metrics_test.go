@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got content type %q", got)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestCounter(t *testing.T) {
+	reg := &Registry{}
+	c := reg.NewCounter("requests_total", "Requests by \\ path,\nand kind.", "path", "kind")
+	c.Inc("/b", "plain")
+	c.Add(2.5, `/a"quoted"`, "back\\slash")
+	c.Inc("/b", "plain")
+	c.Inc("/c", "new\nline")
+
+	want := `# HELP requests_total Requests by \\ path,\nand kind.
+# TYPE requests_total counter
+requests_total{path="/a\"quoted\"",kind="back\\slash"} 2.5
+requests_total{path="/b",kind="plain"} 2
+requests_total{path="/c",kind="new\nline"} 1
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	reg := &Registry{}
+	h := reg.NewHistogram("duration_seconds", "Durations.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/")
+	h.Observe(0.1, "/")
+	h.Observe(0.5, "/")
+	h.Observe(3, "/")
+	h.Observe(2, "/plans")
+
+	want := `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/",le="0.1"} 2
+duration_seconds_bucket{route="/",le="1"} 3
+duration_seconds_bucket{route="/",le="+Inf"} 4
+duration_seconds_sum{route="/"} 3.65
+duration_seconds_count{route="/"} 4
+duration_seconds_bucket{route="/plans",le="0.1"} 0
+duration_seconds_bucket{route="/plans",le="1"} 0
+duration_seconds_bucket{route="/plans",le="+Inf"} 1
+duration_seconds_sum{route="/plans"} 2
+duration_seconds_count{route="/plans"} 1
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Families are sorted by name, and a failing collector leaves out only its
+// own.
+func TestCollect(t *testing.T) {
+	reg := &Registry{}
+	reg.Collect(func(context.Context) ([]Family, error) {
+		return []Family{{Name: "zeta", Help: "Last.", Type: "gauge", Samples: []Sample{{Value: 1}}}}, nil
+	})
+	reg.Collect(func(context.Context) ([]Family, error) {
+		return nil, errors.New("database gone")
+	})
+	reg.NewCounter("alpha_total", "First.")
+
+	want := `# HELP alpha_total First.
+# TYPE alpha_total counter
+# HELP zeta Last.
+# TYPE zeta gauge
+zeta 1
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	reg := &Registry{}
+	c := reg.NewCounter("requests_total", "Requests.", "path")
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a missing label value")
+		}
+	}()
+	c.Inc()
+}
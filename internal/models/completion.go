@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Completion records how a session actually went. Every detail is optional.
+type Completion struct {
+	SessionID   int64     `json:"session_id"`
+	CompletedAt time.Time `json:"completed_at"`
+	Duration    *int      `json:"duration_minutes,omitempty"`
+	AvgHR       *int      `json:"avg_hr,omitempty"`
+	RPE         *int      `json:"rpe,omitempty"`
+	DistanceKm  *float64  `json:"distance_km,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PlanChange is an entry of a plan's history: what was changed, by whom and
+// when, with snapshots as JSON of what the change touched before and after
+// it.
+type PlanChange struct {
+	ID     int64 `json:"id"`
+	PlanID int64 `json:"plan_id"`
+	// Set for changes of a single session, whose snapshots are of the
+	// session only
+	SessionID *int64    `json:"session_id,omitempty"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+	// Empty where there was nothing before or after the change
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
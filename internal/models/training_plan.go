@@ -7,4 +7,8 @@ type TrainingPlan struct {
 	Name          string    `json:"name"`
 	WorkoutTypeID int64     `json:"workout_type_id"`
 	CreatedAt     time.Time `json:"created_at"`
+	// What the file a plan was imported from tells about it
+	Description string `json:"description,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Author      string `json:"author,omitempty"`
 }
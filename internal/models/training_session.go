@@ -8,10 +8,19 @@ type TrainingSession struct {
 	SessionOrder *int      `json:"session_order"`
 	Description  string    `json:"description"`
 	Date         time.Time `json:"date"`
+	StartTime    *string   `json:"start_time,omitempty"`
+	Duration     *int      `json:"duration_minutes,omitempty"`
+	Completed    bool      `json:"completed"`
+	// Identifies the session in the YAML of its plan when syncing
+	Key string `json:"key,omitempty"`
+	// Dropped from the plan's YAML after it was completed
+	Archived bool `json:"archived,omitempty"`
+	// Type-specific details
+	HFMax string `json:"hfmax,omitempty"` // For cycling
 }
 
 type CyclingSession struct {
-	SessionID int64 `json:"session_id"`
+	SessionID int64  `json:"session_id"`
 	HFMax     string `json:"hfmax"`
 }
 
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"training-tracker/internal/models"
+	"training-tracker/internal/storage"
+)
+
+// Actions of the plan history.
+const (
+	ActionCreatePlan      = "create_plan"
+	ActionAddSession      = "add_session"
+	ActionCompleteSession = "complete_session"
+	ActionSetDuration     = "set_duration"
+	ActionSyncPlan        = "sync_plan"
+	ActionRevertPlan      = "revert_plan"
+)
+
+type actorKey struct{}
+
+// WithActor returns a context whose changes are recorded in the history as
+// made by name, such as the user a proxy authenticated.
+func WithActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, actorKey{}, name)
+}
+
+func actor(ctx context.Context) string {
+	name, _ := ctx.Value(actorKey{}).(string)
+	return name
+}
+
+// PlanSnapshot is a plan with all of its sessions, archived ones included,
+// as the history records it for changes to the whole plan.
+type PlanSnapshot struct {
+	Plan     models.TrainingPlan      `json:"plan"`
+	Sessions []models.TrainingSession `json:"sessions"`
+}
+
+// SessionSnapshot is a session as the history records it for changes to a
+// single session.
+type SessionSnapshot struct {
+	Session    models.TrainingSession `json:"session"`
+	Completion *models.Completion     `json:"completion,omitempty"`
+}
+
+func planSnapshot(ctx context.Context, store storage.Store, planID int64) (PlanSnapshot, error) {
+	plan, err := store.Plans().Get(ctx, planID)
+	if err != nil {
+		return PlanSnapshot{}, err
+	}
+	sessions, err := store.Sessions().ListByPlan(ctx, planID)
+	if err != nil {
+		return PlanSnapshot{}, err
+	}
+	return PlanSnapshot{Plan: plan, Sessions: sessions}, nil
+}
+
+func sessionSnapshot(ctx context.Context, store storage.Store, sessionID int64) (SessionSnapshot, error) {
+	session, err := store.Sessions().Get(ctx, sessionID)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	snapshot := SessionSnapshot{Session: session}
+	completion, err := store.Completions().Get(ctx, sessionID)
+	if err == nil {
+		snapshot.Completion = &completion
+	} else if err != storage.ErrNotFound {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// record adds a change to the history of a plan, made by the actor of ctx.
+// before and after are the snapshots of what the change touched, nil where
+// there was nothing.
+func record(ctx context.Context, tx storage.Store, planID int64, sessionID *int64, action string, before, after interface{}) error {
+	change := models.PlanChange{
+		PlanID:    planID,
+		SessionID: sessionID,
+		Action:    action,
+		Actor:     actor(ctx),
+	}
+	var err error
+	if before != nil {
+		if change.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if change.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	return tx.History().Add(ctx, &change)
+}
+
+// HistoryEntry is a change of a plan's history with what it did.
+type HistoryEntry struct {
+	models.PlanChange
+	// The session a change of a single session was made to, as it was after
+	// the change
+	Session *models.TrainingSession
+	// The changes to the plan and its sessions, for changes of the whole plan
+	Diff PlanSync
+}
+
+// PlanHistory returns the changes made to a plan, newest first.
+func (s *Service) PlanHistory(ctx context.Context, planID int64) ([]HistoryEntry, error) {
+	plan, err := s.store.Plans().Get(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+	workoutType, err := s.store.WorkoutTypes().Get(ctx, plan.WorkoutTypeID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.store.History().ListByPlan(ctx, planID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	for _, c := range changes {
+		entry := HistoryEntry{PlanChange: c}
+		if c.SessionID != nil {
+			var snapshot SessionSnapshot
+			data := c.After
+			if data == nil {
+				data = c.Before
+			}
+			if err := json.Unmarshal(data, &snapshot); err != nil {
+				return nil, err
+			}
+			entry.Session = &snapshot.Session
+		} else {
+			var before, after PlanSnapshot
+			if c.Before != nil {
+				if err := json.Unmarshal(c.Before, &before); err != nil {
+					return nil, err
+				}
+			}
+			if err := json.Unmarshal(c.After, &after); err != nil {
+				return nil, err
+			}
+			entry.Diff = snapshotSync(before, after, workoutType.Name)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// planVersion returns a plan as it was right after a change of its history,
+// by undoing the later changes on its current state.
+func planVersion(ctx context.Context, store storage.Store, planID, changeID int64) (PlanSnapshot, error) {
+	version, err := planSnapshot(ctx, store, planID)
+	if err != nil {
+		return version, err
+	}
+	changes, err := store.History().ListByPlan(ctx, planID)
+	if err != nil {
+		return version, err
+	}
+
+	for _, c := range changes {
+		if c.ID == changeID {
+			return version, nil
+		}
+		switch {
+		case c.SessionID == nil && c.Before == nil:
+			// The plan was created, there is nothing before
+			return version, ErrNotFound
+		case c.SessionID == nil:
+			// Decoded afresh, as decoding into the slice of sessions would
+			// keep fields of the later version that this one leaves out
+			var before PlanSnapshot
+			if err := json.Unmarshal(c.Before, &before); err != nil {
+				return version, err
+			}
+			version = before
+		case c.Before == nil:
+			// The session did not exist before
+			version.Sessions = removeSession(version.Sessions, *c.SessionID)
+		default:
+			var snapshot SessionSnapshot
+			if err := json.Unmarshal(c.Before, &snapshot); err != nil {
+				return version, err
+			}
+			version.Sessions = replaceSession(version.Sessions, snapshot.Session)
+		}
+	}
+	return version, ErrNotFound
+}
+
+func removeSession(sessions []models.TrainingSession, id int64) []models.TrainingSession {
+	var rest []models.TrainingSession
+	for _, s := range sessions {
+		if s.ID != id {
+			rest = append(rest, s)
+		}
+	}
+	return rest
+}
+
+func replaceSession(sessions []models.TrainingSession, session models.TrainingSession) []models.TrainingSession {
+	for i, s := range sessions {
+		if s.ID == session.ID {
+			sessions[i] = session
+			return sessions
+		}
+	}
+	return append(sessions, session)
+}
+
+// snapshotSync works out the changes that turn one version of a plan into
+// another. Sessions are matched by their ID. Whether sessions are completed
+// is left alone, so sessions to drop that were completed are archived.
+func snapshotSync(from, to PlanSnapshot, workoutType string) PlanSync {
+	sync := PlanSync{Before: from.Plan, After: from.Plan, WorkoutType: workoutType, Changes: []SessionChange{}}
+	metadata := []struct {
+		field string
+		value string
+		dest  *string
+	}{
+		{"name", to.Plan.Name, &sync.After.Name},
+		{"description", to.Plan.Description, &sync.After.Description},
+		{"source", to.Plan.Source, &sync.After.Source},
+		{"author", to.Plan.Author, &sync.After.Author},
+	}
+	for _, m := range metadata {
+		if m.value != *m.dest {
+			*m.dest = m.value
+			sync.Fields = append(sync.Fields, m.field)
+		}
+	}
+
+	current := make(map[int64]int)
+	for j, s := range from.Sessions {
+		current[s.ID] = j
+	}
+	kept := make(map[int64]bool)
+	for i := range to.Sessions {
+		target := to.Sessions[i]
+		j, ok := current[target.ID]
+		if !ok {
+			if target.Archived {
+				continue
+			}
+			added := target
+			added.ID = 0
+			added.PlanID = from.Plan.ID
+			added.Completed = false
+			sync.Changes = append(sync.Changes, SessionChange{Action: SyncAdd, Number: i + 1, After: &added})
+			continue
+		}
+		kept[target.ID] = true
+
+		stored := from.Sessions[j]
+		updated := target
+		updated.Completed = stored.Completed
+		fields := changedFields(stored, updated)
+		action := SyncUpdate
+		switch {
+		case stored.Archived && !updated.Archived:
+			action = SyncRestore
+		case !stored.Archived && updated.Archived:
+			action = SyncArchive
+		case len(fields) == 0:
+			sync.Unchanged++
+			continue
+		}
+		sync.Changes = append(sync.Changes, SessionChange{
+			Action: action,
+			Number: i + 1,
+			Before: &from.Sessions[j],
+			After:  &updated,
+			Fields: fields,
+		})
+	}
+	for j, s := range from.Sessions {
+		if kept[s.ID] || s.Archived {
+			continue
+		}
+		action := SyncDelete
+		if s.Completed {
+			action = SyncArchive
+		}
+		sync.Changes = append(sync.Changes, SessionChange{Action: action, Before: &from.Sessions[j]})
+	}
+	return sync
+}
+
+// PreviewRevert returns what RevertPlan would change. Nothing is stored.
+func (s *Service) PreviewRevert(ctx context.Context, planID, changeID int64) (PlanSync, error) {
+	return revertSync(ctx, s.store, planID, changeID)
+}
+
+// RevertPlan brings a plan and its sessions back to how they were right
+// after a change of its history. Completions are kept: sessions to drop
+// that were completed are archived instead. The revert is recorded in the
+// history itself, so that it can be undone as well.
+func (s *Service) RevertPlan(ctx context.Context, planID, changeID int64) (PlanSync, error) {
+	var sync PlanSync
+	err := s.store.InTx(ctx, func(tx storage.Store) error {
+		var err error
+		sync, err = revertSync(ctx, tx, planID, changeID)
+		if err != nil {
+			return err
+		}
+		return applyRecorded(ctx, tx, ActionRevertPlan, sync)
+	})
+	return sync, err
+}
+
+func revertSync(ctx context.Context, store storage.Store, planID, changeID int64) (PlanSync, error) {
+	current, err := planSnapshot(ctx, store, planID)
+	if err != nil {
+		return PlanSync{}, err
+	}
+	workoutType, err := store.WorkoutTypes().Get(ctx, current.Plan.WorkoutTypeID)
+	if err != nil {
+		return PlanSync{}, err
+	}
+	version, err := planVersion(ctx, store, planID, changeID)
+	if err != nil {
+		return PlanSync{}, err
+	}
+	return snapshotSync(current, version, workoutType.Name), nil
+}
+
+// applyRecorded stores the changes of a sync and records them in the
+// plan's history as action. A sync that changes nothing is not recorded.
+func applyRecorded(ctx context.Context, tx storage.Store, action string, sync PlanSync) error {
+	if len(sync.Fields) == 0 && len(sync.Changes) == 0 {
+		return nil
+	}
+	before, err := planSnapshot(ctx, tx, sync.Before.ID)
+	if err != nil {
+		return err
+	}
+	if err := applySync(ctx, tx, sync); err != nil {
+		return err
+	}
+	after, err := planSnapshot(ctx, tx, sync.Before.ID)
+	if err != nil {
+		return err
+	}
+	return record(ctx, tx, sync.Before.ID, nil, action, before, after)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"training-tracker/internal/models"
+)
+
+func TestPlanVersion(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	plan, err := svc.CreatePlan(ctx, NewPlan{
+		Name:          "Base",
+		WorkoutTypeID: workoutTypeID(t, svc, "core"),
+		Sessions: []NewSession{
+			{Key: "a", Description: "Plank", Date: mustDate(t, "2025-03-03")},
+			{Key: "b", Description: "Crunches", Date: mustDate(t, "2025-03-05")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := svc.AddSession(ctx, plan.ID, NewSession{Key: "c", Description: "Bridge", Date: mustDate(t, "2025-03-07")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.SyncPlan(ctx, plan.ID, NewPlan{
+		Description: "Three times a week",
+		Sessions: []NewSession{
+			{Key: "a", Description: "Side plank", Date: mustDate(t, "2025-03-04")},
+			{Key: "c", Description: "Bridge", Date: mustDate(t, "2025-03-07")},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.CompleteSession(ctx, models.Completion{SessionID: added.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := svc.PlanHistory(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]int64)
+	for _, entry := range history {
+		ids[entry.Action] = entry.ID
+	}
+
+	// Descriptions of the active sessions of each version
+	tests := []struct {
+		action      string
+		description string
+		sessions    []string
+		completed   bool
+	}{
+		{ActionCreatePlan, "", []string{"Plank", "Crunches"}, false},
+		{ActionAddSession, "", []string{"Plank", "Crunches", "Bridge"}, false},
+		{ActionSyncPlan, "Three times a week", []string{"Side plank", "Bridge"}, false},
+		{ActionCompleteSession, "Three times a week", []string{"Side plank", "Bridge"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			version, err := planVersion(ctx, svc.store, plan.ID, ids[tt.action])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version.Plan.Description != tt.description {
+				t.Errorf("got description %q, want %q", version.Plan.Description, tt.description)
+			}
+			var sessions []string
+			completed := false
+			for _, s := range version.Sessions {
+				if !s.Archived {
+					sessions = append(sessions, s.Description)
+				}
+				if s.ID == added.ID {
+					completed = s.Completed
+				}
+			}
+			if !reflect.DeepEqual(sessions, tt.sessions) {
+				t.Errorf("got sessions %q, want %q", sessions, tt.sessions)
+			}
+			if completed != tt.completed {
+				t.Errorf("added session completed: got %v, want %v", completed, tt.completed)
+			}
+		})
+	}
+
+	if _, err := planVersion(ctx, svc.store, plan.ID, ids[ActionCompleteSession]+100); err != ErrNotFound {
+		t.Errorf("unknown change: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestSnapshotSync(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	plan := models.TrainingPlan{ID: 1, Name: "Base", Description: "Old"}
+	from := PlanSnapshot{Plan: plan, Sessions: []models.TrainingSession{
+		{ID: 1, PlanID: 1, Description: "Same", Date: day(1)},
+		{ID: 2, PlanID: 1, Description: "Changed", Date: day(2), Completed: true},
+		{ID: 3, PlanID: 1, Description: "Dropped", Date: day(3)},
+		{ID: 4, PlanID: 1, Description: "Dropped and done", Date: day(4), Completed: true},
+		{ID: 5, PlanID: 1, Description: "Archived", Date: day(5), Archived: true},
+		{ID: 6, PlanID: 1, Description: "Still there", Date: day(6)},
+	}}
+	renamed := plan
+	renamed.Description = "New"
+	to := PlanSnapshot{Plan: renamed, Sessions: []models.TrainingSession{
+		{ID: 1, PlanID: 1, Description: "Same", Date: day(1)},
+		{ID: 2, PlanID: 1, Description: "Changed", Date: day(8)},
+		{ID: 5, PlanID: 1, Description: "Archived", Date: day(5)},
+		{ID: 6, PlanID: 1, Description: "Still there", Date: day(6), Archived: true},
+		{ID: 7, PlanID: 1, Description: "New", Date: day(7), Completed: true},
+		{ID: 9, PlanID: 1, Description: "Gone since", Date: day(9), Archived: true},
+	}}
+
+	sync := snapshotSync(from, to, "core")
+	if !reflect.DeepEqual(sync.Fields, []string{"description"}) || sync.After.Description != "New" {
+		t.Errorf("got plan fields %v with description %q, want the description changed to %q", sync.Fields, sync.After.Description, "New")
+	}
+	if sync.Unchanged != 1 {
+		t.Errorf("got %d unchanged sessions, want 1", sync.Unchanged)
+	}
+
+	type change struct {
+		action SyncAction
+		id     int64
+	}
+	want := []change{{SyncUpdate, 2}, {SyncRestore, 5}, {SyncArchive, 6}, {SyncAdd, 0}, {SyncDelete, 3}, {SyncArchive, 4}}
+	var got []change
+	for _, c := range sync.Changes {
+		var id int64
+		if c.Before != nil {
+			id = c.Before.ID
+		}
+		got = append(got, change{c.Action, id})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got changes %v, want %v", got, want)
+	}
+
+	// Completions stay as they are, and added sessions belong to the plan
+	if !sync.Changes[0].After.Completed || !reflect.DeepEqual(sync.Changes[0].Fields, []string{"date"}) {
+		t.Errorf("update: got %+v with fields %v, want session 2 moved and still completed", sync.Changes[0].After, sync.Changes[0].Fields)
+	}
+	if added := sync.Changes[3].After; added.ID != 0 || added.PlanID != 1 || added.Completed {
+		t.Errorf("add: got %+v, want a new uncompleted session of plan 1", added)
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/durations"
+	"training-tracker/internal/models"
+	"training-tracker/internal/storage"
+)
+
+// NewPlan is a plan to be created along with its sessions.
+type NewPlan struct {
+	Name          string
+	WorkoutTypeID int64
+	Description   string
+	Source        string
+	Author        string
+	Sessions      []NewSession
+}
+
+// NewSession is a session to be added to a plan.
+type NewSession struct {
+	// Identifies the session when its plan is synced; optional
+	Key string
+	// Position within the plan; nil appends the session
+	Order       *int
+	Description string
+	Date        time.Time
+	// "15:04", optional
+	StartTime string
+	// Planned minutes; zero means the description is searched for one
+	Duration int
+	// Type-specific fields
+	HFMax string // For cycling
+}
+
+// PlanDetail is a plan with its workout type and all of its sessions.
+type PlanDetail struct {
+	models.TrainingPlan
+	WorkoutType string                   `json:"workout_type"`
+	Sessions    []models.TrainingSession `json:"sessions"`
+}
+
+// plannedDuration returns the given duration in minutes, or the one found in
+// the description if none was given.
+func plannedDuration(minutes int, description string) *int {
+	if minutes > 0 {
+		return &minutes
+	}
+	if parsed, ok := durations.Parse(description); ok {
+		return &parsed
+	}
+	return nil
+}
+
+// validateSession checks a session before it is stored. index is the
+// session's position in an import, or zero for a single session.
+func validateSession(s NewSession, index int) FieldErrors {
+	var problems FieldErrors
+	if s.Date.IsZero() {
+		problems = append(problems, inputError("date", "Invalid date format"))
+	}
+	// Imported sessions may be just a date, as in the shipped plans
+	if index == 0 && strings.TrimSpace(s.Description) == "" {
+		problems = append(problems, inputError("description", "Description is required"))
+	}
+	if s.StartTime != "" {
+		if _, ok := dates.MinutesOfDay(s.StartTime); !ok {
+			if index > 0 {
+				problems = append(problems, inputError("time", "Invalid time %q in session %d, expected HH:MM", s.StartTime, index))
+			} else {
+				problems = append(problems, inputError("start_time", "Invalid start time"))
+			}
+		}
+	}
+	if s.Duration < 0 {
+		problems = append(problems, inputError("duration_minutes", "Invalid duration"))
+	}
+	return problems
+}
+
+// ValidateSession reports all problems of a session that AddSession would
+// refuse, without adding it.
+func (s *Service) ValidateSession(session NewSession) error {
+	return validateSession(session, 0).err()
+}
+
+// sessionModel returns a validated session as it is stored in a plan.
+func sessionModel(planID int64, s NewSession) models.TrainingSession {
+	session := models.TrainingSession{
+		PlanID:       planID,
+		SessionOrder: s.Order,
+		Description:  s.Description,
+		Date:         dates.Of(s.Date),
+		Duration:     plannedDuration(s.Duration, s.Description),
+		Key:          s.Key,
+		HFMax:        s.HFMax,
+	}
+	if s.StartTime != "" {
+		startTime := s.StartTime
+		session.StartTime = &startTime
+	}
+	return session
+}
+
+// addSession stores a validated session of a plan with the given workout
+// type.
+func addSession(ctx context.Context, tx storage.Store, planID int64, workoutType string, s NewSession) (models.TrainingSession, error) {
+	session := sessionModel(planID, s)
+	if session.SessionOrder == nil {
+		order, err := tx.Sessions().NextOrder(ctx, planID)
+		if err != nil {
+			return session, err
+		}
+		session.SessionOrder = &order
+	}
+
+	err := tx.Sessions().Create(ctx, &session, workoutType)
+	return session, err
+}
+
+// ValidatePlan reports all problems of a plan and its sessions that
+// CreatePlan would refuse, without storing anything.
+func (s *Service) ValidatePlan(ctx context.Context, p NewPlan) error {
+	return validatePlan(ctx, s.store, p)
+}
+
+func validatePlan(ctx context.Context, store storage.Store, p NewPlan) error {
+	var problems FieldErrors
+	if strings.TrimSpace(p.Name) == "" {
+		problems = append(problems, inputError("name", "Plan name is required"))
+	}
+	if _, err := store.WorkoutTypes().Get(ctx, p.WorkoutTypeID); err == storage.ErrNotFound {
+		problems = append(problems, inputError("workout_type_id", "Unknown workout type"))
+	} else if err != nil {
+		return err
+	}
+	keys := make(map[string]int)
+	for i, session := range p.Sessions {
+		problems = append(problems, validateSession(session, i+1)...)
+		if session.Key == "" {
+			continue
+		}
+		if first, ok := keys[session.Key]; ok {
+			problems = append(problems, inputError("key", "Key %q is given to sessions %d and %d", session.Key, first, i+1))
+			continue
+		}
+		keys[session.Key] = i + 1
+	}
+	return problems.err()
+}
+
+// CreatePlan validates the plan and all of its sessions and stores them
+// together.
+func (s *Service) CreatePlan(ctx context.Context, p NewPlan) (models.TrainingPlan, error) {
+	plans, err := s.CreatePlans(ctx, []NewPlan{p})
+	if err != nil {
+		return models.TrainingPlan{}, err
+	}
+	return plans[0], nil
+}
+
+// CreatePlans validates several plans, such as those of one import, and
+// stores either all of them or none.
+func (s *Service) CreatePlans(ctx context.Context, plans []NewPlan) ([]models.TrainingPlan, error) {
+	for _, p := range plans {
+		if err := s.ValidatePlan(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+
+	var created []models.TrainingPlan
+	err := s.store.InTx(ctx, func(tx storage.Store) error {
+		created = created[:0]
+		for _, p := range plans {
+			plan, err := createPlan(ctx, tx, p)
+			if err != nil {
+				return err
+			}
+			created = append(created, plan)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// createPlan stores a validated plan with its sessions.
+func createPlan(ctx context.Context, tx storage.Store, p NewPlan) (models.TrainingPlan, error) {
+	plan := models.TrainingPlan{
+		Name:          strings.TrimSpace(p.Name),
+		WorkoutTypeID: p.WorkoutTypeID,
+		Description:   strings.TrimSpace(p.Description),
+		Source:        strings.TrimSpace(p.Source),
+		Author:        strings.TrimSpace(p.Author),
+	}
+	workoutType, err := tx.WorkoutTypes().Get(ctx, plan.WorkoutTypeID)
+	if err == storage.ErrNotFound {
+		return plan, inputError("workout_type_id", "Unknown workout type")
+	}
+	if err != nil {
+		return plan, err
+	}
+
+	if err := tx.Plans().Create(ctx, &plan); err != nil {
+		return plan, err
+	}
+	for _, session := range p.Sessions {
+		if _, err := addSession(ctx, tx, plan.ID, workoutType.Name, session); err != nil {
+			return plan, err
+		}
+	}
+	created, err := planSnapshot(ctx, tx, plan.ID)
+	if err != nil {
+		return plan, err
+	}
+	return plan, record(ctx, tx, plan.ID, nil, ActionCreatePlan, nil, created)
+}
+
+// AddSession validates a session and appends it to a plan.
+func (s *Service) AddSession(ctx context.Context, planID int64, session NewSession) (models.TrainingSession, error) {
+	if err := s.ValidateSession(session); err != nil {
+		return models.TrainingSession{}, err
+	}
+
+	var created models.TrainingSession
+	err := s.store.InTx(ctx, func(tx storage.Store) error {
+		workoutType, err := s.planWorkoutType(ctx, tx, planID)
+		if err != nil {
+			return err
+		}
+		created, err = addSession(ctx, tx, planID, workoutType, session)
+		if err != nil {
+			return err
+		}
+		after, err := sessionSnapshot(ctx, tx, created.ID)
+		if err != nil {
+			return err
+		}
+		return record(ctx, tx, planID, &created.ID, ActionAddSession, nil, after)
+	})
+	return created, err
+}
+
+// planWorkoutType returns the name of the workout type of a plan.
+func (s *Service) planWorkoutType(ctx context.Context, store storage.Store, planID int64) (string, error) {
+	plan, err := store.Plans().Get(ctx, planID)
+	if err != nil {
+		return "", err
+	}
+	workoutType, err := store.WorkoutTypes().Get(ctx, plan.WorkoutTypeID)
+	if err != nil {
+		return "", err
+	}
+	return workoutType.Name, nil
+}
+
+// PlanWorkoutType returns the name of the workout type of a plan.
+func (s *Service) PlanWorkoutType(ctx context.Context, planID int64) (string, error) {
+	return s.planWorkoutType(ctx, s.store, planID)
+}
+
+// Plans returns all plans, newest first.
+func (s *Service) Plans(ctx context.Context) ([]models.TrainingPlan, error) {
+	return s.store.Plans().List(ctx)
+}
+
+// Plan returns a plan with all of its sessions in order.
+func (s *Service) Plan(ctx context.Context, id int64) (PlanDetail, error) {
+	plan, err := s.store.Plans().Get(ctx, id)
+	if err != nil {
+		return PlanDetail{}, err
+	}
+	workoutType, err := s.store.WorkoutTypes().Get(ctx, plan.WorkoutTypeID)
+	if err != nil {
+		return PlanDetail{}, err
+	}
+	sessions, err := s.store.Sessions().ListByPlan(ctx, id)
+	if err != nil {
+		return PlanDetail{}, err
+	}
+	if sessions == nil {
+		sessions = []models.TrainingSession{}
+	}
+	return PlanDetail{TrainingPlan: plan, WorkoutType: workoutType.Name, Sessions: sessions}, nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/storage"
+)
+
+// typeField is a field of the import format that only sessions of a
+// workout type have.
+type typeField struct {
+	name  string
+	value func(NewSession) string
+}
+
+// typeFields are the fields that sessions of a workout type are expected to
+// set: those the schema gives workout types for, read from the NewSession
+// field of the same name.
+var typeFields = buildTypeFields()
+
+func buildTypeFields() map[string][]typeField {
+	fields := make(map[string][]typeField)
+	t := reflect.TypeOf(SessionYAML{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		rule := sessionRules[name]
+		if len(rule.workoutTypes) == 0 {
+			continue
+		}
+		goName := t.Field(i).Name
+		if f, ok := reflect.TypeOf(NewSession{}).FieldByName(goName); !ok || f.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("service: no text field %s in NewSession for %q", goName, name))
+		}
+		field := typeField{name, func(s NewSession) string {
+			return reflect.ValueOf(s).FieldByName(goName).String()
+		}}
+		for _, workoutType := range rule.workoutTypes {
+			fields[workoutType] = append(fields[workoutType], field)
+		}
+	}
+	return fields
+}
+
+// ImportWarning is something about an imported session that is allowed but
+// likely a mistake. Like that of InputError, Message is written in English
+// and may be a format for Args.
+type ImportWarning struct {
+	Message string
+	Args    []interface{}
+}
+
+// PreviewSession is a session as CreatePlan would store it, along with what
+// looks wrong about it.
+type PreviewSession struct {
+	NewSession
+	// Position within the import, from 1
+	Number int
+	// Planned minutes as they would be stored, possibly read from the
+	// description
+	Planned  *int
+	Warnings []ImportWarning
+}
+
+// PlanPreview shows what importing a plan would create.
+type PlanPreview struct {
+	WorkoutType string
+	Sessions    []PreviewSession
+	// Number of sessions with warnings
+	Flagged int
+}
+
+// PreviewPlans validates the plans of an import like CreatePlans does and
+// returns the sessions each would create, flagging dates before today, days
+// with several sessions of the same plan, days other plans of the import or
+// already stored plans have sessions on and missing fields of the workout
+// type. Nothing is stored.
+func (s *Service) PreviewPlans(ctx context.Context, plans []NewPlan, today time.Time) ([]PlanPreview, error) {
+	imported := make([]map[time.Time]bool, len(plans))
+	for i, p := range plans {
+		imported[i] = make(map[time.Time]bool)
+		for _, session := range p.Sessions {
+			imported[i][dates.Of(session.Date)] = true
+		}
+	}
+
+	previews := make([]PlanPreview, len(plans))
+	for i, p := range plans {
+		others := make(map[time.Time][]string)
+		for j, other := range plans {
+			if j == i {
+				continue
+			}
+			for day := range imported[j] {
+				if imported[i][day] {
+					others[day] = append(others[day], strings.TrimSpace(other.Name))
+				}
+			}
+		}
+		preview, err := s.previewPlan(ctx, p, today, others)
+		if err != nil {
+			return nil, err
+		}
+		previews[i] = preview
+	}
+	return previews, nil
+}
+
+// previewPlan previews a single plan of an import, with others as the names
+// of the import's other plans that have sessions on a day.
+func (s *Service) previewPlan(ctx context.Context, p NewPlan, today time.Time, others map[time.Time][]string) (PlanPreview, error) {
+	if err := s.ValidatePlan(ctx, p); err != nil {
+		return PlanPreview{}, err
+	}
+	workoutType, err := s.store.WorkoutTypes().Get(ctx, p.WorkoutTypeID)
+	if err != nil {
+		return PlanPreview{}, err
+	}
+	preview := PlanPreview{WorkoutType: workoutType.Name}
+	if len(p.Sessions) == 0 {
+		return preview, nil
+	}
+
+	perDay := make(map[time.Time]int)
+	span := storage.DateRange{From: dates.Of(p.Sessions[0].Date), To: dates.Of(p.Sessions[0].Date)}
+	for _, session := range p.Sessions {
+		day := dates.Of(session.Date)
+		perDay[day]++
+		if day.Before(span.From) {
+			span.From = day
+		}
+		if day.After(span.To) {
+			span.To = day
+		}
+	}
+	span.To = span.To.AddDate(0, 0, 1)
+
+	existing, err := s.store.Sessions().InRanges(ctx, span)
+	if err != nil {
+		return PlanPreview{}, err
+	}
+	otherPlans := make(map[time.Time][]string)
+	for _, e := range existing {
+		day := dates.Of(e.Date)
+		if !slices.Contains(otherPlans[day], e.PlanName) {
+			otherPlans[day] = append(otherPlans[day], e.PlanName)
+		}
+	}
+
+	for i, session := range p.Sessions {
+		day := dates.Of(session.Date)
+		ps := PreviewSession{
+			NewSession: session,
+			Number:     i + 1,
+			Planned:    plannedDuration(session.Duration, session.Description),
+		}
+		warn := func(msg string, args ...interface{}) {
+			ps.Warnings = append(ps.Warnings, ImportWarning{Message: msg, Args: args})
+		}
+
+		if day.Before(today) {
+			warn("Date is in the past")
+		}
+		if n := perDay[day]; n > 1 {
+			warn("%d sessions of this import on the same day", n)
+		}
+		names := otherPlans[day]
+		sort.Strings(names)
+		for _, name := range names {
+			warn("Plan %q already has a session on this day", name)
+		}
+		for _, name := range others[day] {
+			warn("Plan %q of this import also has a session on this day", name)
+		}
+		for _, field := range typeFields[workoutType.Name] {
+			if field.value(session) == "" {
+				warn("No %s given, which %s sessions should have", field.name, workoutType.Name)
+			}
+		}
+
+		if len(ps.Warnings) > 0 {
+			preview.Flagged++
+		}
+		preview.Sessions = append(preview.Sessions, ps)
+	}
+	return preview, nil
+}
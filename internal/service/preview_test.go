@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestPreviewPlans(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	cycling := workoutTypeID(t, svc, "cycling")
+	core := workoutTypeID(t, svc, "core")
+	if _, err := svc.CreatePlan(ctx, NewPlan{
+		Name:          "Stored",
+		WorkoutTypeID: core,
+		Sessions:      []NewSession{{Description: "Plank", Date: mustDate(t, "2025-03-05")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	plans := []NewPlan{{
+		Name:          "Base",
+		WorkoutTypeID: cycling,
+		Sessions: []NewSession{
+			{Description: "GA1", Date: mustDate(t, "2025-03-03"), HFMax: "140"},
+			{Description: "GA2", Date: mustDate(t, "2025-03-04")},
+		},
+	}, {
+		Name:          "Core",
+		WorkoutTypeID: core,
+		Sessions: []NewSession{
+			{Description: "Crunches", Date: mustDate(t, "2025-03-04")},
+			{Description: "Plank", Date: mustDate(t, "2025-03-05")},
+		},
+	}}
+	previews, err := svc.PreviewPlans(ctx, plans, mustDate(t, "2025-03-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"", `Plan "Core" of this import also has a session on this day; No hfmax given, which cycling sessions should have`},
+		{`Plan "Base" of this import also has a session on this day`, `Plan "Stored" already has a session on this day`},
+	}
+	for i, preview := range previews {
+		for j, session := range preview.Sessions {
+			got := ""
+			for k, w := range session.Warnings {
+				if k > 0 {
+					got += "; "
+				}
+				got += fmt.Sprintf(w.Message, w.Args...)
+			}
+			if got != want[i][j] {
+				t.Errorf("plan %d session %d: got warnings %q, want %q", i+1, j+1, got, want[i][j])
+			}
+		}
+	}
+	if previews[0].Flagged != 1 || previews[1].Flagged != 2 {
+		t.Errorf("flagged %d and %d sessions, want 1 and 2", previews[0].Flagged, previews[1].Flagged)
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"training-tracker/internal/models"
+)
+
+// JSONSchema is the part of JSON Schema (draft-07) needed to describe the
+// import format. Editors use it to complete and check plans while they are
+// written; ParsePlans checks documents against the very same schema.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 schemaTypes            `json:"type,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Definitions          map[string]*JSONSchema `json:"definitions,omitempty"`
+	AllOf                []*JSONSchema          `json:"allOf,omitempty"`
+	If                   *JSONSchema            `json:"if,omitempty"`
+	Then                 *JSONSchema            `json:"then,omitempty"`
+	Not                  *JSONSchema            `json:"not,omitempty"`
+
+	// Problems reported for values of the property, with the number of the
+	// session and the line
+	invalid string // Does not match; gets the value as well
+	missing string // Required, but left out or empty
+	// The workout types a property is for, all if empty
+	workoutTypes []string
+	pattern      *regexp.Regexp
+	// Index of the property's field in its import format type
+	index int
+}
+
+// schemaTypes are the JSON types a value may have, written as a single
+// string where there is only one.
+type schemaTypes []string
+
+func (t schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// textTypes are the types of text fields. Like any scalar, a number or a
+// boolean is read as its text, and null as no text, which is left out for
+// required fields.
+var textTypes = schemaTypes{"string", "number", "boolean", "null"}
+
+// propertyRule is what the fields of the import format types do not tell
+// about a property of the schema.
+type propertyRule struct {
+	description string
+	// Types other than those of the field's Go type
+	types        schemaTypes
+	pattern      string
+	minimum      *int
+	invalid      string
+	missing      string
+	workoutTypes []string
+}
+
+var noLess = 0
+
+// planRules describe the fields of PlanYAML.
+var planRules = map[string]propertyRule{
+	"name":         {description: "Name of the plan; may be given on import instead"},
+	"workout_type": {description: "Workout type of the sessions; may be chosen on import instead", types: schemaTypes{"string"}},
+	"description":  {description: "What the plan is about"},
+	"source":       {description: "Where the plan comes from, such as a book or a website"},
+	"author":       {description: "Who wrote the plan"},
+	"sessions":     {description: "Sessions of the plan"},
+}
+
+// sessionRules describe the fields of SessionYAML.
+var sessionRules = map[string]propertyRule{
+	"key":         {description: "Identifies the session when the plan is synced, so that it can be moved without losing its completion"},
+	"order":       {description: "Position of the session in the plan"},
+	"description": {description: "What to do"},
+	"date": {
+		description: "Day of the session as YYYY-MM-DD, or a timestamp that gives its start time as well",
+		pattern:     `^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt ][0-9].*)?$`,
+		invalid:     "Session %d, line %d: invalid date %q, expected YYYY-MM-DD",
+		missing:     "Session %d, line %d: date is required",
+	},
+	"time": {
+		description: "Start time as HH:MM",
+		types:       schemaTypes{"string", "null"},
+		pattern:     `^([01]?[0-9]|2[0-3]):[0-5][0-9]$`,
+		invalid:     "Session %d, line %d: invalid time %q, expected HH:MM",
+	},
+	"duration": {
+		description: "Planned minutes",
+		minimum:     &noLess,
+		invalid:     "Session %d, line %d: invalid duration %q, expected a number of minutes",
+	},
+	"hfmax": {
+		description:  "Maximum heart rate, such as 150 or 140-150",
+		workoutTypes: []string{"cycling"},
+	},
+}
+
+// importSchema is the schema of the import format without the workout types,
+// which only the database knows.
+var importSchema = buildImportSchema()
+
+func buildImportSchema() *JSONSchema {
+	session := objectSchema(reflect.TypeOf(SessionYAML{}), sessionRules)
+	session.Title = "Session"
+
+	root := objectSchema(reflect.TypeOf(PlanYAML{}), planRules)
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.Title = "Training plan"
+	root.Description = "A plan in the YAML import format of the training tracker"
+	root.Definitions = map[string]*JSONSchema{"session": session}
+
+	// Fields for some workout types only are left out of plans of the others
+	names := make([]string, 0, len(session.Properties))
+	for name := range session.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		prop := session.Properties[name]
+		if len(prop.workoutTypes) == 0 {
+			continue
+		}
+		root.AllOf = append(root.AllOf, &JSONSchema{
+			If: &JSONSchema{
+				Properties: map[string]*JSONSchema{"workout_type": {Not: &JSONSchema{Enum: prop.workoutTypes}}},
+				Required:   []string{"workout_type"},
+			},
+			Then: &JSONSchema{
+				Properties: map[string]*JSONSchema{"sessions": {Items: &JSONSchema{
+					Properties: map[string]*JSONSchema{name: {Not: &JSONSchema{}}},
+				}}},
+			},
+		})
+	}
+	return root
+}
+
+// objectSchema describes the fields of one of the import format types, as
+// named by their yaml tags. Every field needs a rule and every rule a field,
+// so that the schema cannot fall behind the type.
+func objectSchema(t reflect.Type, rules map[string]propertyRule) *JSONSchema {
+	closed := false
+	schema := &JSONSchema{
+		Type:                 schemaTypes{"object"},
+		Properties:           make(map[string]*JSONSchema),
+		AdditionalProperties: &closed,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		rule, ok := rules[name]
+		if !ok {
+			panic(fmt.Sprintf("service: no schema rule for field %q of %s", name, t.Name()))
+		}
+
+		prop := &JSONSchema{
+			Description:  rule.description,
+			Pattern:      rule.pattern,
+			Minimum:      rule.minimum,
+			invalid:      rule.invalid,
+			missing:      rule.missing,
+			workoutTypes: rule.workoutTypes,
+			index:        i,
+		}
+		if rule.pattern != "" {
+			prop.pattern = regexp.MustCompile(rule.pattern)
+		}
+		switch {
+		case rule.types != nil:
+			prop.Type = rule.types
+		case field.Type == reflect.TypeOf(time.Time{}):
+			prop.Type = schemaTypes{"string"}
+		case field.Type.Kind() == reflect.String && rule.missing != "":
+			prop.Type = textTypes[:len(textTypes)-1]
+		case field.Type.Kind() == reflect.String:
+			prop.Type = textTypes
+		case field.Type.Kind() == reflect.Int, field.Type == reflect.TypeOf((*int)(nil)):
+			prop.Type = schemaTypes{"integer", "null"}
+		case field.Type == reflect.TypeOf([]SessionYAML{}):
+			prop.Type = schemaTypes{"array", "null"}
+			prop.Items = &JSONSchema{Ref: "#/definitions/session"}
+		default:
+			panic(fmt.Sprintf("service: no schema type for field %q of %s", name, t.Name()))
+		}
+		if rule.missing != "" {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	for name := range rules {
+		if _, ok := schema.Properties[name]; !ok {
+			panic(fmt.Sprintf("service: schema rule for unknown field %q of %s", name, t.Name()))
+		}
+	}
+	return schema
+}
+
+// PlanSchema returns the JSON Schema of the import format, with types as the
+// workout types a plan may name.
+func PlanSchema(types []models.WorkoutType) *JSONSchema {
+	schema := *importSchema
+	schema.Properties = make(map[string]*JSONSchema, len(importSchema.Properties))
+	for name, prop := range importSchema.Properties {
+		schema.Properties[name] = prop
+	}
+
+	workoutType := *importSchema.Properties["workout_type"]
+	for _, wt := range types {
+		workoutType.Enum = append(workoutType.Enum, wt.Name)
+	}
+	schema.Properties["workout_type"] = &workoutType
+	return &schema
+}
+
+// nodeType returns the JSON type of a YAML value.
+func nodeType(node *yaml.Node) string {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.MappingNode:
+		return "object"
+	}
+	switch node.Tag {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// allows tells whether a value of the JSON type t is valid for the property.
+func (s *JSONSchema) allows(t string) bool {
+	return slices.Contains(s.Type, t) || t == "integer" && slices.Contains(s.Type, "number")
+}
+
+// matches tells whether a value of the property, whose type it allows,
+// matches its pattern and minimum. Like in JSON Schema, patterns only apply
+// to strings.
+func (s *JSONSchema) matches(node *yaml.Node) bool {
+	if s.pattern != nil && nodeType(node) == "string" && !s.pattern.MatchString(node.Value) {
+		return false
+	}
+	if s.Minimum != nil && nodeType(node) == "integer" {
+		var n int
+		if node.Decode(&n) != nil || n < *s.Minimum {
+			return false
+		}
+	}
+	return true
+}
+
+// forType tells whether the property is one of the workout type.
+func (s *JSONSchema) forType(workoutType string) bool {
+	return len(s.workoutTypes) == 0 || slices.Contains(s.workoutTypes, workoutType)
+}
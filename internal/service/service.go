@@ -0,0 +1,77 @@
+// Package service implements the domain rules of the training tracker on top
+// of the storage repositories. The web handlers, the JSON API and command
+// line tools all go through it.
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"training-tracker/internal/models"
+	"training-tracker/internal/storage"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = storage.ErrNotFound
+
+// InputError reports invalid input. Message is written in English and may be
+// a format for Args, so that callers can translate it.
+type InputError struct {
+	// Name of the offending field, if any
+	Field   string
+	Message string
+	Args    []interface{}
+}
+
+func (e *InputError) Error() string {
+	if len(e.Args) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf(e.Message, e.Args...)
+}
+
+func inputError(field, msg string, args ...interface{}) *InputError {
+	return &InputError{Field: field, Message: msg, Args: args}
+}
+
+// FieldErrors reports all the problems found in a form or an import at once,
+// so that they can be fixed in one go.
+type FieldErrors []*InputError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap lets errors.As find the first of the problems.
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// err returns nil rather than an empty list, which would not be a nil error.
+func (e FieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+type Service struct {
+	store storage.Store
+}
+
+func New(store storage.Store) *Service {
+	return &Service{store: store}
+}
+
+func (s *Service) WorkoutTypes(ctx context.Context) ([]models.WorkoutType, error) {
+	return s.store.WorkoutTypes().List(ctx)
+}
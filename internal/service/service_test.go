@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/storage"
+)
+
+// newTestService returns a service on a fresh in-memory SQLite database
+// with the default workout types.
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	return New(storage.NewSQLite(db))
+}
+
+// workoutTypeID returns the ID of the workout type with the given name.
+func workoutTypeID(t *testing.T, svc *Service, name string) int64 {
+	t.Helper()
+	types, err := svc.WorkoutTypes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wt := range types {
+		if wt.Name == name {
+			return wt.ID
+		}
+	}
+	t.Fatalf("no workout type %q", name)
+	return 0
+}
+
+func mustDate(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := dates.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
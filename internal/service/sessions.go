@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"training-tracker/internal/durations"
+	"training-tracker/internal/models"
+	"training-tracker/internal/storage"
+)
+
+// Session returns a single session.
+func (s *Service) Session(ctx context.Context, id int64) (models.TrainingSession, error) {
+	return s.store.Sessions().Get(ctx, id)
+}
+
+// CompleteSession marks a session as done and records how it went. A
+// session completed again replaces the earlier record.
+func (s *Service) CompleteSession(ctx context.Context, c models.Completion) error {
+	if c.Duration != nil && *c.Duration <= 0 {
+		return inputError("duration_minutes", "Invalid duration")
+	}
+	if c.AvgHR != nil && (*c.AvgHR < 30 || *c.AvgHR > 240) {
+		return inputError("avg_hr", "Invalid heart rate")
+	}
+	if c.RPE != nil && (*c.RPE < 1 || *c.RPE > 10) {
+		return inputError("rpe", "Invalid RPE")
+	}
+	if c.DistanceKm != nil && *c.DistanceKm < 0 {
+		return inputError("distance_km", "Invalid distance")
+	}
+	if c.CompletedAt.IsZero() {
+		c.CompletedAt = time.Now()
+	}
+
+	return s.store.InTx(ctx, func(tx storage.Store) error {
+		before, err := sessionSnapshot(ctx, tx, c.SessionID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Sessions().SetCompleted(ctx, c.SessionID, true); err != nil {
+			return err
+		}
+		if err := tx.Completions().Save(ctx, c); err != nil {
+			return err
+		}
+		after, err := sessionSnapshot(ctx, tx, c.SessionID)
+		if err != nil {
+			return err
+		}
+		return record(ctx, tx, before.Session.PlanID, &c.SessionID, ActionCompleteSession, before, after)
+	})
+}
+
+// Schedule returns the sessions on any day of the given ranges, ordered by
+// date and start time, sessions without one first.
+func (s *Service) Schedule(ctx context.Context, ranges ...storage.DateRange) ([]storage.ScheduledSession, error) {
+	return s.store.Sessions().InRanges(ctx, ranges...)
+}
+
+type DurationCandidate struct {
+	SessionID   int64
+	PlanName    string
+	Description string
+	Date        time.Time
+	Minutes     int
+	Parsed      bool
+}
+
+// DurationCandidates parses the description of every session that has no
+// planned duration yet.
+func (s *Service) DurationCandidates(ctx context.Context) ([]DurationCandidate, error) {
+	sessions, err := s.store.Sessions().WithoutDuration(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []DurationCandidate
+	for _, session := range sessions {
+		c := DurationCandidate{
+			SessionID:   session.ID,
+			PlanName:    session.PlanName,
+			Description: session.Description,
+			Date:        session.Date,
+		}
+		c.Minutes, c.Parsed = durations.Parse(c.Description)
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// BackfillDurations stores the durations parsed from the descriptions of
+// the given candidates. Sessions that got a duration in the meantime keep it.
+func (s *Service) BackfillDurations(ctx context.Context, candidates []DurationCandidate) error {
+	return s.store.InTx(ctx, func(tx storage.Store) error {
+		for _, c := range candidates {
+			if !c.Parsed {
+				continue
+			}
+			before, err := sessionSnapshot(ctx, tx, c.SessionID)
+			if err == storage.ErrNotFound {
+				// Deleted in the meantime
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if before.Session.Duration != nil {
+				continue
+			}
+			if err := tx.Sessions().SetDurationIfMissing(ctx, c.SessionID, c.Minutes); err != nil {
+				return err
+			}
+			after, err := sessionSnapshot(ctx, tx, c.SessionID)
+			if err != nil {
+				return err
+			}
+			if err := record(ctx, tx, before.Session.PlanID, &c.SessionID, ActionSetDuration, before, after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"training-tracker/internal/models"
+)
+
+func TestCompleteSession(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	plan, err := svc.CreatePlan(ctx, NewPlan{
+		Name:          "Base",
+		WorkoutTypeID: workoutTypeID(t, svc, "cycling"),
+		Sessions:      []NewSession{{Description: "GA1 60min", Date: mustDate(t, "2025-03-03")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := svc.Plan(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := detail.Sessions[0].ID
+
+	hr, rpe, bad := 140, 6, 11
+	tests := []struct {
+		name  string
+		c     models.Completion
+		field string
+		err   error
+	}{
+		{name: "unknown session", c: models.Completion{SessionID: sessionID + 100}, err: ErrNotFound},
+		{name: "invalid RPE", c: models.Completion{SessionID: sessionID, RPE: &bad}, field: "rpe"},
+		{name: "invalid heart rate", c: models.Completion{SessionID: sessionID, AvgHR: &bad}, field: "avg_hr"},
+		{name: "details", c: models.Completion{SessionID: sessionID, AvgHR: &hr, RPE: &rpe}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.CompleteSession(ctx, tt.c)
+			var input *InputError
+			switch {
+			case tt.field != "":
+				if !errors.As(err, &input) || input.Field != tt.field {
+					t.Fatalf("got %v, want an input error for %s", err, tt.field)
+				}
+			case err != tt.err:
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// The unknown session got no completion, the valid details were stored
+	if _, err := svc.store.Completions().Get(ctx, sessionID+100); err != ErrNotFound {
+		t.Errorf("completion of unknown session: got %v, want %v", err, ErrNotFound)
+	}
+	stored, err := svc.store.Completions().Get(ctx, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AvgHR == nil || *stored.AvgHR != hr || stored.RPE == nil || *stored.RPE != rpe {
+		t.Errorf("stored completion %+v, want heart rate %d and RPE %d", stored, hr, rpe)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"training-tracker/internal/locale"
+	"training-tracker/internal/storage"
+)
+
+// Settings holds the athlete's personal values used by the analytics and
+// the calendar.
+type Settings struct {
+	RestingHR int
+	MaxHR     int
+	// IANA time zone name; empty means the server's zone
+	TimeZone string
+	// Controls the language, day and month names and date formats; empty
+	// means the browser's language
+	LocaleCode string
+	// "monday", "sunday" or "saturday"; empty means the locale's default
+	WeekStart string
+}
+
+// weekStartDays are the days a week may start on.
+var weekStartDays = map[string]time.Weekday{
+	"monday":   time.Monday,
+	"sunday":   time.Sunday,
+	"saturday": time.Saturday,
+}
+
+// Locale returns the user's locale with the configured week start applied.
+// Without a chosen locale the best match for the Accept-Language header is
+// used.
+func (s Settings) Locale(acceptLanguage string) locale.Locale {
+	code := s.LocaleCode
+	if code == "" {
+		code = locale.Match(acceptLanguage)
+	}
+	l := locale.Get(code)
+	if day, ok := weekStartDays[s.WeekStart]; ok {
+		l = l.WithWeekStart(day)
+	}
+	return l
+}
+
+// Location returns the user's time zone.
+func (s Settings) Location() *time.Location {
+	if s.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+var DefaultSettings = Settings{
+	RestingHR: 60,
+	MaxHR:     190,
+}
+
+// Settings reads all stored settings, falling back to the defaults for
+// anything that was never saved.
+func (s *Service) Settings(ctx context.Context) (Settings, error) {
+	settings := DefaultSettings
+
+	values, err := s.store.Settings().All(ctx)
+	if err != nil {
+		return settings, err
+	}
+
+	if v, err := strconv.Atoi(values["resting_hr"]); err == nil {
+		settings.RestingHR = v
+	}
+	if v, err := strconv.Atoi(values["max_hr"]); err == nil {
+		settings.MaxHR = v
+	}
+	settings.TimeZone = values["timezone"]
+	settings.LocaleCode = values["locale"]
+	settings.WeekStart = values["week_start"]
+
+	return settings, nil
+}
+
+// SaveSettings validates and stores all settings.
+func (s *Service) SaveSettings(ctx context.Context, settings Settings) error {
+	if settings.RestingHR <= 0 {
+		return inputError("resting_hr", "Invalid resting heart rate")
+	}
+	if settings.MaxHR <= settings.RestingHR {
+		return inputError("max_hr", "Invalid maximum heart rate")
+	}
+	if settings.TimeZone != "" {
+		if _, err := time.LoadLocation(settings.TimeZone); err != nil {
+			return inputError("timezone", "Unknown time zone")
+		}
+	}
+	if settings.LocaleCode != "" && !locale.Exists(settings.LocaleCode) {
+		return inputError("locale", "Unknown locale")
+	}
+	if _, ok := weekStartDays[settings.WeekStart]; settings.WeekStart != "" && !ok {
+		return inputError("week_start", "Invalid week start")
+	}
+
+	return s.store.InTx(ctx, func(tx storage.Store) error {
+		return tx.Settings().Save(ctx, map[string]string{
+			"resting_hr": strconv.Itoa(settings.RestingHR),
+			"max_hr":     strconv.Itoa(settings.MaxHR),
+			"timezone":   settings.TimeZone,
+			"locale":     settings.LocaleCode,
+			"week_start": settings.WeekStart,
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"training-tracker/internal/storage"
+)
+
+type PlanStreak struct {
+	PlanID      int64  `json:"plan_id"`
+	PlanName    string `json:"plan_name"`
+	WorkoutType string `json:"workout_type"`
+	Current     int    `json:"current"`
+	Longest     int    `json:"longest"`
+	Completed   int    `json:"completed"`
+	Total       int    `json:"total"`
+	// Sessions of today, completed ones among them, and open sessions of
+	// days before
+	DueToday       int `json:"due_today"`
+	CompletedToday int `json:"completed_today"`
+	Overdue        int `json:"overdue"`
+}
+
+// Streaks computes streaks of consecutively completed sessions per plan,
+// along with how many sessions are completed, due today and overdue. Only
+// sessions due up to today, a calendar date, count; an open session today
+// does not break the current streak since it can still be completed.
+func (s *Service) Streaks(ctx context.Context, today time.Time) ([]PlanStreak, error) {
+	types, err := s.store.WorkoutTypes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	typeNames := make(map[int64]string, len(types))
+	for _, wt := range types {
+		typeNames[wt.ID] = wt.Name
+	}
+
+	list, err := s.store.Plans().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]PlanStreak, 0, len(list))
+	for _, plan := range list {
+		plans = append(plans, PlanStreak{
+			PlanID:      plan.ID,
+			PlanName:    plan.Name,
+			WorkoutType: typeNames[plan.WorkoutTypeID],
+		})
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].PlanID < plans[j].PlanID })
+
+	byID := make(map[int64]*PlanStreak, len(plans))
+	for i := range plans {
+		byID[plans[i].PlanID] = &plans[i]
+	}
+
+	err = s.store.Stats().DueSessions(ctx, today, func(session storage.DueSession) error {
+		current, ok := byID[session.PlanID]
+		if !ok {
+			return nil
+		}
+
+		current.Total++
+		switch {
+		case session.DueToday:
+			current.DueToday++
+			if session.Completed {
+				current.CompletedToday++
+			}
+		case !session.Completed:
+			current.Overdue++
+		}
+		switch {
+		case session.Completed:
+			current.Completed++
+			current.Current++
+			if current.Current > current.Longest {
+				current.Longest = current.Current
+			}
+		case !session.DueToday:
+			current.Current = 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Plans without any session due yet have no streak
+	streaks := []PlanStreak{}
+	for _, plan := range plans {
+		if plan.Total > 0 {
+			streaks = append(streaks, plan)
+		}
+	}
+	return streaks, nil
+}
+
+// CompletedPerDay sums up the completed sessions per day.
+func (s *Service) CompletedPerDay(ctx context.Context, r storage.DateRange, filter storage.StatsFilter) ([]storage.DayTotal, error) {
+	return s.store.Stats().CompletedPerDay(ctx, r, filter)
+}
+
+// LoadSessions returns every session with what is known about its load,
+// ordered by date.
+func (s *Service) LoadSessions(ctx context.Context, filter storage.StatsFilter) ([]storage.LoadSession, error) {
+	return s.store.Stats().LoadSessions(ctx, filter)
+}
+
+// VolumePerDay sums up planned and completed volume per day and plan.
+func (s *Service) VolumePerDay(ctx context.Context, r storage.DateRange, filter storage.StatsFilter) ([]storage.VolumeDay, error) {
+	return s.store.Stats().VolumePerDay(ctx, r, filter)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"training-tracker/internal/models"
+)
+
+func TestStreaks(t *testing.T) {
+	type session struct {
+		date string
+		done bool
+	}
+	tests := []struct {
+		name     string
+		sessions []session
+		want     PlanStreak
+	}{
+		{
+			name:     "all completed",
+			sessions: []session{{"2025-03-07", true}, {"2025-03-08", true}, {"2025-03-09", true}},
+			want:     PlanStreak{Current: 3, Longest: 3, Completed: 3, Total: 3},
+		},
+		{
+			name:     "reset by a missed session",
+			sessions: []session{{"2025-03-05", true}, {"2025-03-06", true}, {"2025-03-07", false}, {"2025-03-08", true}},
+			want:     PlanStreak{Current: 1, Longest: 2, Completed: 3, Total: 4, Overdue: 1},
+		},
+		{
+			name:     "missed yesterday",
+			sessions: []session{{"2025-03-08", true}, {"2025-03-09", false}, {"2025-03-10", false}},
+			want:     PlanStreak{Current: 0, Longest: 1, Completed: 1, Total: 3, DueToday: 1, Overdue: 1},
+		},
+		{
+			name:     "open today",
+			sessions: []session{{"2025-03-08", true}, {"2025-03-09", true}, {"2025-03-10", false}},
+			want:     PlanStreak{Current: 2, Longest: 2, Completed: 2, Total: 3, DueToday: 1},
+		},
+		{
+			name:     "completed today",
+			sessions: []session{{"2025-03-09", true}, {"2025-03-10", true}},
+			want:     PlanStreak{Current: 2, Longest: 2, Completed: 2, Total: 2, DueToday: 1, CompletedToday: 1},
+		},
+		{
+			name:     "later sessions do not count",
+			sessions: []session{{"2025-03-09", true}, {"2025-03-11", false}, {"2025-03-12", true}},
+			want:     PlanStreak{Current: 1, Longest: 1, Completed: 1, Total: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService(t)
+			p := NewPlan{Name: "Base", WorkoutTypeID: workoutTypeID(t, svc, "mobility")}
+			for _, s := range tt.sessions {
+				p.Sessions = append(p.Sessions, NewSession{Description: "Stretch", Date: mustDate(t, s.date)})
+			}
+			plan, err := svc.CreatePlan(ctx, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			detail, err := svc.Plan(ctx, plan.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.sessions {
+				if !s.done {
+					continue
+				}
+				if err := svc.CompleteSession(ctx, models.Completion{SessionID: detail.Sessions[i].ID}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			streaks, err := svc.Streaks(ctx, mustDate(t, "2025-03-10"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			want.PlanID, want.PlanName, want.WorkoutType = plan.ID, "Base", "mobility"
+			if len(streaks) != 1 || streaks[0] != want {
+				t.Errorf("got %+v, want %+v", streaks, want)
+			}
+		})
+	}
+}
+
+// Plans without sessions due yet are left out.
+func TestStreaksWithoutDueSessions(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	_, err := svc.CreatePlan(ctx, NewPlan{
+		Name:          "Next month",
+		WorkoutTypeID: workoutTypeID(t, svc, "mobility"),
+		Sessions:      []NewSession{{Description: "Stretch", Date: mustDate(t, "2025-04-01")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	streaks, err := svc.Streaks(ctx, mustDate(t, "2025-03-10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streaks) != 0 {
+		t.Errorf("got %+v, want no streaks", streaks)
+	}
+}
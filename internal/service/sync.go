@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+	"training-tracker/internal/storage"
+)
+
+// SyncAction is what syncing a plan with its YAML does to a session.
+type SyncAction string
+
+const (
+	SyncAdd    SyncAction = "add"
+	SyncUpdate SyncAction = "update"
+	// Sessions dropped from the YAML are deleted unless they were completed
+	SyncDelete SyncAction = "delete"
+	// Dropped sessions that were completed are archived, which keeps their
+	// completion
+	SyncArchive SyncAction = "archive"
+	// Archived sessions come back when the YAML has their key again
+	SyncRestore SyncAction = "restore"
+)
+
+// SessionChange is a change that syncing makes to a session of the plan.
+type SessionChange struct {
+	Action SyncAction `json:"action"`
+	// Position of the session in the YAML, from 1; zero for dropped ones
+	Number int `json:"number,omitempty"`
+	// Session as it is stored, nil for added ones
+	Before *models.TrainingSession `json:"before,omitempty"`
+	// Session as it will be stored, nil for dropped ones
+	After *models.TrainingSession `json:"after,omitempty"`
+	// Fields of the import format that an update changes
+	Fields []string `json:"fields,omitempty"`
+}
+
+// PlanSync shows what syncing a plan with an updated YAML changes.
+type PlanSync struct {
+	Before      models.TrainingPlan `json:"before"`
+	After       models.TrainingPlan `json:"after"`
+	WorkoutType string              `json:"workout_type"`
+	// Fields of the plan that change
+	Fields  []string        `json:"fields,omitempty"`
+	Changes []SessionChange `json:"changes"`
+	// Sessions that stay as they are
+	Unchanged int `json:"unchanged"`
+}
+
+// Count returns the number of sessions the sync does action to.
+func (p PlanSync) Count(action SyncAction) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// PreviewSync compares a plan with an updated version of its YAML and
+// returns what SyncPlan would change. Nothing is stored.
+func (s *Service) PreviewSync(ctx context.Context, planID int64, p NewPlan) (PlanSync, error) {
+	return planSync(ctx, s.store, planID, p)
+}
+
+// SyncPlan brings a plan in line with an updated version of its YAML.
+//
+// Sessions are matched by their key where the YAML gives one, then by date
+// and description, and the rest by their order or else their position.
+// Matched sessions take the description, date, start time, planned
+// duration and details of the YAML; those only in the YAML are added.
+// Sessions the YAML dropped are deleted, or archived if they were
+// completed, and restored when the YAML has their key again. Completions
+// are never touched. The name, workout type and metadata of p are optional;
+// a workout type given must be that of the plan.
+func (s *Service) SyncPlan(ctx context.Context, planID int64, p NewPlan) (PlanSync, error) {
+	var sync PlanSync
+	err := s.store.InTx(ctx, func(tx storage.Store) error {
+		var err error
+		sync, err = planSync(ctx, tx, planID, p)
+		if err != nil {
+			return err
+		}
+		return applyRecorded(ctx, tx, ActionSyncPlan, sync)
+	})
+	return sync, err
+}
+
+// planSync works out the changes of syncing a plan with p.
+func planSync(ctx context.Context, store storage.Store, planID int64, p NewPlan) (PlanSync, error) {
+	plan, err := store.Plans().Get(ctx, planID)
+	if err != nil {
+		return PlanSync{}, err
+	}
+	workoutType, err := store.WorkoutTypes().Get(ctx, plan.WorkoutTypeID)
+	if err != nil {
+		return PlanSync{}, err
+	}
+	if p.WorkoutTypeID != 0 && p.WorkoutTypeID != plan.WorkoutTypeID {
+		other, err := store.WorkoutTypes().Get(ctx, p.WorkoutTypeID)
+		if err == storage.ErrNotFound {
+			return PlanSync{}, inputError("workout_type_id", "Unknown workout type")
+		}
+		if err != nil {
+			return PlanSync{}, err
+		}
+		return PlanSync{}, inputError("workout_type_id", "The YAML is for %s sessions, but the plan is for %s sessions", other.Name, workoutType.Name)
+	}
+	p.WorkoutTypeID = plan.WorkoutTypeID
+	if strings.TrimSpace(p.Name) == "" {
+		p.Name = plan.Name
+	}
+	if err := validatePlan(ctx, store, p); err != nil {
+		return PlanSync{}, err
+	}
+
+	sync := PlanSync{Before: plan, After: plan, WorkoutType: workoutType.Name, Changes: []SessionChange{}}
+	metadata := []struct {
+		field string
+		value string
+		dest  *string
+	}{
+		{"name", p.Name, &sync.After.Name},
+		{"description", p.Description, &sync.After.Description},
+		{"source", p.Source, &sync.After.Source},
+		{"author", p.Author, &sync.After.Author},
+	}
+	for _, m := range metadata {
+		// What the YAML leaves out is kept
+		value := strings.TrimSpace(m.value)
+		if value != "" && value != *m.dest {
+			*m.dest = value
+			sync.Fields = append(sync.Fields, m.field)
+		}
+	}
+
+	current, err := store.Sessions().ListByPlan(ctx, planID)
+	if err != nil {
+		return PlanSync{}, err
+	}
+	matches := matchSessions(current, p.Sessions)
+	matched := make([]bool, len(current))
+	for i, session := range p.Sessions {
+		j := matches[i]
+		if j < 0 {
+			added := sessionModel(planID, session)
+			sync.Changes = append(sync.Changes, SessionChange{Action: SyncAdd, Number: i + 1, After: &added})
+			continue
+		}
+		matched[j] = true
+
+		updated, fields := syncedSession(current[j], session, workoutType.Name)
+		action := SyncUpdate
+		if current[j].Archived {
+			updated.Archived = false
+			action = SyncRestore
+		} else if len(fields) == 0 {
+			sync.Unchanged++
+			continue
+		}
+		sync.Changes = append(sync.Changes, SessionChange{
+			Action: action,
+			Number: i + 1,
+			Before: &current[j],
+			After:  &updated,
+			Fields: fields,
+		})
+	}
+	for j := range current {
+		if matched[j] || current[j].Archived {
+			continue
+		}
+		action := SyncDelete
+		if current[j].Completed {
+			action = SyncArchive
+		}
+		sync.Changes = append(sync.Changes, SessionChange{Action: action, Before: &current[j]})
+	}
+	return sync, nil
+}
+
+// matchSessions returns the index of the stored session that each session of
+// the YAML updates, or -1 for sessions to add. Sessions are matched by key
+// first, then by date and description. The rest are matched in order, their
+// position in the YAML standing in for an order it does not give, except
+// stored sessions with a key and archived ones, which the YAML has to name.
+// A completed session is only matched that way if it keeps its date or its
+// description, so that its completion stays with what was done.
+func matchSessions(stored []models.TrainingSession, sessions []NewSession) []int {
+	byKey := make(map[string]int)
+	for j, s := range stored {
+		if s.Key == "" {
+			continue
+		}
+		// An active session wins over an archived one with the same key
+		if k, ok := byKey[s.Key]; ok && !stored[k].Archived {
+			continue
+		}
+		byKey[s.Key] = j
+	}
+
+	matches := make([]int, len(sessions))
+	taken := make([]bool, len(stored))
+	var rest []int
+	for i, s := range sessions {
+		j, ok := byKey[s.Key]
+		if s.Key == "" || !ok {
+			matches[i] = -1
+			rest = append(rest, i)
+			continue
+		}
+		matches[i] = j
+		taken[j] = true
+	}
+
+	type content struct {
+		date        time.Time
+		description string
+	}
+	same := make(map[content][]int)
+	for j, s := range stored {
+		if !taken[j] && s.Key == "" && !s.Archived {
+			c := content{dates.Of(s.Date), s.Description}
+			same[c] = append(same[c], j)
+		}
+	}
+	var ordered []int
+	for _, i := range rest {
+		c := content{dates.Of(sessions[i].Date), sessions[i].Description}
+		if len(same[c]) == 0 {
+			ordered = append(ordered, i)
+			continue
+		}
+		matches[i] = same[c][0]
+		taken[same[c][0]] = true
+		same[c] = same[c][1:]
+	}
+
+	// Stored sessions are listed by order and date already
+	var free []int
+	for j, s := range stored {
+		if !taken[j] && s.Key == "" && !s.Archived {
+			free = append(free, j)
+		}
+	}
+	order := func(i int) int {
+		if o := sessions[i].Order; o != nil {
+			return *o
+		}
+		return i + 1
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return order(ordered[a]) < order(ordered[b])
+	})
+	for n, i := range ordered {
+		if n >= len(free) {
+			break
+		}
+		j := free[n]
+		moved := !dates.Of(stored[j].Date).Equal(dates.Of(sessions[i].Date))
+		if stored[j].Completed && moved && stored[j].Description != sessions[i].Description {
+			continue
+		}
+		matches[i] = j
+	}
+	return matches
+}
+
+// syncedSession returns a stored session updated to what the YAML gives for
+// it, along with the fields that change. Whether it is completed stays as it
+// is. A planned duration is kept if the YAML has none.
+func syncedSession(stored models.TrainingSession, s NewSession, workoutType string) (models.TrainingSession, []string) {
+	target := sessionModel(stored.PlanID, s)
+	updated := stored
+	updated.Key = target.Key
+	if target.SessionOrder != nil {
+		updated.SessionOrder = target.SessionOrder
+	}
+	updated.Date = target.Date
+	updated.StartTime = target.StartTime
+	updated.Description = target.Description
+	if target.Duration != nil {
+		updated.Duration = target.Duration
+	}
+	if workoutType == "cycling" {
+		updated.HFMax = target.HFMax
+	}
+	return updated, changedFields(stored, updated)
+}
+
+// changedFields returns the fields of the import format in which two
+// versions of a session differ.
+func changedFields(a, b models.TrainingSession) []string {
+	var fields []string
+	if a.Key != b.Key {
+		fields = append(fields, "key")
+	}
+	if !sameInt(a.SessionOrder, b.SessionOrder) {
+		fields = append(fields, "order")
+	}
+	if !dates.Of(a.Date).Equal(dates.Of(b.Date)) {
+		fields = append(fields, "date")
+	}
+	if !sameString(a.StartTime, b.StartTime) {
+		fields = append(fields, "time")
+	}
+	if a.Description != b.Description {
+		fields = append(fields, "description")
+	}
+	if !sameInt(a.Duration, b.Duration) {
+		fields = append(fields, "duration")
+	}
+	if a.HFMax != b.HFMax {
+		fields = append(fields, "hfmax")
+	}
+	return fields
+}
+
+func sameInt(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func sameString(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// applySync stores the changes of a sync.
+func applySync(ctx context.Context, tx storage.Store, sync PlanSync) error {
+	if len(sync.Fields) > 0 {
+		if err := tx.Plans().Update(ctx, sync.After); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range sync.Changes {
+		var err error
+		switch c.Action {
+		case SyncAdd:
+			if c.After.SessionOrder == nil {
+				order, err := tx.Sessions().NextOrder(ctx, c.After.PlanID)
+				if err != nil {
+					return err
+				}
+				c.After.SessionOrder = &order
+			}
+			err = tx.Sessions().Create(ctx, c.After, sync.WorkoutType)
+		case SyncUpdate, SyncRestore:
+			err = tx.Sessions().Update(ctx, c.After, sync.WorkoutType)
+		case SyncDelete:
+			err = tx.Sessions().Delete(ctx, c.Before.ID)
+		case SyncArchive:
+			err = tx.Sessions().Archive(ctx, c.Before.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
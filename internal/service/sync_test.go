@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"training-tracker/internal/models"
+)
+
+// completedIDs returns the IDs of the completed sessions of a plan.
+func completedIDs(t *testing.T, svc *Service, planID int64) map[int64]bool {
+	t.Helper()
+	detail, err := svc.Plan(context.Background(), planID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int64]bool)
+	for _, s := range detail.Sessions {
+		if s.Completed {
+			ids[s.ID] = true
+		}
+	}
+	return ids
+}
+
+// A plan without keys or orders, like the shipped ones, keeps its
+// completions when a session is dropped from the top.
+func TestSyncPlanWithoutKeys(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	data, err := os.ReadFile("../../msr300.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ParsePlans(data, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := svc.CreatePlan(ctx, NewPlan{Name: "MSR", WorkoutTypeID: workoutTypeID(t, svc, "cycling"), Sessions: docs[0].Sessions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	detail, err := svc.Plan(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range detail.Sessions[:2] {
+		if err := svc.CompleteSession(ctx, models.Completion{SessionID: s.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := completedIDs(t, svc, plan.ID)
+
+	sync, err := svc.SyncPlan(ctx, plan.ID, NewPlan{Sessions: docs[0].Sessions[1:]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sync.Changes) != 1 || sync.Changes[0].Action != SyncArchive || sync.Changes[0].Before.ID != detail.Sessions[0].ID {
+		t.Errorf("got changes %+v, want the first session archived", sync.Changes)
+	}
+	if sync.Unchanged != len(docs[0].Sessions)-1 {
+		t.Errorf("got %d unchanged sessions, want %d", sync.Unchanged, len(docs[0].Sessions)-1)
+	}
+	after := completedIDs(t, svc, plan.ID)
+	if len(after) != len(before) || !after[detail.Sessions[0].ID] || !after[detail.Sessions[1].ID] {
+		t.Errorf("completed sessions %v after the sync, want %v", after, before)
+	}
+
+	// A fixed typo updates the session in place
+	edited := append([]NewSession(nil), docs[0].Sessions[1:]...)
+	edited[0].Description += "!"
+	sync, err = svc.SyncPlan(ctx, plan.ID, NewPlan{Sessions: edited})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sync.Changes) != 1 || sync.Changes[0].Action != SyncUpdate || sync.Changes[0].Before.ID != detail.Sessions[1].ID {
+		t.Errorf("got changes %+v, want the second session updated", sync.Changes)
+	}
+	if after := completedIDs(t, svc, plan.ID); !after[detail.Sessions[1].ID] {
+		t.Errorf("completed sessions %v after fixing a typo, want %d still completed", after, detail.Sessions[1].ID)
+	}
+}
+
+func TestMatchSessions(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	order := func(n int) *int { return &n }
+	stored := []models.TrainingSession{
+		{ID: 1, SessionOrder: order(1), Description: "A", Date: day(1), Completed: true},
+		{ID: 2, SessionOrder: order(2), Description: "B", Date: day(2)},
+		{ID: 3, SessionOrder: order(3), Description: "C", Date: day(3), Key: "c"},
+		{ID: 4, SessionOrder: order(4), Description: "D", Date: day(4), Archived: true},
+	}
+
+	tests := []struct {
+		name     string
+		sessions []NewSession
+		want     []int
+	}{
+		{
+			name:     "by date and description",
+			sessions: []NewSession{{Description: "B", Date: day(2)}, {Description: "A", Date: day(1)}},
+			want:     []int{1, 0},
+		},
+		{
+			name:     "by key",
+			sessions: []NewSession{{Key: "c", Description: "C moved", Date: day(9)}},
+			want:     []int{2},
+		},
+		{
+			name:     "typos fixed",
+			sessions: []NewSession{{Description: "A changed", Date: day(1)}, {Description: "B changed", Date: day(2)}},
+			want:     []int{0, 1},
+		},
+		{
+			name:     "moved by position",
+			sessions: []NewSession{{Description: "A", Date: day(6)}, {Description: "B", Date: day(5)}},
+			want:     []int{0, 1},
+		},
+		{
+			name:     "by declared order",
+			sessions: []NewSession{{Order: order(1), Description: "A changed", Date: day(1)}, {Order: order(2), Description: "B moved", Date: day(5)}},
+			want:     []int{0, 1},
+		},
+		{
+			name:     "completed sessions stay with what was done",
+			sessions: []NewSession{{Order: order(1), Description: "A moved", Date: day(6)}, {Order: order(2), Description: "B moved", Date: day(5)}},
+			want:     []int{-1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchSessions(stored, tt.sessions)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got matches %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+)
+
+// SessionYAML is a session in the YAML import format. A field added here
+// needs a rule in sessionRules, which describes it in the schema.
+type SessionYAML struct {
+	// Identifies the session when the plan is synced, so that it can be
+	// moved without losing its completion
+	Key         string    `yaml:"key,omitempty"`
+	Order       *int      `yaml:"order,omitempty"`
+	Description string    `yaml:"description"`
+	Date        time.Time `yaml:"date"`
+	Time        string    `yaml:"time,omitempty"`     // Start time, "15:04"
+	Duration    int       `yaml:"duration,omitempty"` // Planned minutes
+	// Type-specific fields
+	HFMax string `yaml:"hfmax,omitempty"` // For cycling
+	// Mobility has no additional fields
+	// Sandbag has no additional fields yet
+}
+
+// SessionsYAML is the import format of plans whose name and workout type
+// are given separately. It is still accepted, as the sessions of a PlanYAML.
+type SessionsYAML struct {
+	Sessions []SessionYAML `yaml:"sessions"`
+}
+
+// PlanYAML is a plan in the import format, which describes itself. A file
+// may hold several of them as separate YAML documents. Its fields are
+// described in the schema by planRules.
+type PlanYAML struct {
+	Name        string        `yaml:"name,omitempty"`
+	WorkoutType string        `yaml:"workout_type,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+	Source      string        `yaml:"source,omitempty"`
+	Author      string        `yaml:"author,omitempty"`
+	Sessions    []SessionYAML `yaml:"sessions"`
+}
+
+// PlanDocument is a plan read from a document in the import format. Fields
+// the document leaves out are empty.
+type PlanDocument struct {
+	Name        string
+	WorkoutType string
+	Description string
+	Source      string
+	Author      string
+	Sessions    []NewSession
+	// Line of the workout type, for reporting an unknown one
+	workoutTypeLine int
+	// Fields the sessions have that only some workout types have
+	typed []typedField
+}
+
+// Plan turns the document into a plan to create, with the workout type
+// looked up among types. The name and workout type of defaults are used
+// where the document gives none.
+func (d PlanDocument) Plan(types []models.WorkoutType, defaults NewPlan) (NewPlan, error) {
+	plan := NewPlan{
+		Name:          d.Name,
+		WorkoutTypeID: defaults.WorkoutTypeID,
+		Description:   d.Description,
+		Source:        d.Source,
+		Author:        d.Author,
+		Sessions:      d.Sessions,
+	}
+	if plan.Name == "" {
+		plan.Name = defaults.Name
+	}
+	if d.WorkoutType != "" {
+		plan.WorkoutTypeID = 0
+		for _, wt := range types {
+			if wt.Name == d.WorkoutType {
+				plan.WorkoutTypeID = wt.ID
+			}
+		}
+		if plan.WorkoutTypeID == 0 {
+			return plan, inputError("yaml_sessions", "Line %d: unknown workout type %q", d.workoutTypeLine, d.WorkoutType)
+		}
+	}
+
+	// Like the schema, which can only tell once the workout type is known
+	var problems FieldErrors
+	for _, wt := range types {
+		if wt.ID != plan.WorkoutTypeID {
+			continue
+		}
+		for _, f := range d.typed {
+			if !f.prop.forType(wt.Name) {
+				problems = append(problems, inputError("yaml_sessions", "Session %d, line %d: %s is only for %s sessions", f.session, f.line, f.name, strings.Join(f.prop.workoutTypes, ", ")))
+			}
+		}
+	}
+	return plan, problems.err()
+}
+
+// maxYAMLErrors is how many problems of an import are reported; a document
+// in the wrong format would otherwise make for one per line.
+const maxYAMLErrors = 20
+
+// ParsePlans reads the plans of all documents in the import format.
+// Timestamps with a time of day are converted into loc. Problems are
+// reported as FieldErrors with the line and the number of the session they
+// are in.
+func ParsePlans(data []byte, loc *time.Location) ([]PlanDocument, error) {
+	var plans []PlanDocument
+	var problems FieldErrors
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, yamlSyntaxError(err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			// Nothing but comments
+			continue
+		}
+
+		plan, errs := decodePlan(doc.Content[0], loc)
+		problems = append(problems, errs...)
+		plans = append(plans, plan)
+	}
+
+	if len(problems) > maxYAMLErrors {
+		more := len(problems) - maxYAMLErrors
+		problems = append(problems[:maxYAMLErrors], inputError("yaml_sessions", "… and %d more problems", more))
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return plans, nil
+}
+
+// yamlLine matches the line number in errors of the YAML parser.
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// yamlSyntaxError reports a document that is not YAML at all.
+func yamlSyntaxError(err error) error {
+	m := yamlLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return inputError("yaml_sessions", "Invalid YAML format: %v", err)
+	}
+	line, _ := strconv.Atoi(m[1])
+	return inputError("yaml_sessions", "Invalid YAML on line %d: %s", line, m[2])
+}
+
+// decodePlan reads the fields of a plan and its list of sessions, checking
+// them against the import schema.
+func decodePlan(root *yaml.Node, loc *time.Location) (PlanDocument, FieldErrors) {
+	var plan PlanDocument
+	if root.Kind != yaml.MappingNode {
+		return plan, FieldErrors{inputError("yaml_sessions", "Line %d: expected \"sessions:\" with a list of sessions", root.Line)}
+	}
+
+	text := map[string]*string{
+		"name":         &plan.Name,
+		"workout_type": &plan.WorkoutType,
+		"description":  &plan.Description,
+		"source":       &plan.Source,
+		"author":       &plan.Author,
+	}
+	var problems FieldErrors
+	var items []*yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		prop, ok := importSchema.Properties[key.Value]
+		if !ok {
+			problems = append(problems, inputError("yaml_sessions", "Line %d: unknown field %q", key.Line, key.Value))
+			continue
+		}
+		if key.Value != "sessions" {
+			if !prop.allows(nodeType(value)) || value.Decode(text[key.Value]) != nil {
+				problems = append(problems, inputError("yaml_sessions", "Line %d: %s must be text", value.Line, key.Value))
+			}
+			if key.Value == "workout_type" {
+				plan.workoutTypeLine = value.Line
+			}
+			continue
+		}
+
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		switch {
+		case !prop.allows(nodeType(value)):
+			problems = append(problems, inputError("yaml_sessions", "Line %d: expected \"sessions:\" with a list of sessions", value.Line))
+		case value.Kind == yaml.SequenceNode:
+			items = value.Content
+		}
+	}
+
+	for i, item := range items {
+		s, typed, errs := decodeSession(item, i+1)
+		problems = append(problems, errs...)
+		if len(errs) > 0 {
+			continue
+		}
+		plan.typed = append(plan.typed, typed...)
+
+		date, startTime := dates.Split(s.Date, loc)
+		if s.Time != "" {
+			startTime = s.Time
+		}
+
+		plan.Sessions = append(plan.Sessions, NewSession{
+			Key:         s.Key,
+			Order:       s.Order,
+			Description: s.Description,
+			Date:        date,
+			StartTime:   startTime,
+			Duration:    s.Duration,
+			HFMax:       s.HFMax,
+		})
+	}
+	return plan, problems
+}
+
+// typedField is a field of a session that only some workout types have.
+type typedField struct {
+	name    string
+	session int
+	line    int
+	prop    *JSONSchema
+}
+
+// decodeSession reads a session field by field, so that every problem can
+// be told with its line. The fields are checked against the import schema
+// and returned along with those that only some workout types have.
+func decodeSession(node *yaml.Node, index int) (SessionYAML, []typedField, FieldErrors) {
+	var s SessionYAML
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return s, nil, FieldErrors{inputError("yaml_sessions", "Session %d, line %d: expected fields like \"date:\" and \"description:\"", index, node.Line)}
+	}
+
+	var problems FieldErrors
+	problem := func(msg string, line int, args ...interface{}) {
+		args = append([]interface{}{index, line}, args...)
+		problems = append(problems, inputError("yaml_sessions", msg, args...))
+	}
+
+	schema := importSchema.Definitions["session"]
+	fields := reflect.ValueOf(&s).Elem()
+	var typed []typedField
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if seen[key.Value] {
+			problem("Session %d, line %d: %s is given twice", key.Line, key.Value)
+			continue
+		}
+		seen[key.Value] = true
+
+		prop, ok := schema.Properties[key.Value]
+		if !ok {
+			problem("Session %d, line %d: unknown field %q", key.Line, key.Value)
+			continue
+		}
+		invalid := func() {
+			switch {
+			case prop.invalid != "" && value.Kind == yaml.ScalarNode:
+				problem(prop.invalid, value.Line, value.Value)
+			case prop.Type[0] == "integer":
+				problem("Session %d, line %d: %s must be a whole number", value.Line, key.Value)
+			default:
+				problem("Session %d, line %d: %s must be text", value.Line, key.Value)
+			}
+		}
+
+		t := nodeType(value)
+		switch {
+		case t == "null" && prop.missing != "":
+			problem(prop.missing, value.Line)
+			continue
+		case !prop.allows(t):
+			invalid()
+			continue
+		case !prop.matches(value) && prop.invalid == "":
+			problem(prop.missing, value.Line)
+			continue
+		case !prop.matches(value):
+			invalid()
+			continue
+		}
+
+		field := fields.Field(prop.index)
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			if value.Decode(&s.Date) != nil {
+				// A quoted date is meant as one as well
+				date, err := dates.Parse(value.Value)
+				if err != nil {
+					invalid()
+					continue
+				}
+				s.Date = date
+			}
+			if s.Date.IsZero() {
+				problem(prop.missing, value.Line)
+			}
+		} else if value.Decode(field.Addr().Interface()) != nil {
+			invalid()
+			continue
+		}
+		if len(prop.workoutTypes) > 0 && t != "null" {
+			typed = append(typed, typedField{name: key.Value, session: index, line: key.Line, prop: prop})
+		}
+	}
+
+	for _, name := range schema.Required {
+		if !seen[name] {
+			problem(schema.Properties[name].missing, node.Line)
+		}
+	}
+	return s, typed, problems
+}
+
+// MarshalPlan writes a plan in the import format, so that an exported plan
+// can be imported again as it is.
+func MarshalPlan(plan PlanDetail) ([]byte, error) {
+	out := PlanYAML{
+		Name:        plan.Name,
+		WorkoutType: plan.WorkoutType,
+		Description: plan.Description,
+		Source:      plan.Source,
+		Author:      plan.Author,
+	}
+	for _, s := range plan.Sessions {
+		if s.Archived {
+			// No longer part of the plan
+			continue
+		}
+		session := SessionYAML{
+			Key:         s.Key,
+			Order:       s.SessionOrder,
+			Description: s.Description,
+			Date:        dates.Of(s.Date),
+			HFMax:       s.HFMax,
+		}
+		if s.StartTime != nil {
+			session.Time = *s.StartTime
+		}
+		if s.Duration != nil {
+			session.Duration = *s.Duration
+		}
+		out.Sessions = append(out.Sessions, session)
+	}
+
+	// Indented like the plans written by hand
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(out); err != nil {
+		return nil, err
+	}
+	err := encoder.Close()
+	return b.Bytes(), err
+}
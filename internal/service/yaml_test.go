@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"training-tracker/internal/models"
+)
+
+func TestParsePlansShipped(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	types, err := svc.WorkoutTypes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, workoutType := range map[string]string{
+		"msr300.yaml":    "cycling",
+		"mobility.yaml":  "mobility",
+		"sandbag.yaml":   "sandbag",
+		"5min_core.yaml": "core",
+	} {
+		data, err := os.ReadFile("../../" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs, err := ParsePlans(data, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if len(docs) != 1 || len(docs[0].Sessions) == 0 {
+			t.Errorf("%s: got %d plans, want one with sessions", file, len(docs))
+			continue
+		}
+		plan, err := docs[0].Plan(types, NewPlan{Name: file, WorkoutTypeID: workoutTypeID(t, svc, workoutType)})
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if _, err := svc.CreatePlan(ctx, plan); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestParsePlansSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{
+			name: "all fields",
+			yaml: "name: Base\nworkout_type: cycling\nauthor: Me\nsessions:\n  - key: a\n    order: 1\n    description: GA1\n    date: 2025-03-03\n    time: \"07:30\"\n    duration: 60\n    hfmax: 140-150\n",
+		},
+		{
+			name: "scalars read as text",
+			yaml: "sessions:\n  - description: 42\n    date: 2025-03-03\n    hfmax: 150\n",
+		},
+		{
+			name: "unknown plan field",
+			yaml: "titel: Base\nsessions: []\n",
+			err:  `Line 1: unknown field "titel"`,
+		},
+		{
+			name: "unknown session field",
+			yaml: "sessions:\n  - date: 2025-03-03\n    notes: easy\n",
+			err:  `Session 1, line 3: unknown field "notes"`,
+		},
+		{
+			name: "missing date",
+			yaml: "sessions:\n  - description: GA1\n",
+			err:  "Session 1, line 2: date is required",
+		},
+		{
+			name: "invalid date",
+			yaml: "sessions:\n  - date: 3.3.2025\n",
+			err:  `Session 1, line 2: invalid date "3.3.2025", expected YYYY-MM-DD`,
+		},
+		{
+			name: "invalid time",
+			yaml: "sessions:\n  - date: 2025-03-03\n    time: \"25:00\"\n",
+			err:  `Session 1, line 3: invalid time "25:00", expected HH:MM`,
+		},
+		{
+			name: "negative duration",
+			yaml: "sessions:\n  - date: 2025-03-03\n    duration: -5\n",
+			err:  `Session 1, line 3: invalid duration "-5", expected a number of minutes`,
+		},
+		{
+			name: "order not a number",
+			yaml: "sessions:\n  - date: 2025-03-03\n    order: first\n",
+			err:  "Session 1, line 3: order must be a whole number",
+		},
+		{
+			name: "field given twice",
+			yaml: "sessions:\n  - date: 2025-03-03\n    date: 2025-03-04\n",
+			err:  "Session 1, line 3: date is given twice",
+		},
+		{
+			name: "sessions not a list",
+			yaml: "sessions: 3\n",
+			err:  `Line 1: expected "sessions:" with a list of sessions`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePlans([]byte(tt.yaml), time.UTC)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("got %v, want no error", err)
+			case tt.err != "" && (err == nil || err.Error() != tt.err):
+				t.Fatalf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParsePlansOrder(t *testing.T) {
+	docs, err := ParsePlans([]byte("sessions:\n  - date: 2025-03-03\n    order: 2\n  - date: 2025-03-04\n  - date: 2025-03-05\n    order: null\n"), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := docs[0].Sessions
+	if sessions[0].Order == nil || *sessions[0].Order != 2 {
+		t.Errorf("got order %v, want 2", sessions[0].Order)
+	}
+	for _, s := range sessions[1:] {
+		if s.Order != nil {
+			t.Errorf("got order %d for a session without one, want none", *s.Order)
+		}
+	}
+}
+
+func TestPlanDocumentWorkoutType(t *testing.T) {
+	types := []models.WorkoutType{{ID: 1, Name: "cycling"}, {ID: 2, Name: "core"}}
+	tests := []struct {
+		name     string
+		yaml     string
+		defaults NewPlan
+		typeID   int64
+		err      string
+	}{
+		{
+			name:   "named in the document",
+			yaml:   "workout_type: cycling\nsessions:\n  - date: 2025-03-03\n    hfmax: 150\n",
+			typeID: 1,
+		},
+		{
+			name:     "chosen on import",
+			yaml:     "sessions:\n  - date: 2025-03-03\n",
+			defaults: NewPlan{WorkoutTypeID: 2},
+			typeID:   2,
+		},
+		{
+			name: "unknown",
+			yaml: "workout_type: rowing\nsessions: []\n",
+			err:  `Line 1: unknown workout type "rowing"`,
+		},
+		{
+			name: "field of another type",
+			yaml: "workout_type: core\nsessions:\n  - date: 2025-03-03\n    hfmax: 150\n",
+			err:  "Session 1, line 4: hfmax is only for cycling sessions",
+		},
+		{
+			name:     "field of another chosen type",
+			yaml:     "sessions:\n  - date: 2025-03-03\n    hfmax: 150\n",
+			defaults: NewPlan{WorkoutTypeID: 2},
+			err:      "Session 1, line 3: hfmax is only for cycling sessions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := ParsePlans([]byte(tt.yaml), time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := docs[0].Plan(types, tt.defaults)
+			switch {
+			case tt.err != "":
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got %v, want %q", err, tt.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case plan.WorkoutTypeID != tt.typeID:
+				t.Errorf("got workout type %d, want %d", plan.WorkoutTypeID, tt.typeID)
+			}
+		})
+	}
+}
+
+// An exported plan is valid against the schema it is served with.
+func TestPlanSchema(t *testing.T) {
+	schema := PlanSchema([]models.WorkoutType{{ID: 1, Name: "cycling"}, {ID: 2, Name: "core"}})
+	if got := schema.Properties["workout_type"].Enum; !slices.Equal(got, []string{"cycling", "core"}) {
+		t.Errorf("got workout types %v, want cycling and core", got)
+	}
+	if importSchema.Properties["workout_type"].Enum != nil {
+		t.Error("PlanSchema changed the shared schema")
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatal(err)
+	}
+
+	order, minutes, start := 1, 60, "07:30"
+	data, err := MarshalPlan(PlanDetail{
+		TrainingPlan: models.TrainingPlan{Name: "Base"},
+		WorkoutType:  "cycling",
+		Sessions: []models.TrainingSession{{
+			Key:          "a",
+			SessionOrder: &order,
+			Description:  "GA1",
+			Date:         time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+			StartTime:    &start,
+			Duration:     &minutes,
+			HFMax:        "150",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ParsePlans(data, time.UTC)
+	if err != nil {
+		t.Fatalf("exported plan:\n%s\n%v", data, err)
+	}
+	got := docs[0].Sessions[0]
+	if got.Key != "a" || got.Order == nil || *got.Order != 1 || got.StartTime != start || got.Duration != minutes || got.HFMax != "150" {
+		t.Errorf("got %+v back from\n%s", got, data)
+	}
+}
@@ -0,0 +1,10 @@
+// Package static holds the files served below /static/, embedded into the
+// binary.
+package static
+
+import "embed"
+
+// FS contains the static files, e.g. favicon.svg.
+//
+//go:embed *.svg
+var FS embed.FS
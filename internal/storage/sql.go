@@ -0,0 +1,771 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+)
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// dialect holds what the SQL of the supported databases differs in. The
+// queries are written for SQLite and rewritten where needed.
+type dialect struct {
+	// Placeholders are numbered ("$1") instead of "?"
+	numbered bool
+	// Expression formatting the date of session ts as "2006-01-02"
+	day string
+}
+
+var (
+	sqliteDialect   = dialect{day: "DATE(ts.date)"}
+	postgresDialect = dialect{numbered: true, day: "TO_CHAR(ts.date, 'YYYY-MM-DD')"}
+)
+
+// rebind rewrites the "?" placeholders of query for the dialect. Queries
+// must not contain question marks in string literals.
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// conn runs queries of one dialect on the database or a transaction.
+type conn struct {
+	q querier
+	d dialect
+}
+
+// QueryObserver, if set, is told how long each query took, along with the
+// function that ran it, such as "planRepo.Get". For queries returning rows
+// that is the time until the first row is ready.
+var QueryObserver func(caller string, d time.Duration)
+
+// observe reports a query started at start to QueryObserver. It must be
+// deferred by the conn method running the query.
+func observe(start time.Time) {
+	if QueryObserver == nil {
+		return
+	}
+	caller := "unknown"
+	// Skip observe and the conn method
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			caller = strings.TrimPrefix(fn.Name(), "training-tracker/internal/storage.")
+		}
+	}
+	QueryObserver(caller, time.Since(start))
+}
+
+func (c conn) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observe(time.Now())
+	return c.q.ExecContext(ctx, c.d.rebind(query), args...)
+}
+
+func (c conn) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observe(time.Now())
+	return c.q.QueryContext(ctx, c.d.rebind(query), args...)
+}
+
+// scan runs a query over many rows whose loop checks ctx itself, see
+// detached.
+func (c conn) scan(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observe(time.Now())
+	return c.q.QueryContext(detached(ctx), c.d.rebind(query), args...)
+}
+
+func (c conn) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observe(time.Now())
+	return c.q.QueryRowContext(ctx, c.d.rebind(query), args...)
+}
+
+// insert runs an INSERT and returns the ID of the new row. PostgreSQL has no
+// last insert ID, so both dialects use RETURNING.
+func (c conn) insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	defer observe(time.Now())
+	var id int64
+	err := c.q.QueryRowContext(ctx, c.d.rebind(query+" RETURNING id"), args...).Scan(&id)
+	return id, err
+}
+
+type sqlStore struct {
+	db *sql.DB
+	conn
+}
+
+// NewSQLite returns a store backed by a SQLite database whose tables were
+// created with database.CreateTables.
+func NewSQLite(db *sql.DB) Store {
+	return &sqlStore{db: db, conn: conn{db, sqliteDialect}}
+}
+
+// NewPostgres returns a store backed by a PostgreSQL database whose tables
+// were created with database.CreateTables.
+func NewPostgres(db *sql.DB) Store {
+	return &sqlStore{db: db, conn: conn{db, postgresDialect}}
+}
+
+// New returns the store for a database opened with database.Open.
+func New(db *sql.DB, d database.Dialect) Store {
+	if d == database.Postgres {
+		return NewPostgres(db)
+	}
+	return NewSQLite(db)
+}
+
+func (s *sqlStore) WorkoutTypes() WorkoutTypeRepository { return workoutTypeRepo{s.conn} }
+func (s *sqlStore) Plans() PlanRepository               { return planRepo{s.conn} }
+func (s *sqlStore) Sessions() SessionRepository         { return sessionRepo{s.conn} }
+func (s *sqlStore) Completions() CompletionRepository   { return completionRepo{s.conn} }
+func (s *sqlStore) History() HistoryRepository          { return historyRepo{s.conn} }
+func (s *sqlStore) Settings() SettingsRepository        { return settingsRepo{s.conn} }
+func (s *sqlStore) Stats() StatsRepository              { return statsRepo{s.conn} }
+
+func (s *sqlStore) InTx(ctx context.Context, fn func(Store) error) error {
+	// Already inside a transaction
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqlStore{db: s.db, conn: conn{tx, s.d}}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// detached strips the cancellation from ctx for reading rows. go-sqlite3
+// starts a goroutine for every row it reads under a cancellable context,
+// which doubles the cost of the long scans behind streaks and analytics.
+// Only those use it, through scan, and check the context between rows
+// instead; every other query stays cancellable.
+func detached(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// notFound turns sql.ErrNoRows into ErrNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// expectRow returns ErrNotFound if an update did not touch any row.
+func expectRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type workoutTypeRepo struct{ conn }
+
+func (r workoutTypeRepo) List(ctx context.Context) ([]models.WorkoutType, error) {
+	rows, err := r.query(ctx, "SELECT id, name FROM workout_types ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []models.WorkoutType
+	for rows.Next() {
+		var wt models.WorkoutType
+		if err := rows.Scan(&wt.ID, &wt.Name); err != nil {
+			return nil, err
+		}
+		types = append(types, wt)
+	}
+	return types, rows.Err()
+}
+
+func (r workoutTypeRepo) Get(ctx context.Context, id int64) (models.WorkoutType, error) {
+	var wt models.WorkoutType
+	err := r.queryRow(ctx, "SELECT id, name FROM workout_types WHERE id = ?", id).Scan(&wt.ID, &wt.Name)
+	return wt, notFound(err)
+}
+
+type planRepo struct{ conn }
+
+// planColumns are read by scanPlan.
+const planColumns = `id, name, workout_type_id, created_at, description, source, author`
+
+func scanPlan(row scanner) (models.TrainingPlan, error) {
+	var plan models.TrainingPlan
+	err := row.Scan(&plan.ID, &plan.Name, &plan.WorkoutTypeID, &plan.CreatedAt, &plan.Description, &plan.Source, &plan.Author)
+	return plan, err
+}
+
+func (r planRepo) List(ctx context.Context) ([]models.TrainingPlan, error) {
+	rows, err := r.query(ctx, `
+		SELECT `+planColumns+`
+		FROM training_plans
+		ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []models.TrainingPlan
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+func (r planRepo) Get(ctx context.Context, id int64) (models.TrainingPlan, error) {
+	plan, err := scanPlan(r.queryRow(ctx, `
+		SELECT `+planColumns+`
+		FROM training_plans
+		WHERE id = ?`, id))
+	return plan, notFound(err)
+}
+
+func (r planRepo) Create(ctx context.Context, plan *models.TrainingPlan) error {
+	if plan.CreatedAt.IsZero() {
+		plan.CreatedAt = time.Now()
+	}
+	var err error
+	plan.ID, err = r.insert(ctx, `
+		INSERT INTO training_plans (name, workout_type_id, created_at, description, source, author)
+		VALUES (?, ?, ?, ?, ?, ?)`, plan.Name, plan.WorkoutTypeID, plan.CreatedAt, plan.Description, plan.Source, plan.Author)
+	return err
+}
+
+func (r planRepo) Update(ctx context.Context, plan models.TrainingPlan) error {
+	return expectRow(r.exec(ctx, `
+		UPDATE training_plans SET name = ?, description = ?, source = ?, author = ?
+		WHERE id = ?`, plan.Name, plan.Description, plan.Source, plan.Author, plan.ID))
+}
+
+type sessionRepo struct{ conn }
+
+// sessionColumns are read by scanSession.
+const sessionColumns = `
+	ts.id,
+	ts.plan_id,
+	ts.session_order,
+	COALESCE(ts.description, ''),
+	ts.date,
+	ts.start_time,
+	ts.duration_minutes,
+	ts.completed,
+	COALESCE(cs.hfmax, ''),
+	COALESCE(ts.sync_key, ''),
+	ts.archived`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row scanner, s *models.TrainingSession, extra ...interface{}) error {
+	var (
+		order     sql.NullInt64
+		startTime sql.NullString
+		duration  sql.NullInt64
+	)
+	dest := []interface{}{&s.ID, &s.PlanID, &order, &s.Description, &s.Date, &startTime, &duration, &s.Completed, &s.HFMax, &s.Key, &s.Archived}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if order.Valid {
+		v := int(order.Int64)
+		s.SessionOrder = &v
+	}
+	if startTime.Valid && startTime.String != "" {
+		s.StartTime = &startTime.String
+	}
+	if duration.Valid {
+		v := int(duration.Int64)
+		s.Duration = &v
+	}
+	return nil
+}
+
+func (r sessionRepo) Get(ctx context.Context, id int64) (models.TrainingSession, error) {
+	var s models.TrainingSession
+	err := scanSession(r.queryRow(ctx, `
+		SELECT`+sessionColumns+`
+		FROM training_sessions ts
+		LEFT JOIN cycling_sessions cs ON ts.id = cs.session_id
+		WHERE ts.id = ?`, id), &s)
+	return s, notFound(err)
+}
+
+func (r sessionRepo) ListByPlan(ctx context.Context, planID int64) ([]models.TrainingSession, error) {
+	rows, err := r.query(ctx, `
+		SELECT`+sessionColumns+`
+		FROM training_sessions ts
+		LEFT JOIN cycling_sessions cs ON ts.id = cs.session_id
+		WHERE ts.plan_id = ?
+		ORDER BY ts.session_order, ts.date`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.TrainingSession
+	for rows.Next() {
+		var s models.TrainingSession
+		if err := scanSession(rows, &s); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r sessionRepo) scheduled(ctx context.Context, where, order string, args ...interface{}) ([]ScheduledSession, error) {
+	rows, err := r.query(ctx, `
+		SELECT`+sessionColumns+`,
+			p.name,
+			wt.name as workout_type
+		FROM training_sessions ts
+		JOIN training_plans p ON ts.plan_id = p.id
+		JOIN workout_types wt ON p.workout_type_id = wt.id
+		LEFT JOIN cycling_sessions cs ON ts.id = cs.session_id
+		WHERE `+where+`
+		ORDER BY `+order, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []ScheduledSession{}
+	for rows.Next() {
+		var s ScheduledSession
+		if err := scanSession(rows, &s.TrainingSession, &s.PlanName, &s.WorkoutType); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r sessionRepo) InRanges(ctx context.Context, ranges ...DateRange) ([]ScheduledSession, error) {
+	if len(ranges) == 0 {
+		return []ScheduledSession{}, nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, dr := range ranges {
+		conditions = append(conditions, "(ts.date >= ? AND ts.date < ?)")
+		args = append(args, dr.From.Format(dates.Layout), dr.To.Format(dates.Layout))
+	}
+	return r.scheduled(ctx, strings.Join(conditions, " OR "), "ts.date, ts.start_time NULLS FIRST, ts.session_order", args...)
+}
+
+func (r sessionRepo) WithoutDuration(ctx context.Context) ([]ScheduledSession, error) {
+	return r.scheduled(ctx, "ts.duration_minutes IS NULL", "p.name, ts.date")
+}
+
+func (r sessionRepo) NextOrder(ctx context.Context, planID int64) (int, error) {
+	var order int
+	err := r.queryRow(ctx, `
+		SELECT COALESCE(MAX(session_order), 0) + 1
+		FROM training_sessions
+		WHERE plan_id = ?`, planID).Scan(&order)
+	return order, err
+}
+
+func (r sessionRepo) Create(ctx context.Context, s *models.TrainingSession, workoutType string) error {
+	var err error
+	s.ID, err = r.insert(ctx, `
+		INSERT INTO training_sessions (plan_id, session_order, description, date, completed, duration_minutes, start_time, sync_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`,
+		s.PlanID,
+		s.SessionOrder,
+		s.Description,
+		s.Date.Format(dates.Layout),
+		s.Completed,
+		s.Duration,
+		s.StartTime,
+		s.Key)
+	if err != nil {
+		return err
+	}
+
+	table, ok := database.DetailTables[workoutType]
+	if !ok {
+		return nil
+	}
+	if workoutType == "cycling" {
+		_, err = r.exec(ctx, `
+			INSERT INTO cycling_sessions (session_id, hfmax)
+			VALUES (?, NULLIF(?, ''))`, s.ID, s.HFMax)
+		return err
+	}
+	_, err = r.exec(ctx, fmt.Sprintf("INSERT INTO %s (session_id) VALUES (?)", table), s.ID)
+	return err
+}
+
+func (r sessionRepo) Update(ctx context.Context, s *models.TrainingSession, workoutType string) error {
+	err := expectRow(r.exec(ctx, `
+		UPDATE training_sessions
+		SET session_order = ?, description = ?, date = ?, duration_minutes = ?, start_time = ?, sync_key = NULLIF(?, ''), archived = ?
+		WHERE id = ?`,
+		s.SessionOrder,
+		s.Description,
+		s.Date.Format(dates.Layout),
+		s.Duration,
+		s.StartTime,
+		s.Key,
+		s.Archived,
+		s.ID))
+	if err != nil || workoutType != "cycling" {
+		return err
+	}
+	_, err = r.exec(ctx, `
+		INSERT INTO cycling_sessions (session_id, hfmax)
+		VALUES (?, NULLIF(?, ''))
+		ON CONFLICT (session_id) DO UPDATE SET hfmax = excluded.hfmax`, s.ID, s.HFMax)
+	return err
+}
+
+func (r sessionRepo) Delete(ctx context.Context, id int64) error {
+	return expectRow(r.exec(ctx, "DELETE FROM training_sessions WHERE id = ?", id))
+}
+
+func (r sessionRepo) Archive(ctx context.Context, id int64) error {
+	return expectRow(r.exec(ctx, "UPDATE training_sessions SET archived = ? WHERE id = ?", true, id))
+}
+
+func (r sessionRepo) SetCompleted(ctx context.Context, id int64, completed bool) error {
+	return expectRow(r.exec(ctx, "UPDATE training_sessions SET completed = ? WHERE id = ?", completed, id))
+}
+
+func (r sessionRepo) SetDurationIfMissing(ctx context.Context, id int64, minutes int) error {
+	_, err := r.exec(ctx, `
+		UPDATE training_sessions SET duration_minutes = ?
+		WHERE id = ? AND duration_minutes IS NULL`, minutes, id)
+	return err
+}
+
+type completionRepo struct{ conn }
+
+func (r completionRepo) Get(ctx context.Context, sessionID int64) (models.Completion, error) {
+	var (
+		c        models.Completion
+		duration sql.NullInt64
+		avgHR    sql.NullInt64
+		rpe      sql.NullInt64
+		distance sql.NullFloat64
+	)
+	err := r.queryRow(ctx, `
+		SELECT session_id, completed_at, duration_minutes, avg_hr, rpe, distance_km
+		FROM session_completions
+		WHERE session_id = ?`, sessionID).Scan(&c.SessionID, &c.CompletedAt, &duration, &avgHR, &rpe, &distance)
+	if err != nil {
+		return c, notFound(err)
+	}
+	for _, v := range []struct {
+		value sql.NullInt64
+		dest  **int
+	}{{duration, &c.Duration}, {avgHR, &c.AvgHR}, {rpe, &c.RPE}} {
+		if v.value.Valid {
+			n := int(v.value.Int64)
+			*v.dest = &n
+		}
+	}
+	if distance.Valid {
+		c.DistanceKm = &distance.Float64
+	}
+	return c, nil
+}
+
+func (r completionRepo) Save(ctx context.Context, c models.Completion) error {
+	if c.CompletedAt.IsZero() {
+		c.CompletedAt = time.Now()
+	}
+	_, err := r.exec(ctx, `
+		INSERT INTO session_completions (session_id, completed_at, duration_minutes, avg_hr, rpe, distance_km)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (session_id) DO UPDATE SET
+			completed_at = excluded.completed_at,
+			duration_minutes = excluded.duration_minutes,
+			avg_hr = excluded.avg_hr,
+			rpe = excluded.rpe,
+			distance_km = excluded.distance_km`,
+		c.SessionID, c.CompletedAt, c.Duration, c.AvgHR, c.RPE, c.DistanceKm)
+	return err
+}
+
+type historyRepo struct{ conn }
+
+func (r historyRepo) Add(ctx context.Context, c *models.PlanChange) error {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now()
+	}
+	var err error
+	c.ID, err = r.insert(ctx, `
+		INSERT INTO plan_history (plan_id, session_id, action, actor, created_at, snapshot_before, snapshot_after)
+		VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))`,
+		c.PlanID, c.SessionID, c.Action, c.Actor, c.CreatedAt, string(c.Before), string(c.After))
+	return err
+}
+
+func (r historyRepo) ListByPlan(ctx context.Context, planID int64) ([]models.PlanChange, error) {
+	rows, err := r.query(ctx, `
+		SELECT id, plan_id, session_id, action, actor, created_at, COALESCE(snapshot_before, ''), COALESCE(snapshot_after, '')
+		FROM plan_history
+		WHERE plan_id = ?
+		ORDER BY id DESC`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.PlanChange
+	for rows.Next() {
+		var (
+			c             models.PlanChange
+			sessionID     sql.NullInt64
+			before, after string
+		)
+		if err := rows.Scan(&c.ID, &c.PlanID, &sessionID, &c.Action, &c.Actor, &c.CreatedAt, &before, &after); err != nil {
+			return nil, err
+		}
+		if sessionID.Valid {
+			c.SessionID = &sessionID.Int64
+		}
+		if before != "" {
+			c.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			c.After = json.RawMessage(after)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+type settingsRepo struct{ conn }
+
+func (r settingsRepo) All(ctx context.Context) (map[string]string, error) {
+	rows, err := r.query(ctx, "SELECT key, value FROM settings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, rows.Err()
+}
+
+func (r settingsRepo) Save(ctx context.Context, values map[string]string) error {
+	for key, value := range values {
+		_, err := r.exec(ctx, `
+			INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type statsRepo struct{ conn }
+
+func (r statsRepo) CompletedPerDay(ctx context.Context, dr DateRange, filter StatsFilter) ([]DayTotal, error) {
+	rows, err := r.query(ctx, `
+		SELECT
+			`+r.d.day+` as day,
+			COUNT(*) as sessions,
+			COALESCE(SUM(COALESCE(sc.duration_minutes, ts.duration_minutes)), 0) as minutes
+		FROM training_sessions ts
+		JOIN training_plans p ON ts.plan_id = p.id
+		JOIN workout_types wt ON p.workout_type_id = wt.id
+		LEFT JOIN session_completions sc ON ts.id = sc.session_id
+		WHERE ts.completed
+			AND ts.date >= ? AND ts.date < ?
+			AND (? = '' OR wt.name = ?)
+			AND (? = 0 OR p.id = ?)
+		GROUP BY day
+		ORDER BY day
+	`,
+		dr.From.Format(dates.Layout), dr.To.Format(dates.Layout),
+		filter.WorkoutType, filter.WorkoutType,
+		filter.PlanID, filter.PlanID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []DayTotal{}
+	for rows.Next() {
+		var day DayTotal
+		if err := rows.Scan(&day.Date, &day.Sessions, &day.Minutes); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (r statsRepo) LoadSessions(ctx context.Context, filter StatsFilter) ([]LoadSession, error) {
+	rows, err := r.scan(ctx, `
+		SELECT
+			`+r.d.day+` as day,
+			ts.completed,
+			COALESCE(cs.hfmax, '') as hfmax,
+			COALESCE(sc.duration_minutes, ts.duration_minutes) as duration,
+			sc.avg_hr,
+			sc.rpe
+		FROM training_sessions ts
+		JOIN training_plans p ON ts.plan_id = p.id
+		JOIN workout_types wt ON p.workout_type_id = wt.id
+		LEFT JOIN cycling_sessions cs ON ts.id = cs.session_id
+		LEFT JOIN session_completions sc ON ts.id = sc.session_id
+		WHERE (? = '' OR wt.name = ?)
+			AND (? = 0 OR p.id = ?)
+		ORDER BY ts.date
+	`,
+		filter.WorkoutType, filter.WorkoutType,
+		filter.PlanID, filter.PlanID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []LoadSession
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var s LoadSession
+		if err := rows.Scan(&s.Date, &s.Completed, &s.HFMax, &s.Duration, &s.AvgHR, &s.RPE); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r statsRepo) VolumePerDay(ctx context.Context, dr DateRange, filter StatsFilter) ([]VolumeDay, error) {
+	rows, err := r.query(ctx, `
+		SELECT
+			`+r.d.day+` as day,
+			p.id,
+			p.name,
+			wt.name as workout_type,
+			COUNT(*) as planned_sessions,
+			SUM(CASE WHEN ts.completed THEN 1 ELSE 0 END) as completed_sessions,
+			COALESCE(SUM(ts.duration_minutes), 0) as planned_minutes,
+			COALESCE(SUM(CASE WHEN ts.completed THEN COALESCE(sc.duration_minutes, ts.duration_minutes) END), 0) as completed_minutes,
+			COALESCE(SUM(CASE WHEN ts.completed THEN sc.distance_km END), 0) as distance_km
+		FROM training_sessions ts
+		JOIN training_plans p ON ts.plan_id = p.id
+		JOIN workout_types wt ON p.workout_type_id = wt.id
+		LEFT JOIN session_completions sc ON ts.id = sc.session_id
+		WHERE ts.date >= ? AND ts.date < ?
+			AND (? = '' OR wt.name = ?)
+			AND (? = 0 OR p.id = ?)
+		GROUP BY day, p.id, p.name, wt.name
+		ORDER BY day
+	`,
+		dr.From.Format(dates.Layout), dr.To.Format(dates.Layout),
+		filter.WorkoutType, filter.WorkoutType,
+		filter.PlanID, filter.PlanID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []VolumeDay
+	for rows.Next() {
+		var d VolumeDay
+		if err := rows.Scan(
+			&d.Date,
+			&d.PlanID,
+			&d.PlanName,
+			&d.WorkoutType,
+			&d.PlannedSessions,
+			&d.CompletedSessions,
+			&d.PlannedMinutes,
+			&d.CompletedMinutes,
+			&d.DistanceKm,
+		); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (r statsRepo) DueSessions(ctx context.Context, today time.Time, fn func(DueSession) error) error {
+	rows, err := r.scan(ctx, `
+		SELECT plan_id, date = ? as due_today, completed
+		FROM training_sessions
+		WHERE date <= ?
+		ORDER BY plan_id, date, session_order
+	`, today.Format(dates.Layout), today.Format(dates.Layout))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var s DueSession
+		if err := rows.Scan(&s.PlanID, &s.DueToday, &s.Completed); err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"training-tracker/internal/database"
+	"training-tracker/internal/dates"
+	"training-tracker/internal/models"
+)
+
+// newTestStore returns a store on a fresh in-memory SQLite database with
+// the default workout types.
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	db, err := database.InitDB(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.CreateTables(db, database.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLite(db)
+}
+
+// createPlan stores a plan of the workout type with a session on each day.
+func createPlan(t *testing.T, store Store, workoutType string, days ...string) (models.TrainingPlan, []models.TrainingSession) {
+	t.Helper()
+	ctx := context.Background()
+	types, err := store.WorkoutTypes().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := models.TrainingPlan{Name: workoutType + " plan"}
+	for _, wt := range types {
+		if wt.Name == workoutType {
+			plan.WorkoutTypeID = wt.ID
+		}
+	}
+	if err := store.Plans().Create(ctx, &plan); err != nil {
+		t.Fatal(err)
+	}
+
+	var sessions []models.TrainingSession
+	for i, day := range days {
+		order, minutes := i+1, 30
+		date, err := dates.Parse(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := models.TrainingSession{PlanID: plan.ID, SessionOrder: &order, Description: "Session", Date: date, Duration: &minutes}
+		if err := store.Sessions().Create(ctx, &s, workoutType); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	return plan, sessions
+}
+
+func date(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := dates.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestQueriesCancel(t *testing.T) {
+	store := newTestStore(t)
+	createPlan(t, store, "core", "2025-03-01", "2025-03-02", "2025-03-03")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"query", func() error {
+			_, err := store.Plans().List(ctx)
+			return err
+		}},
+		{"query of a row", func() error {
+			_, err := store.WorkoutTypes().Get(ctx, 1)
+			return err
+		}},
+		// The long scans read detached and check the context between rows
+		{"load scan", func() error {
+			_, err := store.Stats().LoadSessions(ctx, StatsFilter{})
+			return err
+		}},
+		{"due scan", func() error {
+			return store.Stats().DueSessions(ctx, date(t, "2025-03-03"), func(DueSession) error { return nil })
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, context.Canceled) {
+				t.Errorf("got %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestSessionNotFound(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	_, sessions := createPlan(t, store, "cycling", "2025-03-01")
+	unknown := sessions[0].ID + 1
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"get", func() error {
+			_, err := store.Sessions().Get(ctx, unknown)
+			return err
+		}},
+		{"complete", func() error { return store.Sessions().SetCompleted(ctx, unknown, true) }},
+		{"update", func() error {
+			return store.Sessions().Update(ctx, &models.TrainingSession{ID: unknown, Date: date(t, "2025-03-01")}, "cycling")
+		}},
+		{"completion", func() error {
+			_, err := store.Completions().Get(ctx, sessions[0].ID)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); err != ErrNotFound {
+				t.Errorf("got %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestInRanges(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	_, sessions := createPlan(t, store, "mobility", "2025-03-03", "2025-03-03", "2025-03-03", "2025-03-05", "2025-03-20")
+	evening, morning := "18:00", "07:30"
+	for i, startTime := range []*string{&evening, nil, &morning} {
+		s := sessions[i]
+		s.StartTime = startTime
+		if err := store.Sessions().Update(ctx, &s, "mobility"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := store.Sessions().InRanges(ctx,
+		DateRange{From: date(t, "2025-03-03"), To: date(t, "2025-03-05")},
+		DateRange{From: date(t, "2025-03-20"), To: date(t, "2025-03-21")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{sessions[1].ID, sessions[2].ID, sessions[0].ID, sessions[4].ID}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sessions, want %d", len(got), len(want))
+	}
+	for i, s := range got {
+		if s.ID != want[i] {
+			t.Errorf("session %d is %d, want %d", i, s.ID, want[i])
+		}
+		if s.PlanName != "mobility plan" || s.WorkoutType != "mobility" {
+			t.Errorf("session %d of %q (%s)", s.ID, s.PlanName, s.WorkoutType)
+		}
+	}
+}
+
+func TestCompletedPerDay(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	_, cycling := createPlan(t, store, "cycling", "2025-03-01", "2025-03-01", "2025-03-02")
+	_, core := createPlan(t, store, "core", "2025-03-01", "2024-12-31")
+
+	recorded := 50
+	for _, c := range []models.Completion{
+		{SessionID: cycling[0].ID, Duration: &recorded},
+		{SessionID: cycling[1].ID},
+		{SessionID: core[0].ID},
+		{SessionID: core[1].ID},
+	} {
+		if err := store.Sessions().SetCompleted(ctx, c.SessionID, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Completions().Save(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	year := DateRange{From: date(t, "2025-01-01"), To: date(t, "2026-01-01")}
+	tests := []struct {
+		name   string
+		filter StatsFilter
+		want   []DayTotal
+	}{
+		// The recorded duration wins over the planned one
+		{"all", StatsFilter{}, []DayTotal{{"2025-03-01", 3, 50 + 30 + 30}}},
+		{"by type", StatsFilter{WorkoutType: "cycling"}, []DayTotal{{"2025-03-01", 2, 80}}},
+		{"by plan", StatsFilter{PlanID: core[0].PlanID}, []DayTotal{{"2025-03-01", 1, 30}}},
+		{"nothing", StatsFilter{WorkoutType: "sandbag"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Stats().CompletedPerDay(ctx, year, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestInTxRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	failed := errors.New("failed")
+
+	err := store.InTx(ctx, func(tx Store) error {
+		plan := models.TrainingPlan{Name: "Rolled back", WorkoutTypeID: 1}
+		if err := tx.Plans().Create(ctx, &plan); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("got %v, want %v", err, failed)
+	}
+	plans, err := store.Plans().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 0 {
+		t.Errorf("got %d plans after the rollback", len(plans))
+	}
+}
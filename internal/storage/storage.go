@@ -0,0 +1,164 @@
+// Package storage persists workout types, plans, sessions and everything
+// recorded about them. All SQL lives here; the rest of the application works
+// against the repository interfaces.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"training-tracker/internal/models"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Store gives access to all repositories.
+type Store interface {
+	WorkoutTypes() WorkoutTypeRepository
+	Plans() PlanRepository
+	Sessions() SessionRepository
+	Completions() CompletionRepository
+	History() HistoryRepository
+	Settings() SettingsRepository
+	Stats() StatsRepository
+
+	// InTx runs fn with a store whose repositories share one transaction.
+	// The transaction is committed if fn returns nil and rolled back
+	// otherwise.
+	InTx(ctx context.Context, fn func(Store) error) error
+}
+
+type WorkoutTypeRepository interface {
+	List(ctx context.Context) ([]models.WorkoutType, error)
+	Get(ctx context.Context, id int64) (models.WorkoutType, error)
+}
+
+type PlanRepository interface {
+	// List returns all plans, newest first.
+	List(ctx context.Context) ([]models.TrainingPlan, error)
+	Get(ctx context.Context, id int64) (models.TrainingPlan, error)
+	// Create inserts the plan and sets its ID.
+	Create(ctx context.Context, plan *models.TrainingPlan) error
+	// Update stores the name and the metadata of the plan.
+	Update(ctx context.Context, plan models.TrainingPlan) error
+}
+
+type SessionRepository interface {
+	Get(ctx context.Context, id int64) (models.TrainingSession, error)
+	// ListByPlan returns the sessions of a plan in their order.
+	ListByPlan(ctx context.Context, planID int64) ([]models.TrainingSession, error)
+	// InRanges returns the sessions on any day of the given ranges, ordered
+	// by date and start time, sessions without one first.
+	InRanges(ctx context.Context, ranges ...DateRange) ([]ScheduledSession, error)
+	// WithoutDuration returns all sessions without a planned duration.
+	WithoutDuration(ctx context.Context) ([]ScheduledSession, error)
+	// NextOrder returns the order a session appended to the plan gets.
+	NextOrder(ctx context.Context, planID int64) (int, error)
+	// Create inserts the session together with the details of its workout
+	// type and sets its ID.
+	Create(ctx context.Context, session *models.TrainingSession, workoutType string) error
+	// Update stores what a plan's YAML tells about the session: its order,
+	// description, date, start time, planned duration, key, whether it is
+	// archived and the details of its workout type. Whether it is completed
+	// is left alone.
+	Update(ctx context.Context, session *models.TrainingSession, workoutType string) error
+	// Delete removes the session along with its details and completion.
+	Delete(ctx context.Context, id int64) error
+	// Archive marks the session as dropped from its plan.
+	Archive(ctx context.Context, id int64) error
+	SetCompleted(ctx context.Context, id int64, completed bool) error
+	// SetDurationIfMissing stores the planned duration unless the session
+	// already has one.
+	SetDurationIfMissing(ctx context.Context, id int64, minutes int) error
+}
+
+type CompletionRepository interface {
+	// Get returns the completion of a session.
+	Get(ctx context.Context, sessionID int64) (models.Completion, error)
+	// Save stores the completion, replacing any earlier one of the session.
+	Save(ctx context.Context, completion models.Completion) error
+}
+
+type HistoryRepository interface {
+	// Add appends the change to its plan's history and sets its ID.
+	Add(ctx context.Context, change *models.PlanChange) error
+	// ListByPlan returns the history of a plan, newest first.
+	ListByPlan(ctx context.Context, planID int64) ([]models.PlanChange, error)
+}
+
+type SettingsRepository interface {
+	All(ctx context.Context) (map[string]string, error)
+	Save(ctx context.Context, values map[string]string) error
+}
+
+// StatsRepository answers the aggregate queries of the analytics.
+type StatsRepository interface {
+	// CompletedPerDay sums up the completed sessions per day.
+	CompletedPerDay(ctx context.Context, r DateRange, filter StatsFilter) ([]DayTotal, error)
+	// LoadSessions returns every session with what is known about its load,
+	// ordered by date.
+	LoadSessions(ctx context.Context, filter StatsFilter) ([]LoadSession, error)
+	// VolumePerDay sums up planned and completed volume per day and plan.
+	VolumePerDay(ctx context.Context, r DateRange, filter StatsFilter) ([]VolumeDay, error)
+	// DueSessions calls fn for every session up to and including today,
+	// ordered by plan, date and order. It streams since years of history
+	// make for many sessions.
+	DueSessions(ctx context.Context, today time.Time, fn func(DueSession) error) error
+}
+
+// DateRange is a range of calendar dates, excluding To.
+type DateRange struct {
+	From, To time.Time
+}
+
+// ScheduledSession is a session along with the plan it belongs to.
+type ScheduledSession struct {
+	models.TrainingSession
+	PlanName    string `json:"plan_name"`
+	WorkoutType string `json:"workout_type"`
+}
+
+// StatsFilter narrows the analytics down to a workout type or plan. Zero
+// values select everything.
+type StatsFilter struct {
+	WorkoutType string
+	PlanID      int64
+}
+
+type DayTotal struct {
+	Date     string
+	Sessions int
+	Minutes  int
+}
+
+// LoadSession is a session with everything needed to estimate its load.
+type LoadSession struct {
+	Date      string
+	Completed bool
+	HFMax     string
+	// Recorded duration, or the planned one
+	Duration sql.NullInt64
+	AvgHR    sql.NullInt64
+	RPE      sql.NullInt64
+}
+
+type VolumeDay struct {
+	Date              string
+	PlanID            int64
+	PlanName          string
+	WorkoutType       string
+	PlannedSessions   int
+	CompletedSessions int
+	PlannedMinutes    int
+	CompletedMinutes  int
+	DistanceKm        float64
+}
+
+type DueSession struct {
+	PlanID    int64
+	DueToday  bool
+	Completed bool
+}
@@ -0,0 +1,10 @@
+// Package templates holds the pages of the web interface, embedded into the
+// binary.
+package templates
+
+import "embed"
+
+// FS contains the page templates, e.g. calendar.html.
+//
+//go:embed *.html
+var FS embed.FS